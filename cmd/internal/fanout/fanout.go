@@ -0,0 +1,177 @@
+// Package fanout runs the same operation against several rexd hosts.
+package fanout
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// DefaultPort is added to the hosts that are given without a port
+	DefaultPort = "9090"
+
+	// noMatch is a pattern that matches no line, so that waiting for it
+	// only ends when the process does
+	noMatch = `[^\x00-\x{10FFFF}]`
+)
+
+// ParseHosts splits a comma separated list of hosts, adding DefaultPort to
+// the hosts without a port.
+func ParseHosts(list string) ([]string, error) {
+	var hosts []string
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return normalize(hosts)
+}
+
+// ReadHosts reads hosts from r, one per line, skipping blank lines and
+// lines starting with "#". Adds DefaultPort to the hosts without a port.
+func ReadHosts(r io.Reader) ([]string, error) {
+	var hosts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return normalize(hosts)
+}
+
+// Merge concatenates lists of hosts returned by ParseHosts and ReadHosts,
+// rejecting the hosts that appear in more than one of them.
+func Merge(lists ...[]string) ([]string, error) {
+	var hosts []string
+	for _, list := range lists {
+		hosts = append(hosts, list...)
+	}
+	return normalize(hosts)
+}
+
+func normalize(hosts []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), DefaultPort)
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("duplicate host %q", host)
+		}
+		seen[host] = true
+		ret = append(ret, host)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no hosts")
+	}
+	return ret, nil
+}
+
+// Run calls fn(i) for i in [0, n) concurrently, with at most parallelism
+// calls in progress at a time (no limit if parallelism <= 0). Returns the
+// error of each call.
+func Run(n, parallelism int, fn func(i int) error) []error {
+	if parallelism <= 0 || parallelism > n {
+		parallelism = n
+	}
+	errs := make([]error, n)
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Target is a process on a host.
+type Target struct {
+	Host      string
+	ProcessID uuid.UUID
+}
+
+// String formats the target as a line of the input of ReadTargets
+func (t Target) String() string {
+	return t.Host + " " + t.ProcessID.String()
+}
+
+// ReadTargets reads the targets written by Target.String from r, one per
+// line, skipping blank lines.
+func ReadTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a host and a process id", line)
+		}
+		processID, err := uuid.Parse(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		targets = append(targets, Target{Host: fields[0], ProcessID: processID})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// WaitExit waits for a process to reach its final state, or for ctx to be
+// done.
+func WaitExit(ctx context.Context, waiter rex.OutputWaiter, processID uuid.UUID) (rex.ProcessInfo, error) {
+	match, err := waiter.WaitFor(ctx, processID, rex.StdoutStream, noMatch)
+	if err != nil {
+		return rex.ProcessInfo{}, err
+	}
+	return match.Process, nil
+}
+
+// PrefixLines writes each of the lines of output to w, prefixed with
+// prefix. A missing line terminator is added to the last line.
+func PrefixLines(w io.Writer, prefix string, output []byte) error {
+	if len(output) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(output, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		buf.WriteString(prefix)
+		buf.Write(line)
+	}
+	if output[len(output)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,113 @@
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestParseHosts(t *testing.T) {
+	hosts, err := ParseHosts("h1, h2:9191,,10.0.0.1,::1")
+	if err != nil {
+		t.Fatalf("While parsing hosts: %v", err)
+	}
+	expected := []string{"h1:9090", "h2:9191", "10.0.0.1:9090", "[::1]:9090"}
+	if strings.Join(hosts, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got %v", expected, hosts)
+	}
+
+	for _, list := range []string{"", " , ", "h1,h1:9090"} {
+		if _, err := ParseHosts(list); err == nil {
+			t.Errorf("Expected an error for %q", list)
+		}
+	}
+
+	hosts, err = ReadHosts(strings.NewReader("# web\nweb-1\n\n  web-2:22 \n"))
+	if err != nil || strings.Join(hosts, " ") != "web-1:9090 web-2:22" {
+		t.Errorf("Expected the hosts of the file, got %v, %v", hosts, err)
+	}
+
+	merged, err := Merge([]string{"h1:9090"}, hosts)
+	if err != nil || strings.Join(merged, " ") != "h1:9090 web-1:9090 web-2:22" {
+		t.Errorf("Expected the hosts of both lists, got %v, %v", merged, err)
+	}
+	if _, err := Merge([]string{"web-1:9090"}, hosts); err == nil {
+		t.Errorf("Expected a host in both lists to be rejected")
+	}
+}
+
+func TestWaitExit(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	processID, err := s.Exec(ctx, "sh", "-c", "printf 'a\\n\\377\\n'; sleep 0.2; exit 3")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	info, err := WaitExit(ctx, s, processID)
+	if err != nil {
+		t.Fatalf("While waiting for the process: %v", err)
+	}
+	if info.State != rex.ProcessExited || info.ExitCode != 3 {
+		t.Errorf("Expected the process to have exited with 3, got %+v", info)
+	}
+}
+
+func TestRun_Parallelism(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e"}
+	var m sync.Mutex
+	running, maxRunning := 0, 0
+	errs := Run(len(hosts), 2, func(i int) error {
+		m.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		m.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		m.Lock()
+		running--
+		m.Unlock()
+		if hosts[i] == "c" {
+			return bytes.ErrTooLarge
+		}
+		return nil
+	})
+	if maxRunning != 2 {
+		t.Errorf("Expected at most 2 concurrent calls, got %d", maxRunning)
+	}
+	for i, err := range errs {
+		if (err != nil) != (hosts[i] == "c") {
+			t.Errorf("Unexpected error for host %s: %v", hosts[i], err)
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	targets := []Target{{"h1:9090", uuid.New()}, {"h2:9090", uuid.New()}}
+	var buf bytes.Buffer
+	for _, target := range targets {
+		buf.WriteString(target.String() + "\n\n")
+	}
+	parsed, err := ReadTargets(&buf)
+	if err != nil || len(parsed) != 2 || parsed[0] != targets[0] || parsed[1] != targets[1] {
+		t.Errorf("Expected %v, got %v, %v", targets, parsed, err)
+	}
+	if _, err := ReadTargets(strings.NewReader("h1:9090\n")); err == nil {
+		t.Errorf("Expected a line without a process id to be rejected")
+	}
+
+	buf.Reset()
+	PrefixLines(&buf, "h1: ", []byte("a\nb"))
+	if buf.String() != "h1: a\nh1: b\n" {
+		t.Errorf("Unexpected prefixed output %q", buf.String())
+	}
+}
@@ -0,0 +1,104 @@
+// Package pipeline splits command lines of the form "grep foo | sort" into
+// the executables and the args of their stages, without involving a shell.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parse splits line into stages at the unquoted "|" characters, and each
+// stage into words at the unquoted whitespace. Single quotes preserve their
+// content literally, while inside double quotes a backslash only escapes
+// '"', '\\', '$' and '`'. Outside of quotes a backslash escapes any
+// character. Nothing is expanded, and the unquoted characters that a shell
+// would treat specially (";&<>()`$") are rejected rather than passed on
+// literally.
+func Parse(line string) ([][]string, error) {
+	var stages [][]string
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endStage := func() error {
+		endWord()
+		if len(words) == 0 {
+			return errors.New("empty pipeline stage")
+		}
+		stages = append(stages, words)
+		words = nil
+		return nil
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			endWord()
+
+		case r == '|':
+			if err := endStage(); err != nil {
+				return nil, err
+			}
+
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+
+		case strings.ContainsRune(";&<>()`$", r):
+			return nil, fmt.Errorf("unsupported shell syntax %q (quote it to pass it literally)", r)
+
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if err := endStage(); err != nil {
+		return nil, err
+	}
+	return stages, nil
+}
+
+func indexRune(runes []rune, from int, target rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+	return -1
+}
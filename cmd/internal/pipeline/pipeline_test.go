@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, testCase := range []struct {
+		line     string
+		expected [][]string
+	}{
+		{"grep foo | sort | uniq -c", [][]string{{"grep", "foo"}, {"sort"}, {"uniq", "-c"}}},
+		{"  echo   a\tb  ", [][]string{{"echo", "a", "b"}}},
+		{`grep 'a | b; $HOME' | tr "\"x\\" '\n'`, [][]string{{"grep", "a | b; $HOME"}, {"tr", `"x\`, `\n`}}},
+		{`echo a\ b\|c ''`, [][]string{{"echo", "a b|c", ""}}},
+		{`echo "a"'b'c`, [][]string{{"echo", "abc"}}},
+		{`echo "\n\$"`, [][]string{{"echo", `\n$`}}},
+	} {
+		stages, err := Parse(testCase.line)
+		if err != nil {
+			t.Errorf("While parsing %q: %v", testCase.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(stages, testCase.expected) {
+			t.Errorf("Expected %q to be parsed as %q, got %q", testCase.line, testCase.expected, stages)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"grep foo |",
+		"| sort",
+		"grep foo || sort",
+		"echo 'unterminated",
+		`echo "unterminated`,
+		`echo trailing\`,
+		"echo a > out",
+		"echo a; rm -rf /",
+		"echo $(id)",
+		"sleep 1 &",
+	} {
+		if stages, err := Parse(line); err == nil {
+			t.Errorf("Expected %q to be rejected, got %q", line, stages)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package server holds the flags and the setup that rexd and rexproxy share:
+// TLS, the identities of the clients, the policies and the rate limits.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex/cmd/internal/io"
+	rex_grpc "github.com/farnasirim/rex/grpc"
+)
+
+// VariadicFlag collects the values of a flag that can be passed multiple
+// times.
+type VariadicFlag []string
+
+// Set appends value to the values of the flag
+func (f *VariadicFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f *VariadicFlag) String() string {
+	return ""
+}
+
+// Flags are the command line flags shared by the servers
+type Flags struct {
+	PolicyFlags        VariadicFlag
+	RateLimitFlags     VariadicFlag
+	PathToCACert       string
+	PathToCert         string
+	PathToKey          string
+	CertGroups         string
+	PathToPolicyFile   string
+	PolicyAlgorithm    string
+	LogDenials         bool
+	PolicyFileInterval time.Duration
+	PathToBindings     string
+	ServeAddr          string
+}
+
+// Register defines the flags on fs. role names the certificate of the
+// server in the usage, and resources tells whether the policies can take a
+// Resource.
+func (f *Flags) Register(fs *flag.FlagSet, role string, resources bool) {
+	policyKeys := "Principal, Action, Effect, and optionally Conditions"
+	if resources {
+		policyKeys += " and Resource"
+	}
+	fs.Var(&f.PolicyFlags, "policy",
+		"JSON formatted policy with keys "+policyKeys+", "+
+			"or with keys Effect and Expression, or a set of policies with keys Name, Algorithm and Policies. "+
+			"All of them take an optional Priority. Can be passed multiple times.")
+	fs.StringVar(&f.PathToPolicyFile, "policy-file", "",
+		"path to a YAML or JSON file listing policies under the Policies key, in addition to -policy. "+
+			"Reloaded on SIGHUP and when it changes.")
+	fs.DurationVar(&f.PolicyFileInterval, "policy-file-interval", 5*time.Second,
+		"wait between the checks for changes to -policy-file")
+	fs.StringVar(&f.PolicyAlgorithm, "policy-algorithm", string(rex_grpc.DenyOverrides),
+		"how the verdicts of the policies are combined: deny-overrides, permit-overrides or first-applicable, "+
+			"in the order of their Priority")
+	fs.BoolVar(&f.LogDenials, "log-denials", false,
+		"log the policies that decide on every denied call")
+	fs.StringVar(&f.CertGroups, "cert-groups", "",
+		"comma separated fields of the client certificates that the groups of the clients are read from: "+
+			"OU, O, URI (rex:group/NAME and rex:role/NAME SAN URIs) or OID:<dotted OID>")
+	fs.StringVar(&f.PathToBindings, "role-bindings", "",
+		"path to a JSON file with keys Groups and Roles, mapping group and role names to their members")
+	fs.Var(&f.RateLimitFlags, "rate-limit",
+		"JSON formatted rate limit with keys Principal, Method, Rate (calls per second), and Burst. "+
+			"Can be passed multiple times.")
+
+	fs.StringVar(&f.PathToCACert, "ca", "", "path to ca certificate in pem format")
+	fs.StringVar(&f.PathToCert, "cert", "", "path to "+role+" certificate in pem format")
+	fs.StringVar(&f.PathToKey, "key", "", "path to "+role+" private key in pem format")
+	fs.StringVar(&f.ServeAddr, "addr", "localhost:9090", "serve address of format [ip]:port")
+}
+
+// Validate exits if a required flag is missing
+func (f *Flags) Validate() {
+	if f.PathToCACert == "" {
+		log.Fatalln("Missing -ca arg")
+	}
+
+	if f.PathToKey == "" {
+		log.Fatalln("Missing -key arg")
+	}
+
+	if f.PathToCert == "" {
+		log.Fatalln("Missing -cert arg")
+	}
+}
+
+// Policies parses -policy and loads -policy-file, which keeps being
+// reloaded from then on.
+func (f *Flags) Policies() []rex_grpc.Policy {
+	var policies []rex_grpc.Policy
+	for _, fl := range f.PolicyFlags {
+		x, err := rex_grpc.PolicyFromJSON([]byte(fl))
+		if err != nil {
+			log.Fatalf("Policy argument malformed: %v", err)
+		}
+		policies = append(policies, x)
+	}
+	if f.PathToPolicyFile != "" {
+		policies = append(policies, f.policyFile())
+	}
+	return policies
+}
+
+// policyFile loads -policy-file and keeps reloading it on SIGHUP and when it
+// changes. Invalid files are logged and leave the policies as they are.
+func (f *Flags) policyFile() *rex_grpc.PolicyFile {
+	policyFile, err := rex_grpc.NewPolicyFile(f.PathToPolicyFile)
+	if err != nil {
+		log.Fatalf("Policy file malformed: %v", err)
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			if err := policyFile.Reload(); err != nil {
+				log.Errorf("Keeping the current policies: %v", err)
+			}
+		}
+	}()
+	go policyFile.Watch(context.Background(), f.PolicyFileInterval)
+	return policyFile
+}
+
+// PolicyEnforcer combines policies as configured by -policy-algorithm
+func (f *Flags) PolicyEnforcer(policies []rex_grpc.Policy) *rex_grpc.PolicyEnforcer {
+	algorithm, err := rex_grpc.ParseCombiningAlgorithm(f.PolicyAlgorithm)
+	if err != nil {
+		log.Fatalf("Policy algorithm malformed: %v", err)
+	}
+	return rex_grpc.NewCombiningPolicyEnforcer(algorithm, policies...)
+}
+
+// EnforcedPolicy wraps enforcer to log the denials if -log-denials is set
+func (f *Flags) EnforcedPolicy(enforcer *rex_grpc.PolicyEnforcer) rex_grpc.Policy {
+	if f.LogDenials {
+		return rex_grpc.LogDenials(enforcer)
+	}
+	return enforcer
+}
+
+// IdentityMapper finds the groups and the roles of the clients as
+// configured by -cert-groups and -role-bindings
+func (f *Flags) IdentityMapper() *rex_grpc.IdentityMapper {
+	sources, err := rex_grpc.ParseGroupSources(f.CertGroups)
+	if err != nil {
+		log.Fatalf("Certificate group sources malformed: %v", err)
+	}
+	var bindings *rex_grpc.RoleBindings
+	if f.PathToBindings != "" {
+		bindings, err = rex_grpc.RoleBindingsFromJSON(io.ReadFileOrFatal(f.PathToBindings))
+		if err != nil {
+			log.Fatalf("Role bindings malformed: %v", err)
+		}
+	}
+	return rex_grpc.NewIdentityMapper(bindings, sources...)
+}
+
+// RateLimiter parses -rate-limit
+func (f *Flags) RateLimiter() *rex_grpc.RateLimiter {
+	var rateLimits []*rex_grpc.RateLimitRule
+	for _, fl := range f.RateLimitFlags {
+		rule, err := rex_grpc.RateLimitRuleFromJSON([]byte(fl))
+		if err != nil {
+			log.Fatalf("Rate limit argument malformed: %v", err)
+		}
+		rateLimits = append(rateLimits, rule)
+	}
+	return rex_grpc.NewRateLimiter(rateLimits...)
+}
+
+// KeyPair loads the CA of -ca and the certificate of -cert and -key
+func (f *Flags) KeyPair() (*x509.CertPool, tls.Certificate) {
+	caPool := x509.NewCertPool()
+	if ok := caPool.AppendCertsFromPEM(io.ReadFileOrFatal(f.PathToCACert)); !ok {
+		log.Fatalln("CA cert malformed")
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.PathToCert, f.PathToKey)
+	if err != nil {
+		log.Fatalf("Failed to load key pair: %v\n", err)
+	}
+	return caPool, cert
+}
+
+// NewGRPCServer creates a server requiring the clients to present a
+// certificate signed by -ca, and running every call through the identity
+// mapper, the rate limits and policy. Only the trusted proxies may make
+// calls on behalf of other principals.
+func (f *Flags) NewGRPCServer(policy rex_grpc.Policy, identityMapper *rex_grpc.IdentityMapper,
+	trustedProxies ...string) *grpc.Server {
+	caPool, cert := f.KeyPair()
+	rateLimiter := f.RateLimiter()
+	return grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: false,
+		RootCAs:            caPool,
+		Certificates:       []tls.Certificate{cert},
+		ClientAuth:         tls.RequireAndVerifyClientCert,
+		ClientCAs:          caPool,
+	})),
+		grpc.ChainUnaryInterceptor(
+			rex_grpc.AuthInfoInterceptor,
+			rex_grpc.TrustedProxyInterceptor(trustedProxies...),
+			rex_grpc.IdentityInterceptor(identityMapper),
+			rex_grpc.RateLimitInterceptor(rateLimiter),
+			rex_grpc.PolicyEnforcementInterceptor(policy),
+			rex_grpc.ErrorMarshallerInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			rex_grpc.AuthInfoStreamInterceptor,
+			rex_grpc.TrustedProxyStreamInterceptor(trustedProxies...),
+			rex_grpc.IdentityStreamInterceptor(identityMapper),
+			rex_grpc.RateLimitStreamInterceptor(rateLimiter),
+			rex_grpc.PolicyEnforcementStreamInterceptor(policy),
+			rex_grpc.ErrorMarshallerStreamInterceptor,
+		),
+	)
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlags_Register(t *testing.T) {
+	var flags Flags
+	fs := flag.NewFlagSet("rexd", flag.ContinueOnError)
+	flags.Register(fs, "server", true)
+	err := fs.Parse([]string{
+		"-policy", `{"Effect": "allow"}`, "-policy", `{"Effect": "deny"}`,
+		"-rate-limit", `{"Rate": 1}`,
+		"-ca", "ca.pem", "-cert", "cert.pem", "-key", "key.pem",
+		"-policy-file-interval", "1s", "-log-denials",
+	})
+	if err != nil {
+		t.Fatalf("While parsing the flags: %v", err)
+	}
+	if !reflect.DeepEqual(flags.PolicyFlags, VariadicFlag{`{"Effect": "allow"}`, `{"Effect": "deny"}`}) {
+		t.Errorf("Expected both policies to be kept in order, got %q", flags.PolicyFlags)
+	}
+	if len(flags.RateLimitFlags) != 1 || flags.PathToCACert != "ca.pem" || flags.PathToCert != "cert.pem" ||
+		flags.PathToKey != "key.pem" || flags.PolicyFileInterval != time.Second || !flags.LogDenials {
+		t.Errorf("Expected the flags to be set, got %+v", flags)
+	}
+	if flags.ServeAddr != "localhost:9090" || flags.PolicyAlgorithm != "deny-overrides" {
+		t.Errorf("Expected the defaults of the unset flags, got %+v", flags)
+	}
+}
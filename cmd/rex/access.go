@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+func runQuota(ctx context.Context, reporter rex.QuotaReporter, args []string) {
+	if len(args) > 0 {
+		log.Warnf("Ignoring %d extra arguments to %q", len(args), "quota")
+	}
+	quota, usage, err := reporter.GetQuota(ctx)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Resource", "Usage", "Limit"})
+	table.Append([]string{"running + queued processes",
+		fmt.Sprintf("%d + %d", usage.Running, usage.Queued), quotaLimit(quota.MaxRunning)})
+	table.Append([]string{"processes in the last hour",
+		fmt.Sprint(usage.CreatedLastHour), quotaLimit(quota.MaxPerHour)})
+	table.Append([]string{"stored output bytes",
+		fmt.Sprint(usage.OutputBytes), quotaLimit(quota.MaxOutputBytes)})
+	table.Append([]string{"CPU seconds in the last day",
+		fmt.Sprintf("%.2f", usage.CPUSecondsLastDay), quotaLimit(quota.MaxCPUSecondsPerDay)})
+	table.Render()
+}
+
+func quotaLimit(limit interface{}) string {
+	if fmt.Sprint(limit) == "0" {
+		return "unlimited"
+	}
+	return fmt.Sprint(limit)
+}
+
+func runWhoAmI(ctx context.Context, reporter rex.IdentityReporter, args []string) {
+	if len(args) > 0 {
+		log.Warnf("Ignoring %d extra arguments to %q", len(args), "whoami")
+	}
+	identity, err := reporter.WhoAmI(ctx)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Printf("User:\t%s\nGroups:\t%s\nRoles:\t%s\n", identity.UserID,
+		strings.Join(identity.Groups, ", "), strings.Join(identity.Roles, ", "))
+}
+
+// canIMethods maps the actions of the cli to the methods that they call
+var canIMethods = map[string]string{
+	"exec":   "/Rex/Exec",
+	"kill":   "/Rex/Kill",
+	"delete": "/Rex/Delete",
+	"get":    "/Rex/GetProcessInfo",
+	"read":   "/Rex/Read",
+	"ps":     "/Rex/ListProcessInfo",
+	"quota":  "/Rex/GetQuota",
+	"whoami": "/Rex/WhoAmI",
+}
+
+func runCanI(ctx context.Context, checker rex.AccessChecker, args []string) {
+	canIFlags := flag.NewFlagSet("can-i", flag.ExitOnError)
+	principal := canIFlags.String("as", "", "check for this principal instead of the caller")
+	var groups, roles variadicFlag
+	canIFlags.Var(&groups, "group", "check as a member of this group too. Can be passed multiple times.")
+	canIFlags.Var(&roles, "role", "check as having this role too. Can be passed multiple times.")
+	if err := canIFlags.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	rest := canIFlags.Args()
+	if len(rest) < 1 {
+		log.Fatalln("usage: can-i [-as PRINCIPAL] [-group GROUP] [-role ROLE] " +
+			"exec PATH [ARGS...] | kill [-signal N] PROCESS_ID | ACTION|/Rex/METHOD [PROCESS_ID]")
+	}
+	action, rest := rest[0], rest[1:]
+
+	check := rex.AccessCheck{Method: action, Principal: *principal, Groups: groups, Roles: roles}
+	if method, ok := canIMethods[action]; ok {
+		check.Method = method
+	}
+	switch action {
+	case "exec":
+		if len(rest) < 1 {
+			log.Fatalln("Missing executable path")
+		}
+		check.Command = &rex.Command{Path: rest[0], Args: rest[1:]}
+		rest = nil
+	case "kill":
+		killFlags := flag.NewFlagSet("can-i kill", flag.ExitOnError)
+		signal := killFlags.Int("signal", int(syscall.SIGINT), "signal to send to the process")
+		if err := killFlags.Parse(rest); err != nil {
+			log.Fatalln(err.Error())
+		}
+		check.Signal = *signal
+		rest = killFlags.Args()
+	}
+	if len(rest) > 1 {
+		log.Fatalf("Too many arguments to can-i %s: got: %d, expected: %d", action, len(rest), 1)
+	} else if len(rest) == 1 {
+		processID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Error while parsing processUUID: %v", err)
+		}
+		check.ProcessID = processID
+	}
+
+	decision, err := checker.CheckAccess(ctx, check)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	answer := "no"
+	if decision.Allowed {
+		answer = "yes"
+	}
+	fmt.Printf("%s\nUser:\t%s\nGroups:\t%s\nRoles:\t%s\nReason:\t%s\n", answer,
+		decision.Identity.UserID, strings.Join(decision.Identity.Groups, ", "),
+		strings.Join(decision.Identity.Roles, ", "), decision.Reason)
+	for _, rule := range decision.Rules {
+		fmt.Printf("Rule:\t%s\n", rule)
+	}
+	if !decision.Allowed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// fileLocation is either a local path or a file in a workspace, written as
+// PROCESS_ID:PATH, or :PATH for the workspace of the caller
+type fileLocation struct {
+	remote bool
+	file   rex.FileRef
+	path   string
+}
+
+func parseFileLocation(arg string) fileLocation {
+	i := strings.Index(arg, ":")
+	if i < 0 {
+		return fileLocation{path: arg}
+	}
+	file := rex.FileRef{Path: arg[i+1:]}
+	if i > 0 {
+		processID, err := uuid.Parse(arg[:i])
+		if err != nil {
+			// A local path that happens to contain a colon
+			return fileLocation{path: arg}
+		}
+		file.ProcessID = processID
+	}
+	return fileLocation{remote: true, file: file}
+}
+
+func runCopyAction(ctx context.Context, store rex.FileStore, args []string) {
+	cpFlags := flag.NewFlagSet("cp", flag.ExitOnError)
+	resume := cpFlags.Bool("resume", false, "resume an interrupted transfer of the same file")
+	if err := cpFlags.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	args = cpFlags.Args()
+	if len(args) != 2 {
+		log.Fatalln("usage: cp [-resume] SRC DST, where either SRC or DST is PROCESS_ID:PATH or :PATH")
+	}
+
+	src, dst := parseFileLocation(args[0]), parseFileLocation(args[1])
+	var info rex.FileInfo
+	switch {
+	case !src.remote && dst.remote:
+		info = upload(ctx, store, src.path, dst.file, *resume)
+	case src.remote && !dst.remote:
+		info = download(ctx, store, src.file, dst.path, *resume)
+	default:
+		log.Fatalln("Exactly one of SRC and DST has to be in a workspace")
+	}
+	fmt.Printf("%s\t%d\t%s\n", info.Path, info.Size, info.SHA256)
+}
+
+// upload copies a local file to a workspace, along with its checksum which
+// the server verifies
+func upload(ctx context.Context, store rex.FileStore, localPath string,
+	file rex.FileRef, resume bool) rex.FileInfo {
+	f, err := os.Open(localPath)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	defer f.Close()
+	if file.Path == "" || strings.HasSuffix(file.Path, "/") {
+		file.Path += filepath.Base(localPath)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	var offset int64
+	if resume {
+		// A mismatching prefix is caught by the checksum of the whole file
+		if info, err := store.StatFile(ctx, file); err == nil && info.Size <= size {
+			offset = info.Size
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	info, err := store.Upload(ctx, file, offset, hex.EncodeToString(hash.Sum(nil)), f)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	return info
+}
+
+// download copies a file of a workspace to a local file, verifying its
+// checksum. The local file is removed if the checksum does not match.
+func download(ctx context.Context, store rex.FileStore, file rex.FileRef,
+	localPath string, resume bool) rex.FileInfo {
+	if stat, err := os.Stat(localPath); err == nil && stat.IsDir() {
+		localPath = filepath.Join(localPath, filepath.Base(file.Path))
+	}
+
+	hash := sha256.New()
+	var offset int64
+	if resume {
+		if f, err := os.Open(localPath); err == nil {
+			offset, err = io.Copy(hash, f)
+			f.Close()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+	}
+
+	info, content, err := store.Download(ctx, file, offset)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	defer content.Close()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(localPath, flags, 0644)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	defer f.Close()
+	if _, err := io.Copy(io.MultiWriter(f, hash), content); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != info.SHA256 {
+		f.Close()
+		os.Remove(localPath)
+		log.Fatalf("Checksum mismatch: expected %s, got %s", info.SHA256, checksum)
+	}
+	return info
+}
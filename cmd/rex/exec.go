@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/cmd/internal/pipeline"
+)
+
+// execFlags are the flags of exec, describing the command and the hosts to
+// execute it on
+type execFlags struct {
+	labels            labelsFlag
+	callbacks         variadicFlag
+	priority          int
+	restart           string
+	maxRestarts       int
+	backoff           time.Duration
+	maxBackoff        time.Duration
+	probes            probesFlag
+	probeInterval     time.Duration
+	probeTimeout      time.Duration
+	probeFailures     int
+	probeRestartAfter int
+	pipe              string
+	node              string
+	nodeSelector      labelsFlag
+	hosts             string
+	hostsFile         string
+	parallel          int
+	group             string
+	privateWorkDir    bool
+	dir               string
+	env               labelsFlag
+	artifacts         variadicFlag
+}
+
+// register defines the flags on fs
+func (f *execFlags) register(fs *flag.FlagSet) {
+	f.labels = labelsFlag{}
+	fs.Var(f.labels, "label", "key=value label to attach to the process. Can be passed multiple times.")
+	fs.Var(&f.callbacks, "callback", "URL to notify when the process exits. Can be passed multiple times.")
+	fs.IntVar(&f.priority, "priority", 0, "priority of the process if it has to be queued (higher starts first)")
+	fs.StringVar(&f.restart, "restart", "",
+		"supervise the process as a service, restarting it: never, on-failure or always")
+	fs.IntVar(&f.maxRestarts, "max-restarts", 0, "maximum number of restarts of a service (0 means no limit)")
+	fs.DurationVar(&f.backoff, "backoff", time.Second, "wait before the first restart of a service")
+	fs.DurationVar(&f.maxBackoff, "max-backoff", time.Minute, "maximum wait between the restarts of a service")
+	fs.Var(&f.probes, "probe",
+		"health check of the form tcp:PORT, http:PORT[/PATH] or exec:COMMAND. Can be passed multiple times.")
+	fs.DurationVar(&f.probeInterval, "probe-interval", 10*time.Second, "wait between the health checks")
+	fs.DurationVar(&f.probeTimeout, "probe-timeout", time.Second, "time limit of each health check")
+	fs.IntVar(&f.probeFailures, "probe-failures", 1, "consecutive failed checks after which the process is unhealthy")
+	fs.IntVar(&f.probeRestartAfter, "probe-restart-after", 0,
+		"consecutive failed checks after which a service is restarted (0 means never)")
+	fs.StringVar(&f.pipe, "pipe", "",
+		"pipeline of commands separated by | (e.g. 'grep foo | sort'), executed without a shell")
+	fs.StringVar(&f.node, "node", "", "name of the node to run the process on (rexproxy only)")
+	f.nodeSelector = labelsFlag{}
+	fs.Var(f.nodeSelector, "node-selector",
+		"key=value label that the node running the process must have (rexproxy only). Can be passed multiple times.")
+	fs.StringVar(&f.hosts, "hosts", "",
+		"comma separated list of host[:port] of rexd servers to run the process on, instead of -addr")
+	fs.StringVar(&f.hostsFile, "hosts-file", "", "file listing the hosts to run the process on, one per line")
+	fs.IntVar(&f.parallel, "parallel", 10, "maximum number of hosts contacted at the same time")
+	fs.StringVar(&f.group, "group", "", "ID of the group to add the process to, which is created if it does not exist")
+	fs.BoolVar(&f.privateWorkDir, "private-workdir", false,
+		"run the process in an empty working directory of its own, which is removed when it exits")
+	fs.StringVar(&f.dir, "dir", "", "absolute path to the working directory of the process")
+	f.env = labelsFlag{}
+	fs.Var(f.env, "env", "NAME=value environment variable to set for the process. Can be passed multiple times.")
+	fs.Var(&f.artifacts, "artifact", "glob of the files of the private working directory to keep "+
+		"in the workspace of the process. Implies -private-workdir. Can be passed multiple times.")
+}
+
+// command builds the command to execute from the flags and the remaining
+// arguments, which are the path and the arguments of the executable unless
+// -pipe is given
+func (f *execFlags) command(args []string) rex.Command {
+	var pipelineStages []rex.PipelineStage
+	if f.pipe != "" {
+		if len(args) > 0 {
+			log.Fatalln("Either -pipe or an executable path can be given, not both")
+		}
+		stages, err := pipeline.Parse(f.pipe)
+		if err != nil {
+			log.Fatalf("Bad pipeline %q: %v", f.pipe, err)
+		}
+		args = stages[0]
+		for _, stage := range stages[1:] {
+			pipelineStages = append(pipelineStages, rex.PipelineStage{Path: stage[0], Args: stage[1:]})
+		}
+	}
+	if len(args) < 1 {
+		log.Fatalln("Missing executable path")
+	}
+	command := rex.Command{
+		Path:      args[0],
+		Args:      args[1:],
+		Labels:    f.labels,
+		Callbacks: f.callbacks,
+		Priority:  f.priority,
+		Pipeline:  pipelineStages,
+		Node:      f.node,
+		Artifacts: f.artifacts,
+		Dir:       f.dir,
+	}
+	if len(f.env) > 0 {
+		command.Env = f.env
+	}
+	command.PrivateWorkDir = f.privateWorkDir || len(f.artifacts) > 0
+	if len(f.nodeSelector) > 0 {
+		command.NodeSelector = f.nodeSelector
+	}
+	if f.group != "" {
+		groupID, err := uuid.Parse(f.group)
+		if err != nil {
+			log.Fatalf("Bad group id %q: %v", f.group, err)
+		}
+		command.GroupID = groupID
+	}
+	for _, probe := range f.probes {
+		probe.Interval = f.probeInterval
+		probe.Timeout = f.probeTimeout
+		probe.FailureThreshold = f.probeFailures
+		probe.RestartAfter = f.probeRestartAfter
+		command.Probes = append(command.Probes, probe)
+	}
+	if f.restart != "" {
+		mode, err := rex.ParseRestartMode(f.restart)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		command.Restart = &rex.RestartPolicy{
+			Mode:        mode,
+			MaxRestarts: f.maxRestarts,
+			Backoff:     f.backoff,
+			MaxBackoff:  f.maxBackoff,
+		}
+	}
+	return command
+}
+
+func runExec(ctx context.Context, client rex.Service, args []string) {
+	var f execFlags
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	command := f.command(fs.Args())
+
+	if f.hosts != "" || f.hostsFile != "" {
+		fanOutExec(ctx, command, f.hosts, f.hostsFile, f.parallel)
+		return
+	}
+	processUUID, err := client.ExecCommand(ctx, command)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			log.Debugln("Got exec.ErrNotFound")
+		}
+		log.Fatalln(err.Error())
+	}
+	fmt.Println(processUUID)
+}
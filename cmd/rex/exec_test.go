@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/farnasirim/rex"
+)
+
+func TestExecFlags_Command(t *testing.T) {
+	var f execFlags
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	f.register(fs)
+	err := fs.Parse([]string{
+		"-label", "team=a", "-env", "A=1", "-artifact", "*.log", "-priority", "3",
+		"-restart", "on-failure", "-probe", "tcp:8080", "-probe-failures", "2",
+		"-pipe", "grep foo | sort -r",
+	})
+	if err != nil {
+		t.Fatalf("While parsing the flags: %v", err)
+	}
+	command := f.command(fs.Args())
+
+	expected := rex.Command{
+		Path:           "grep",
+		Args:           []string{"foo"},
+		Labels:         map[string]string{"team": "a"},
+		Env:            map[string]string{"A": "1"},
+		Artifacts:      []string{"*.log"},
+		PrivateWorkDir: true,
+		Priority:       3,
+		Pipeline:       []rex.PipelineStage{{Path: "sort", Args: []string{"-r"}}},
+		Probes: []rex.Probe{{Type: rex.ProbeTCP, Port: 8080, Interval: 10 * time.Second,
+			Timeout: time.Second, FailureThreshold: 2}},
+		Restart: &rex.RestartPolicy{Mode: rex.RestartOnFailure, Backoff: time.Second, MaxBackoff: time.Minute},
+	}
+	if !reflect.DeepEqual(command, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, command)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/cmd/internal/fanout"
+	"github.com/farnasirim/rex/cmd/internal/io"
+	rex_grpc "github.com/farnasirim/rex/grpc"
+)
+
+// fanOutExec executes command on each of the hosts, printing a line per
+// process for collect. Exits with a non-zero status if any of the hosts
+// fails to execute it.
+func fanOutExec(ctx context.Context, command rex.Command, hostList, hostsFile string, parallel int) {
+	var listed, read []string
+	var err error
+	if hostList != "" {
+		listed, err = fanout.ParseHosts(hostList)
+		if err != nil {
+			log.Fatalf("Bad -hosts: %v", err)
+		}
+	}
+	if hostsFile != "" {
+		read, err = fanout.ReadHosts(bytes.NewReader(io.ReadFileOrFatal(hostsFile)))
+		if err != nil {
+			log.Fatalf("Bad -hosts-file: %v", err)
+		}
+	}
+	hosts, err := fanout.Merge(listed, read)
+	if err != nil {
+		log.Fatalf("Bad -hosts and -hosts-file: %v", err)
+	}
+
+	targets := make([]fanout.Target, len(hosts))
+	errs := fanout.Run(len(hosts), parallel, func(i int) error {
+		conn := dial(hosts[i])
+		defer conn.Close()
+		processID, err := rex_grpc.NewClient(conn).ExecCommand(ctx, command)
+		if err != nil {
+			return err
+		}
+		targets[i] = fanout.Target{Host: hosts[i], ProcessID: processID}
+		return nil
+	})
+
+	failed := false
+	for i, err := range errs {
+		if err != nil {
+			log.Errorf("%s: %v", hosts[i], err)
+			failed = true
+			continue
+		}
+		fmt.Println(targets[i])
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runCollect(ctx context.Context, args []string) {
+	collectFlags := flag.NewFlagSet("collect", flag.ExitOnError)
+	parallel := collectFlags.Int("parallel", 10, "maximum number of hosts contacted at the same time")
+	if err := collectFlags.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	args = collectFlags.Args()
+	if len(args) != 1 {
+		log.Fatalln("usage: collect [-parallel N] FILE (- for stdin), as written by exec -hosts")
+	}
+	collect(ctx, args[0], *parallel)
+}
+
+// collect waits for the processes listed in file by fanOutExec, printing
+// their output prefixed by their hosts as they exit, followed by a summary.
+// Exits with a non-zero status unless all of them have exited with a zero
+// exit code.
+func collect(ctx context.Context, file string, parallel int) {
+	var content []byte
+	var err error
+	if file == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	targets, err := fanout.ReadTargets(bytes.NewReader(content))
+	if err != nil {
+		log.Fatalf("Malformed input: %v", err)
+	}
+	if len(targets) == 0 {
+		log.Fatalln("No processes to collect")
+	}
+
+	results := make([]rex.ProcessInfo, len(targets))
+	var printing sync.Mutex
+	errs := fanout.Run(len(targets), parallel, func(i int) error {
+		target := targets[i]
+		conn := dial(target.Host)
+		defer conn.Close()
+		client := rex_grpc.NewClient(conn)
+
+		info, err := fanout.WaitExit(ctx, client, target.ProcessID)
+		if err != nil {
+			return err
+		}
+		results[i] = info
+		stdout, err := client.Read(ctx, target.ProcessID, rex.StdoutStream)
+		if err != nil {
+			return err
+		}
+		stderr, err := client.Read(ctx, target.ProcessID, rex.StderrStream)
+		if err != nil {
+			return err
+		}
+
+		printing.Lock()
+		defer printing.Unlock()
+		prefix := target.Host + ": "
+		if err := fanout.PrefixLines(os.Stdout, prefix, stdout); err != nil {
+			return err
+		}
+		return fanout.PrefixLines(os.Stderr, prefix, stderr)
+	})
+
+	failed := false
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Host", "Process", "Result"})
+	for i, target := range targets {
+		result := ""
+		switch {
+		case errs[i] != nil:
+			result = errs[i].Error()
+		case results[i].State == rex.ProcessExited:
+			result = fmt.Sprintf("exit code %d", results[i].ExitCode)
+		default:
+			result = results[i].State.String()
+		}
+		failed = failed || errs[i] != nil || results[i].State != rex.ProcessExited || results[i].ExitCode != 0
+		table.Append([]string{target.Host, target.ProcessID.String(), result})
+	}
+	table.Render()
+	if failed {
+		os.Exit(1)
+	}
+}
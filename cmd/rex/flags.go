@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/farnasirim/rex"
+)
+
+type variadicFlag []string
+
+func (f *variadicFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f *variadicFlag) String() string {
+	return ""
+}
+
+// labelsFlag collects repeated key=value flags into a map
+type labelsFlag map[string]string
+
+func (f labelsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+func (f labelsFlag) String() string {
+	return ""
+}
+
+// probesFlag collects repeated probe flags of the forms tcp:PORT,
+// http:PORT[/PATH] and exec:COMMAND [ARGS...]
+type probesFlag []rex.Probe
+
+func (f *probesFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("expected TYPE:TARGET, got %q", value)
+	}
+	probeType, err := rex.ParseProbeType(parts[0])
+	if err != nil {
+		return err
+	}
+	probe := rex.Probe{Type: probeType}
+	switch probeType {
+	case rex.ProbeExec:
+		probe.Command = strings.Fields(parts[1])
+	case rex.ProbeTCP, rex.ProbeHTTP:
+		port := parts[1]
+		if i := strings.Index(port, "/"); i >= 0 && probeType == rex.ProbeHTTP {
+			port, probe.Path = port[:i], port[i:]
+		}
+		if probe.Port, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("invalid port in %q", value)
+		}
+	}
+	*f = append(*f, probe)
+	return nil
+}
+
+func (f *probesFlag) String() string {
+	return ""
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+func runGroupAction(ctx context.Context, manager rex.GroupManager, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("missing group action (get, list, kill, wait or delete)")
+	}
+	action, rest := args[0], args[1:]
+
+	switch action {
+	case "list":
+		groups, err := manager.ListGroups(ctx)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "Processes", "Queued", "Running", "Succeeded", "Failed", "State"})
+		for _, info := range groups {
+			table.Append([]string{info.ID.String(), fmt.Sprint(len(info.Processes)),
+				fmt.Sprint(info.Queued), fmt.Sprint(info.Running),
+				fmt.Sprint(info.Succeeded), fmt.Sprint(info.Failed), info.State.String()})
+		}
+		table.Render()
+
+	case "get", "delete":
+		if len(rest) != 1 {
+			log.Fatalf("usage: group %s GROUP_ID", action)
+		}
+		groupID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		}
+		if action == "delete" {
+			if err := manager.DeleteGroup(ctx, groupID); err != nil {
+				log.Fatalln(err.Error())
+			}
+			return
+		}
+		info, err := manager.GetGroupInfo(ctx, groupID)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		printGroup(info)
+
+	case "kill":
+		killFlags := flag.NewFlagSet("group kill", flag.ExitOnError)
+		signal := killFlags.Int("signal", int(syscall.SIGINT), "signal to send to the processes")
+		if err := killFlags.Parse(rest); err != nil {
+			log.Fatalln(err.Error())
+		}
+		rest = killFlags.Args()
+		if len(rest) != 1 {
+			log.Fatalln("usage: group kill [-signal N] GROUP_ID")
+		}
+		groupID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		}
+		if err := manager.KillGroup(ctx, groupID, *signal); err != nil {
+			log.Fatalln(err.Error())
+		}
+
+	case "wait":
+		waitFlags := flag.NewFlagSet("group wait", flag.ExitOnError)
+		mode := waitFlags.String("for", "all", "wait for all or any of the processes to exit")
+		waitTimeout := waitFlags.Duration("timeout", 0, "give up waiting after this long (0 means no limit)")
+		if err := waitFlags.Parse(rest); err != nil {
+			log.Fatalln(err.Error())
+		}
+		rest = waitFlags.Args()
+		if len(rest) != 1 {
+			log.Fatalln("usage: group wait [-for all|any] [-timeout DURATION] GROUP_ID")
+		}
+		groupID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		}
+		waitMode, err := rex.ParseGroupWaitMode(*mode)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if *waitTimeout > 0 {
+			var cancelFunc context.CancelFunc
+			ctx, cancelFunc = context.WithTimeout(ctx, *waitTimeout)
+			defer cancelFunc()
+		}
+		info, err := manager.WaitGroup(ctx, groupID, waitMode)
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Fatalf("Timed out waiting for %s of the processes of group %s", waitMode, groupID)
+		} else if err != nil {
+			log.Fatalln(err.Error())
+		}
+		printGroup(info)
+		if info.State == rex.GroupFailed {
+			os.Exit(1)
+		}
+
+	default:
+		log.Fatalf("Invalid group action: %q", action)
+	}
+}
+
+// printGroup prints the aggregate status of a group along with a table of
+// its processes
+func printGroup(info rex.GroupInfo) {
+	fmt.Printf("Group %s is %s: %d queued, %d running, %d succeeded, %d failed\n",
+		info.ID, info.State, info.Queued, info.Running, info.Succeeded, info.Failed)
+	now := time.Now().UTC()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Command", "Created", "State"})
+	for _, p := range info.Processes {
+		state := p.State.String()
+		if p.State == rex.ProcessExited {
+			state = fmt.Sprintf("Exited with code %d (%s ago)",
+				p.ExitCode, now.Sub(p.Exit).Round(time.Second).String())
+		}
+		table.Append([]string{p.ID.String(), strings.Join(append([]string{p.Path}, p.Args...), " "),
+			now.Sub(p.Create).Round(time.Second).String(), state})
+	}
+	table.Render()
+}
@@ -4,20 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"errors"
 	"flag"
-	"fmt"
-	"os"
-	"os/exec"
-	"syscall"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"gopkg.in/yaml.v2"
-
-	"github.com/google/uuid"
-	"github.com/kataras/tablewriter"
 
 	log "github.com/sirupsen/logrus"
 
@@ -35,20 +27,36 @@ var (
 	pathToCert   string
 	pathToKey    string
 	serverAddr   string
+	pickStrategy string
 	timeout      int
 )
 
 func main() {
 	log.SetLevel(log.DebugLevel)
 	parseAndValidate()
-	conn := getGRPCConnection()
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Fatalln(err.Error())
-		}
-	}()
+	var replicas []rex_grpc.Replica
+	for _, addr := range strings.Split(serverAddr, ",") {
+		conn := dial(addr)
+		defer func() {
+			if err := conn.Close(); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}()
+		replicas = append(replicas, rex_grpc.Replica{Address: addr, Conn: conn})
+	}
+	strategy, err := rex_grpc.ParsePickStrategy(pickStrategy)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	replicaClient, err := rex_grpc.NewReplicaClient(strategy, replicas...)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
 
-	var client rex.Service = rex_grpc.NewClient(conn)
+	var client rex.Service = replicaClient
+	if len(replicas) == 1 {
+		client = rex_grpc.NewClient(replicas[0].Conn)
+	}
 
 	if flag.NArg() < 1 {
 		log.Fatalln("missing action")
@@ -66,118 +74,113 @@ func main() {
 
 	switch action {
 	case "exec":
-		if len(rest) < 1 {
-			log.Fatalln("Missing executable path")
-		}
-		processUUID, err := client.Exec(ctx, rest[0], rest[1:]...)
-		if err != nil {
-			if errors.Is(err, exec.ErrNotFound) {
-				log.Debugln("Got exec.ErrNotFound")
-			}
-			log.Fatalln(err.Error())
-		}
-		fmt.Println(processUUID)
+		runExec(ctx, client, rest)
 	case "kill":
-		if len(rest) < 1 {
-			log.Fatalln("Missing process id")
-		} else if len(rest) > 1 {
-			log.Fatalf("Too many arguments to kill: got: %d, expected: %d", len(rest), 1)
-		}
+		runKill(ctx, client, rest)
+	case "delete":
+		runDelete(ctx, client, rest)
+	case "ps":
+		runList(ctx, client, rest)
+	case "get":
+		runGet(ctx, client, rest)
+	case "read":
+		runRead(ctx, client, rest)
 
-		processID, err := uuid.Parse(rest[0])
-		if err != nil {
-			log.Fatalf("Error while parsing processUUID: %v", err)
+	case "events":
+		watcher, ok := client.(rex.Watcher)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
+		runEvents(ctx, watcher, rest)
 
-		// only supports sigint for now
-		err = client.Kill(ctx, processID, int(syscall.SIGINT))
-		if err != nil {
-			log.Fatalln(err.Error())
+	case "wait":
+		waiter, ok := client.(rex.OutputWaiter)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-	case "ps":
-		if len(rest) > 0 {
-			log.Warnf("Ignoring %d extra arguments to %q", len(rest), "ps")
-		}
-		processes, err := client.ListProcessInfo(ctx)
-		if err != nil {
-			log.Fatalln(err.Error())
-		}
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"ID", "Owner ID", "Created", "State"})
-		now := time.Now().UTC()
-		for _, p := range processes {
-			var row []string
-			row = append(row, p.ID.String())
-			row = append(row, p.OwnerID.String())
-			row = append(row, now.Sub(p.Create).Round(time.Second).String())
-			state := "running"
-			if !p.Exit.IsZero() {
-				state = fmt.Sprintf("Exited with code %d (%s ago)",
-					p.ExitCode, now.Sub(p.Exit).Round(time.Second).String())
-			}
-			row = append(row, state)
-			table.Append(row)
-		}
-		table.Render()
-	case "get":
-		if len(rest) < 1 {
-			log.Fatalln("Missing processID argument")
-		} else if len(rest) > 1 {
-			log.Fatalf("Too many arguments to get: got: %d, expected: %d", len(rest), 1)
-		}
-		processUUID, err := uuid.Parse(rest[0])
-		if err != nil {
-			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		runWait(ctx, waiter, rest)
+
+	case "service":
+		supervisor, ok := client.(rex.Supervisor)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		procInfo, err := client.GetProcessInfo(ctx, processUUID)
-		if err != nil {
-			log.Fatalln(err.Error())
+		runService(ctx, supervisor, rest)
+
+	case "schedule":
+		scheduler, ok := client.(rex.Scheduler)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		output, err := yaml.Marshal(procInfo)
-		if err != nil {
-			log.Fatalf("Error while presenting results: %v", err)
+		runScheduleAction(ctx, scheduler, rest)
+
+	case "workflow":
+		runner, ok := client.(rex.WorkflowRunner)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		fmt.Print(string(output))
+		runWorkflowAction(ctx, runner, rest)
 
-	case "read":
-		if len(rest) < 1 {
-			log.Fatalln("Missing process id")
-		} else if len(rest) == 1 {
-			log.Fatalln("Missing target stream (stdout/stderr)")
-		} else if len(rest) > 2 {
-			log.Fatalf("Too many arguments: got: %d, expected: %d", len(rest), 2)
+	case "group":
+		manager, ok := client.(rex.GroupManager)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		processID, err := uuid.Parse(rest[0])
-		if err != nil {
-			log.Fatalf("Error while parsing processUUID: %v", err)
+		runGroupAction(ctx, manager, rest)
+
+	case "cp":
+		store, ok := client.(rex.FileStore)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
+		runCopyAction(ctx, store, rest)
+
+	case "replicas":
+		runReplicas(ctx, replicaClient, rest)
 
-		var targetStream rex.OutputStream
-		if rest[1] != "stdout" && rest[1] != "stderr" {
-			log.Fatalf("Target stream must be either %q or %q", "stdout", "stderr")
+	case "collect":
+		runCollect(ctx, rest)
+
+	case "quota":
+		reporter, ok := client.(rex.QuotaReporter)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		if rest[1] == "stdout" {
-			targetStream = rex.StdoutStream
-		} else if rest[1] == "stderr" {
-			targetStream = rex.StderrStream
+		runQuota(ctx, reporter, rest)
+
+	case "whoami":
+		reporter, ok := client.(rex.IdentityReporter)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
+		runWhoAmI(ctx, reporter, rest)
 
-		content, err := client.Read(ctx, processID, targetStream)
-		if err != nil {
-			log.Fatalln(err.Error())
+	case "can-i":
+		checker, ok := client.(rex.AccessChecker)
+		if !ok {
+			unsupportedWithReplicas(action)
 		}
-		fmt.Print(string(content))
+		runCanI(ctx, checker, rest)
 
 	default:
 		log.Fatalf("Invalid action: %q", action)
 	}
 }
 
+// unsupportedWithReplicas fails an action which concerns the state of a
+// single server when several addresses are given
+func unsupportedWithReplicas(action string) {
+	log.Fatalf("%q is not supported with several -addr replicas", action)
+}
+
 func parseAndValidate() {
 	flag.StringVar(&pathToCACert, "ca", "", "path to ca certificate in pem format")
 	flag.StringVar(&pathToCert, "cert", "", "path to server certificate in pem format")
 	flag.StringVar(&pathToKey, "key", "", "path to server private key in pem format")
-	flag.StringVar(&serverAddr, "addr", "localhost:9090", "server address of form [ip]:port")
+	flag.StringVar(&serverAddr, "addr", "localhost:9090",
+		"server address of form [ip]:port, or a comma separated list of equivalent replicas")
+	flag.StringVar(&pickStrategy, "pick", rex_grpc.PickRoundRobin.String(),
+		"how to pick one of the replicas for a new process: round-robin or least-loaded")
 	flag.IntVar(&timeout, "timeout", -1, "time limit of the exection of the command (milliseconds)")
 
 	flag.Parse()
@@ -196,7 +199,9 @@ func parseAndValidate() {
 
 }
 
-func getGRPCConnection() *grpc.ClientConn {
+// dial connects to the rexd at addr. The connection is established lazily,
+// upon the first call.
+func dial(addr string) *grpc.ClientConn {
 	caPool := x509.NewCertPool()
 	if ok := caPool.AppendCertsFromPEM(io.ReadFileOrFatal(pathToCACert)); !ok {
 		log.Fatalln("CA cert malformed")
@@ -215,9 +220,10 @@ func getGRPCConnection() *grpc.ClientConn {
 	}
 
 	tlsCredentials := credentials.NewTLS(config)
-	conn, err := grpc.Dial(serverAddr,
+	conn, err := grpc.Dial(addr,
 		grpc.WithTransportCredentials(tlsCredentials),
 		grpc.WithUnaryInterceptor(rex_grpc.ErrorUnmarshallerInterceptor),
+		grpc.WithStreamInterceptor(rex_grpc.ErrorUnmarshallerStreamInterceptor),
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
 	)
 	if err != nil {
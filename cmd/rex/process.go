@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+func runKill(ctx context.Context, client rex.Service, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing process id")
+	} else if len(args) > 1 {
+		log.Fatalf("Too many arguments to kill: got: %d, expected: %d", len(args), 1)
+	}
+
+	processID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Error while parsing processUUID: %v", err)
+	}
+
+	// only supports sigint for now
+	err = client.Kill(ctx, processID, int(syscall.SIGINT))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+func runDelete(ctx context.Context, client rex.Service, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing process id")
+	} else if len(args) > 1 {
+		log.Fatalf("Too many arguments to delete: got: %d, expected: %d", len(args), 1)
+	}
+
+	processID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Error while parsing processUUID: %v", err)
+	}
+
+	if err := client.Delete(ctx, processID); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+func runList(ctx context.Context, client rex.Service, args []string) {
+	if len(args) > 0 {
+		log.Warnf("Ignoring %d extra arguments to %q", len(args), "ps")
+	}
+	processes, err := client.ListProcessInfo(ctx)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	// Processes only have nodes when listed through rexproxy
+	withNodes := false
+	for _, p := range processes {
+		withNodes = withNodes || p.Node != ""
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"ID", "Owner ID", "Created", "State"}
+	if withNodes {
+		header = append(header, "Node")
+	}
+	table.SetHeader(header)
+	now := time.Now().UTC()
+	for _, p := range processes {
+		var row []string
+		row = append(row, p.ID.String())
+		row = append(row, p.OwnerID.String())
+		row = append(row, now.Sub(p.Create).Round(time.Second).String())
+		state := "running"
+		if p.State == rex.ProcessQueued {
+			state = fmt.Sprintf("queued (#%d)", p.QueuePosition)
+		} else if p.State == rex.ProcessCanceled || p.State == rex.ProcessFailed {
+			state = fmt.Sprintf("%s (%s ago)", p.State, now.Sub(p.Exit).Round(time.Second).String())
+		} else if !p.Exit.IsZero() {
+			state = fmt.Sprintf("Exited with code %d (%s ago)",
+				p.ExitCode, now.Sub(p.Exit).Round(time.Second).String())
+		}
+		row = append(row, state)
+		if withNodes {
+			row = append(row, p.Node)
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func runGet(ctx context.Context, client rex.Service, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing processID argument")
+	} else if len(args) > 1 {
+		log.Fatalf("Too many arguments to get: got: %d, expected: %d", len(args), 1)
+	}
+	processUUID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Bad argument %q: %v", args[0], err)
+	}
+	procInfo, err := client.GetProcessInfo(ctx, processUUID)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	output, err := yaml.Marshal(procInfo)
+	if err != nil {
+		log.Fatalf("Error while presenting results: %v", err)
+	}
+	fmt.Print(string(output))
+}
+
+func runRead(ctx context.Context, client rex.Service, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing process id")
+	} else if len(args) == 1 {
+		log.Fatalln("Missing target stream (stdout/stderr)")
+	} else if len(args) > 2 {
+		log.Fatalf("Too many arguments: got: %d, expected: %d", len(args), 2)
+	}
+	processID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Error while parsing processUUID: %v", err)
+	}
+
+	var targetStream rex.OutputStream
+	if args[1] != "stdout" && args[1] != "stderr" {
+		log.Fatalf("Target stream must be either %q or %q", "stdout", "stderr")
+	}
+	if args[1] == "stdout" {
+		targetStream = rex.StdoutStream
+	} else if args[1] == "stderr" {
+		targetStream = rex.StderrStream
+	}
+
+	content, err := client.Read(ctx, processID, targetStream)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	fmt.Print(string(content))
+}
+
+func runEvents(ctx context.Context, watcher rex.Watcher, args []string) {
+	labels := labelsFlag{}
+	var owner string
+	var since uint64
+	eventsFlags := flag.NewFlagSet("events", flag.ExitOnError)
+	eventsFlags.Var(labels, "label", "only show the events of processes with this key=value label. Can be passed multiple times.")
+	eventsFlags.StringVar(&owner, "owner", "", "only show the events of processes owned by this user")
+	eventsFlags.Uint64Var(&since, "since", 0, "only show the events after this sequence number")
+	if err := eventsFlags.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if eventsFlags.NArg() > 0 {
+		log.Warnf("Ignoring %d extra arguments to %q", eventsFlags.NArg(), "events")
+	}
+
+	filter := rex.EventFilter{Labels: labels, AfterSeq: since}
+	if owner != "" {
+		ownerID, err := uuid.Parse(owner)
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", owner, err)
+		}
+		filter.OwnerID = ownerID
+	}
+
+	events, err := watcher.Watch(ctx, filter)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	for event := range events {
+		details := ""
+		if event.Type == rex.EventExited {
+			details = fmt.Sprintf("exit code %d", event.Process.ExitCode)
+		} else if event.Type == rex.EventSignaled {
+			details = fmt.Sprintf("signal %d", event.Signal)
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", event.Seq,
+			event.Time.Format(time.RFC3339), event.Type, event.Process.ID,
+			event.Process.Path, details)
+	}
+}
+
+func runWait(ctx context.Context, waiter rex.OutputWaiter, args []string) {
+	waitFlags := flag.NewFlagSet("wait", flag.ExitOnError)
+	pattern := waitFlags.String("for-output", "", "regular expression to wait for in the output of the process")
+	stream := waitFlags.String("stream", "stdout", "output to wait on (stdout/stderr)")
+	waitTimeout := waitFlags.Duration("timeout", 0, "give up waiting after this long (0 means no limit)")
+	if err := waitFlags.Parse(args); err != nil {
+		log.Fatalln(err.Error())
+	}
+	args = waitFlags.Args()
+	if len(args) < 1 {
+		log.Fatalln("Missing processID argument")
+	} else if len(args) > 1 {
+		log.Fatalf("Too many arguments to wait: got: %d, expected: %d", len(args), 1)
+	}
+	if *pattern == "" {
+		log.Fatalln("Missing -for-output pattern")
+	}
+	processUUID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Bad argument %q: %v", args[0], err)
+	}
+	var targetStream rex.OutputStream
+	switch *stream {
+	case "stdout":
+		targetStream = rex.StdoutStream
+	case "stderr":
+		targetStream = rex.StderrStream
+	default:
+		log.Fatalf("Target stream must be either %q or %q", "stdout", "stderr")
+	}
+
+	if *waitTimeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, *waitTimeout)
+		defer cancelFunc()
+	}
+	match, err := waiter.WaitFor(ctx, processUUID, targetStream, *pattern)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Fatalf("Timed out waiting for %q", *pattern)
+	} else if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if !match.Matched {
+		log.Fatalf("Process is %v without printing %q", match.Process.State, *pattern)
+	}
+	fmt.Printf("%d: %s\n", match.Offset, match.Line)
+}
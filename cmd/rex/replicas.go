@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	rex_grpc "github.com/farnasirim/rex/grpc"
+)
+
+func runReplicas(ctx context.Context, replicaClient *rex_grpc.ReplicaClient, args []string) {
+	if len(args) > 0 {
+		log.Warnf("Ignoring %d extra arguments to %q", len(args), "replicas")
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Address", "Status", "Load"})
+	down := false
+	for _, status := range replicaClient.CheckReplicas(ctx) {
+		if status.Err != nil {
+			down = true
+			table.Append([]string{status.Address, status.Err.Error(), "-"})
+			continue
+		}
+		table.Append([]string{status.Address, "up", fmt.Sprint(status.Load)})
+	}
+	table.Render()
+	if down {
+		os.Exit(1)
+	}
+}
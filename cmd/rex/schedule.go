@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+func runScheduleAction(ctx context.Context, scheduler rex.Scheduler, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("missing schedule action (list, create, delete, pause or resume)")
+	}
+	action, rest := args[0], args[1:]
+
+	switch action {
+	case "list":
+		schedules, err := scheduler.ListSchedules(ctx)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "Cron", "Command", "Next run", "Last process", "State"})
+		for _, sc := range schedules {
+			cron := sc.Cron
+			if sc.TimeZone != "" {
+				cron = fmt.Sprintf("%s (%s)", sc.Cron, sc.TimeZone)
+			}
+			lastProcess := ""
+			if sc.LastProcessID != uuid.Nil {
+				lastProcess = sc.LastProcessID.String()
+			}
+			state := "active"
+			if sc.Paused {
+				state = "paused"
+			} else if sc.LastError != "" {
+				state = "last run failed: " + sc.LastError
+			}
+			table.Append([]string{sc.ID.String(), cron,
+				strings.Join(append([]string{sc.Command.Path}, sc.Command.Args...), " "),
+				sc.NextRun.Format(time.RFC3339), lastProcess, state})
+		}
+		table.Render()
+
+	case "create":
+		labels := labelsFlag{}
+		var callbacks variadicFlag
+		createFlags := flag.NewFlagSet("schedule create", flag.ExitOnError)
+		createFlags.Var(labels, "label", "key=value label to attach to the processes. Can be passed multiple times.")
+		createFlags.Var(&callbacks, "callback", "URL to notify when a process exits. Can be passed multiple times.")
+		priority := createFlags.Int("priority", 0, "priority of the processes if they have to be queued")
+		timeZone := createFlags.String("tz", "", "IANA time zone of the cron expression (default UTC)")
+		overlap := createFlags.String("overlap", "allow",
+			"what to do if the previous process is still running: allow, skip or replace")
+		paused := createFlags.Bool("paused", false, "create the schedule paused")
+		if err := createFlags.Parse(rest); err != nil {
+			log.Fatalln(err.Error())
+		}
+		rest = createFlags.Args()
+
+		if len(rest) < 2 {
+			log.Fatalln("Expected a cron expression followed by the executable path")
+		}
+		overlapPolicy, err := rex.ParseOverlapPolicy(*overlap)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		sc, err := scheduler.CreateSchedule(ctx, rex.Schedule{
+			Cron:     rest[0],
+			TimeZone: *timeZone,
+			Command: rex.Command{
+				Path:      rest[1],
+				Args:      rest[2:],
+				Labels:    labels,
+				Callbacks: callbacks,
+				Priority:  *priority,
+			},
+			Overlap: overlapPolicy,
+			Paused:  *paused,
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		fmt.Println(sc.ID)
+
+	case "delete", "pause", "resume":
+		if len(rest) != 1 {
+			log.Fatalf("Expected exactly one schedule id, got %d arguments", len(rest))
+		}
+		scheduleID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		}
+		if action == "delete" {
+			err = scheduler.DeleteSchedule(ctx, scheduleID)
+		} else {
+			_, err = scheduler.PauseSchedule(ctx, scheduleID, action == "pause")
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+	default:
+		log.Fatalf("Invalid schedule action: %q", action)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+func runService(ctx context.Context, supervisor rex.Supervisor, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("Missing serviceID argument")
+	} else if len(args) > 1 {
+		log.Fatalf("Too many arguments to service: got: %d, expected: %d", len(args), 1)
+	}
+	serviceUUID, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Bad argument %q: %v", args[0], err)
+	}
+	info, err := supervisor.GetServiceInfo(ctx, serviceUUID)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	now := time.Now().UTC()
+	fmt.Printf("Service %s is %s after %d restarts", info.ID, info.State, info.Restarts)
+	if info.State == rex.ServiceRestarting {
+		fmt.Printf(", restarting in %s", info.NextRestart.Sub(now).Round(time.Second))
+	}
+	fmt.Println()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"#", "ID", "Created", "State"})
+	for _, p := range info.Incarnations {
+		state := p.State.String()
+		if p.State == rex.ProcessExited {
+			state = fmt.Sprintf("Exited with code %d (%s ago)",
+				p.ExitCode, now.Sub(p.Exit).Round(time.Second).String())
+		}
+		table.Append([]string{fmt.Sprint(p.Incarnation), p.ID.String(),
+			now.Sub(p.Create).Round(time.Second).String(), state})
+	}
+	table.Render()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/google/uuid"
+	"github.com/kataras/tablewriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// workflowSpec is the format of the files accepted by "rex workflow submit"
+type workflowSpec struct {
+	Steps []struct {
+		Name      string            `yaml:"name"`
+		Command   []string          `yaml:"command"`
+		DependsOn []string          `yaml:"depends_on"`
+		Condition string            `yaml:"condition"`
+		Labels    map[string]string `yaml:"labels"`
+		Priority  int               `yaml:"priority"`
+	} `yaml:"steps"`
+}
+
+func runWorkflowAction(ctx context.Context, runner rex.WorkflowRunner, args []string) {
+	if len(args) < 1 {
+		log.Fatalln("missing workflow action (submit, get, list or cancel)")
+	}
+	action, rest := args[0], args[1:]
+
+	switch action {
+	case "submit":
+		if len(rest) != 1 {
+			log.Fatalln("usage: workflow submit FILE (- for stdin)")
+		}
+		var content []byte
+		var err error
+		if rest[0] == "-" {
+			content, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			content, err = ioutil.ReadFile(rest[0])
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		var spec workflowSpec
+		if err := yaml.UnmarshalStrict(content, &spec); err != nil {
+			log.Fatalf("Malformed workflow file: %v", err)
+		}
+
+		var workflow rex.Workflow
+		for _, stepSpec := range spec.Steps {
+			if len(stepSpec.Command) == 0 {
+				log.Fatalf("Step %q has no command", stepSpec.Name)
+			}
+			step := rex.WorkflowStep{
+				Name: stepSpec.Name,
+				Command: rex.Command{
+					Path:     stepSpec.Command[0],
+					Args:     stepSpec.Command[1:],
+					Labels:   stepSpec.Labels,
+					Priority: stepSpec.Priority,
+				},
+				DependsOn: stepSpec.DependsOn,
+			}
+			if stepSpec.Condition != "" {
+				if step.Condition, err = rex.ParseStepCondition(stepSpec.Condition); err != nil {
+					log.Fatalf("Step %q: %v", stepSpec.Name, err)
+				}
+			}
+			workflow.Steps = append(workflow.Steps, step)
+		}
+		workflow, err = runner.SubmitWorkflow(ctx, workflow)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		fmt.Println(workflow.ID)
+
+	case "get", "cancel":
+		if len(rest) != 1 {
+			log.Fatalf("usage: workflow %s WORKFLOW_ID", action)
+		}
+		workflowID, err := uuid.Parse(rest[0])
+		if err != nil {
+			log.Fatalf("Bad argument %q: %v", rest[0], err)
+		}
+		var workflow rex.Workflow
+		if action == "get" {
+			workflow, err = runner.GetWorkflow(ctx, workflowID)
+		} else {
+			workflow, err = runner.CancelWorkflow(ctx, workflowID)
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		fmt.Printf("Workflow %s is %s\n", workflow.ID, workflow.State)
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Step", "Depends on", "Condition", "State", "Process", "Result"})
+		for _, step := range workflow.Steps {
+			process, result := "", step.Error
+			if step.ProcessID != uuid.Nil {
+				process = step.ProcessID.String()
+			}
+			if result == "" && (step.State == rex.StepSucceeded || step.State == rex.StepFailed) {
+				result = fmt.Sprintf("exit code %d", step.ExitCode)
+			}
+			table.Append([]string{step.Name, strings.Join(step.DependsOn, ", "),
+				step.Condition.String(), step.State.String(), process, result})
+		}
+		table.Render()
+
+	case "list":
+		workflows, err := runner.ListWorkflows(ctx)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		now := time.Now().UTC()
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "Submitted", "Steps", "State"})
+		for _, workflow := range workflows {
+			finished := 0
+			for _, step := range workflow.Steps {
+				if step.State != rex.StepPending && step.State != rex.StepRunning {
+					finished++
+				}
+			}
+			table.Append([]string{workflow.ID.String(),
+				now.Sub(workflow.Create).Round(time.Second).String() + " ago",
+				fmt.Sprintf("%d/%d finished", finished, len(workflow.Steps)),
+				workflow.State.String()})
+		}
+		table.Render()
+
+	default:
+		log.Fatalf("Invalid workflow action: %q", action)
+	}
+}
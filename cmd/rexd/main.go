@@ -1,74 +1,112 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"bytes"
+	"context"
 	"flag"
 	"net"
 	"os"
 	"path"
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-
 	log "github.com/sirupsen/logrus"
 
+	"github.com/farnasirim/rex"
 	"github.com/farnasirim/rex/cmd/internal/io"
+	"github.com/farnasirim/rex/cmd/internal/server"
 	rex_grpc "github.com/farnasirim/rex/grpc"
 	"github.com/farnasirim/rex/localexec"
 	"github.com/farnasirim/rex/proto"
+	"github.com/farnasirim/rex/schedule"
+	"github.com/farnasirim/rex/webhook"
+	"github.com/farnasirim/rex/workflow"
 )
 
-type variadicFlag []string
-
-func (f *variadicFlag) Set(value string) error {
-	*f = append(*f, value)
-	return nil
-}
-
-func (f *variadicFlag) String() string {
-	return ""
+// service combines the optional capabilities of rexd with its
+// rex.Service implementation.
+type service struct {
+	*localexec.ProcessServer
+	*schedule.Scheduler
+	*workflow.Runner
 }
 
 var (
-	policyFlags  variadicFlag
-	pathToCACert string
-	pathToCert   string
-	pathToKey    string
-	dataDirFlag  string
-	serveAddr    string
+	serverFlags    server.Flags
+	trustedProxies server.VariadicFlag
+	dataDirFlag    string
+	ownerPolicy    bool
+
+	maxConcurrentFlag             int
+	maxConcurrentPerPrincipalFlag int
+	quotaFlags                    server.VariadicFlag
+	maxFileSizeFlag               int64
+
+	webhookFlags        server.VariadicFlag
+	webhookAllowFlags   server.VariadicFlag
+	pathToWebhookSecret string
+	webhookAttemptsFlag int
+	webhookTailSizeFlag int
 )
 
 func main() {
 	log.SetLevel(log.DebugLevel)
 	parseAndValidate()
 
-	var policies []rex_grpc.Policy
-	for _, fl := range policyFlags {
-		x, err := rex_grpc.SimpleAccessRuleFromJSON([]byte(fl))
+	policies := serverFlags.Policies()
+	if ownerPolicy {
+		policies = append(policies, rex_grpc.OwnerAccessRule())
+	}
+
+	var quotas []localexec.QuotaRule
+	for _, fl := range quotaFlags {
+		rule, err := localexec.QuotaRuleFromJSON([]byte(fl))
 		if err != nil {
-			log.Fatalf("Policy argument malformed: %v", err)
+			log.Fatalf("Quota argument malformed: %v", err)
 		}
-		policies = append(policies, x)
+		quotas = append(quotas, *rule)
 	}
 
-	lis, err := net.Listen("tcp", serveAddr)
+	lis, err := net.Listen("tcp", serverFlags.ServeAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	tlsCredentials := getTLSCredentials()
-	policyEnforcer := rex_grpc.NewPolicyEnforcer(policies...)
-
-	grpcServer := grpc.NewServer(grpc.Creds(tlsCredentials),
-		grpc.ChainUnaryInterceptor(
-			rex_grpc.AuthInfoInterceptor,
-			rex_grpc.PolicyEnforcementInterceptor(policyEnforcer),
-			rex_grpc.ErrorMarshallerInterceptor,
-		),
+	policyEnforcer := serverFlags.PolicyEnforcer(policies)
+	enforcedPolicy := serverFlags.EnforcedPolicy(policyEnforcer)
+	identityMapper := serverFlags.IdentityMapper()
+	grpcServer := serverFlags.NewGRPCServer(enforcedPolicy, identityMapper, trustedProxies...)
+	var notifier *webhook.Notifier
+	linuxProcessServer := localexec.NewServer(dataDirFlag,
+		localexec.WithConcurrencyLimits(maxConcurrentFlag, maxConcurrentPerPrincipalFlag),
+		localexec.WithQuotas(quotas...),
+		localexec.WithMaxFileSize(maxFileSizeFlag),
+		localexec.WithResourcePolicy(enforcedPolicy.Enforce),
+		localexec.WithCommandValidator(func(ctx context.Context, cmd rex.Command) error {
+			return notifier.ValidateCommand(ctx, cmd)
+		}),
 	)
-	linuxProcessServer := localexec.NewServer(dataDirFlag)
-	rexGRPCServer := rex_grpc.NewServer(linuxProcessServer)
+	notifier = getWebhookNotifier(linuxProcessServer)
+	go func() {
+		if err := notifier.Run(context.Background()); err != nil {
+			log.Fatalf("Webhook notifier stopped: %v", err)
+		}
+	}()
+	scheduler, err := schedule.NewScheduler(linuxProcessServer, path.Join(dataDirFlag, "schedules.json"))
+	if err != nil {
+		log.Fatalf("Failed to load the schedules: %v", err)
+	}
+	go func() {
+		if err := scheduler.Run(context.Background()); err != nil {
+			log.Fatalf("Scheduler stopped: %v", err)
+		}
+	}()
+	workflowRunner := workflow.NewRunner(linuxProcessServer)
+	go func() {
+		if err := workflowRunner.Run(context.Background()); err != nil {
+			log.Fatalf("Workflow runner stopped: %v", err)
+		}
+	}()
+	rexGRPCServer := rex_grpc.NewServer(&service{linuxProcessServer, scheduler, workflowRunner},
+		rex_grpc.WithAccessPolicy(policyEnforcer, identityMapper))
 
 	proto.RegisterRexServer(grpcServer, rexGRPCServer)
 	log.Debugln("Serving...")
@@ -78,13 +116,34 @@ func main() {
 }
 
 func parseAndValidate() {
-	flag.Var(&policyFlags, "policy",
-		"JSON formatted policy with keys Principal, Action, and Effect. Can be passed multiple times.")
-
-	flag.StringVar(&pathToCACert, "ca", "", "path to ca certificate in pem format")
-	flag.StringVar(&pathToCert, "cert", "", "path to server certificate in pem format")
-	flag.StringVar(&pathToKey, "key", "", "path to server private key in pem format")
-	flag.StringVar(&serveAddr, "addr", "localhost:9090", "serve address of format [ip]:port")
+	serverFlags.Register(flag.CommandLine, "server", true)
+	flag.BoolVar(&ownerPolicy, "owner-policy", true,
+		"let the owners of the processes make any call on them. Disable to rely on the Resource policies alone.")
+	flag.Var(&trustedProxies, "trusted-proxy",
+		"common name of a proxy (e.g. rexproxy) allowed to make requests on behalf of other principals. "+
+			"Can be passed multiple times.")
+
+	flag.IntVar(&maxConcurrentFlag, "max-concurrent", 0,
+		"maximum number of processes running at the same time. Others are queued. 0 means no limit.")
+	flag.IntVar(&maxConcurrentPerPrincipalFlag, "max-concurrent-per-principal", 0,
+		"maximum number of processes of a single principal running at the same time. 0 means no limit.")
+	flag.Int64Var(&maxFileSizeFlag, "max-file-size", 0,
+		"maximum size in bytes of each file uploaded to the workspaces. 0 means no limit.")
+	flag.Var(&quotaFlags, "quota",
+		"JSON formatted quota with keys Principal (a user ID, group:NAME shared by the members of the group, "+
+			"or \"*\" for everyone else), MaxRunning, MaxPerHour, "+
+			"MaxOutputBytes, and MaxCPUSecondsPerDay. Can be passed multiple times.")
+
+	flag.Var(&webhookFlags, "webhook",
+		"URL to notify whenever a process exits. Can be passed multiple times.")
+	flag.Var(&webhookAllowFlags, "webhook-allow",
+		"URL prefix that processes are allowed to use as exit callbacks. Can be passed multiple times.")
+	flag.StringVar(&pathToWebhookSecret, "webhook-secret", "",
+		"path to a file containing the secret used to sign webhook payloads (HMAC-SHA256)")
+	flag.IntVar(&webhookAttemptsFlag, "webhook-attempts", webhook.DefaultConfig().MaxAttempts,
+		"number of times a webhook delivery is attempted before giving up")
+	flag.IntVar(&webhookTailSizeFlag, "webhook-tail", webhook.DefaultConfig().TailSize,
+		"number of trailing bytes of stdout/stderr to include in webhook payloads")
 
 	dataDirDefault := os.Getenv("TMPDIR")
 	if len(dataDirDefault) == 0 {
@@ -92,41 +151,26 @@ func parseAndValidate() {
 	}
 	dataDirDefault = path.Join(dataDirDefault, "rex")
 	flag.StringVar(&dataDirFlag, "datadir", dataDirDefault,
-		"Directory to store process stdout/stderr files")
+		"Directory to store process stdout/stderr files, workspaces and the schedules")
 
 	flag.Parse()
 
-	if pathToCACert == "" {
-		log.Fatalln("Missing -ca arg")
-	}
-
-	if pathToKey == "" {
-		log.Fatalln("Missing -key arg")
-	}
-
-	if pathToCert == "" {
-		log.Fatalln("Missing -cert arg")
-	}
+	serverFlags.Validate()
 }
 
-func getTLSCredentials() credentials.TransportCredentials {
-	caPool := x509.NewCertPool()
-	if ok := caPool.AppendCertsFromPEM(io.ReadFileOrFatal(pathToCACert)); !ok {
-		log.Fatalln("CA cert malformed")
+func getWebhookNotifier(svc rex.Service) *webhook.Notifier {
+	config := webhook.DefaultConfig()
+	config.Endpoints = webhookFlags
+	config.Allowlist = webhookAllowFlags
+	config.MaxAttempts = webhookAttemptsFlag
+	config.TailSize = webhookTailSizeFlag
+	if pathToWebhookSecret != "" {
+		config.Secret = bytes.TrimSpace(io.ReadFileOrFatal(pathToWebhookSecret))
 	}
 
-	cert, err := tls.LoadX509KeyPair(pathToCert, pathToKey)
+	notifier, err := webhook.NewNotifier(svc, config)
 	if err != nil {
-		log.Fatalf("Failed to load key pair: %v\n", err)
-	}
-
-	config := &tls.Config{
-		MinVersion:         tls.VersionTLS13,
-		InsecureSkipVerify: false,
-		RootCAs:            caPool,
-		Certificates:       []tls.Certificate{cert},
-		ClientAuth:         tls.RequireAndVerifyClientCert,
-		ClientCAs:          caPool,
+		log.Fatalf("Webhook configuration malformed: %v", err)
 	}
-	return credentials.NewTLS(config)
+	return notifier
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex/cmd/internal/server"
+	"github.com/farnasirim/rex/federation"
+	rex_grpc "github.com/farnasirim/rex/grpc"
+	"github.com/farnasirim/rex/proto"
+)
+
+const (
+	maxMsgSize int = 1e12
+)
+
+var (
+	serverFlags server.Flags
+	nodeFlags   server.VariadicFlag
+)
+
+func main() {
+	log.SetLevel(log.DebugLevel)
+	parseAndValidate()
+
+	caPool, cert := serverFlags.KeyPair()
+	var nodes []federation.Node
+	for _, fl := range nodeFlags {
+		config, err := federation.NodeConfigFromJSON([]byte(fl))
+		if err != nil {
+			log.Fatalf("Node argument malformed: %v", err)
+		}
+		conn := dialNode(config.Address, caPool, cert)
+		defer conn.Close()
+		nodes = append(nodes, federation.Node{
+			Name:    config.Name,
+			Labels:  config.Labels,
+			Service: rex_grpc.NewClient(conn),
+		})
+	}
+	proxy, err := federation.NewProxy(nodes...)
+	if err != nil {
+		log.Fatalf("Invalid nodes: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", serverFlags.ServeAddr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	policyEnforcer := serverFlags.PolicyEnforcer(serverFlags.Policies())
+	identityMapper := serverFlags.IdentityMapper()
+	// Without trusted proxies, the clients claiming to be proxies themselves
+	// are rejected
+	grpcServer := serverFlags.NewGRPCServer(serverFlags.EnforcedPolicy(policyEnforcer), identityMapper)
+	proto.RegisterRexServer(grpcServer, rex_grpc.NewServer(proxy,
+		rex_grpc.WithAccessPolicy(policyEnforcer, identityMapper)))
+	log.Debugf("Serving %d nodes...", len(nodes))
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+// dialNode connects to the rexd of a node, which has to trust the common
+// name of cert through its -trusted-proxy flag.
+func dialNode(address string, caPool *x509.CertPool, cert tls.Certificate) *grpc.ClientConn {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: false,
+		RootCAs:            caPool,
+		Certificates:       []tls.Certificate{cert},
+	}
+
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+		grpc.WithChainUnaryInterceptor(
+			rex_grpc.OnBehalfOfClientInterceptor,
+			rex_grpc.ErrorUnmarshallerInterceptor,
+		),
+		grpc.WithChainStreamInterceptor(
+			rex_grpc.OnBehalfOfStreamClientInterceptor,
+			rex_grpc.ErrorUnmarshallerStreamInterceptor,
+		),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
+	)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	return conn
+}
+
+func parseAndValidate() {
+	flag.Var(&nodeFlags, "node",
+		"JSON formatted node with keys Name, Address ([ip]:port of its rexd) and Labels. "+
+			"Can be passed multiple times.")
+	serverFlags.Register(flag.CommandLine, "proxy", false)
+
+	flag.Parse()
+
+	serverFlags.Validate()
+	if len(nodeFlags) == 0 {
+		log.Fatalln("Missing -node arg")
+	}
+}
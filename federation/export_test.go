@@ -0,0 +1,8 @@
+package federation
+
+// TrackedProcesses returns the number of processes that p keeps the nodes of
+func TrackedProcesses(p *Proxy) int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return len(p.processes)
+}
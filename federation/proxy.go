@@ -0,0 +1,434 @@
+// Package federation fronts several rex services, i.e. nodes, with a single
+// rex.Service.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// namespace is the namespace of the name based UUIDs of the nodes, which are
+// in turn the namespaces of the IDs of their processes
+var namespace = uuid.MustParse("9b3c4f5e-0d4a-4c1b-8f8e-6a2f1d7e5c3b")
+
+// Node is a rex service fronted by a Proxy.
+type Node struct {
+	// Name identifies the node among the other nodes of the proxy.
+	Name string
+	// Labels are arbitrary key/value pairs used to select the node through
+	// rex.Command.NodeSelector.
+	Labels map[string]string
+	// Service executes the processes of the node, usually a grpc.Client.
+	Service rex.Service
+}
+
+// NodeConfig describes how to reach a node.
+type NodeConfig struct {
+	Name    string `validate:"required"`
+	Address string `validate:"required,hostname_port"`
+	Labels  map[string]string
+}
+
+// NodeConfigFromJSON creates a node config from its json representation,
+// e.g. {"Name": "worker-1", "Address": "10.0.0.1:9090", "Labels": {"zone": "eu"}}
+func NodeConfigFromJSON(marshalledConfig []byte) (*NodeConfig, error) {
+	validate := validator.New()
+
+	var config NodeConfig
+	if err := json.Unmarshal(marshalledConfig, &config); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// processRef locates a process or a group on its node
+type processRef struct {
+	node *node
+	id   uuid.UUID
+	// owner and group are the owner and the group on the proxy of a process
+	owner uuid.UUID
+	group uuid.UUID
+}
+
+// nodeID is the ID of a group on its node
+type nodeID struct {
+	node *node
+	id   uuid.UUID
+}
+
+type node struct {
+	Node
+	namespace uuid.UUID
+}
+
+// Proxy implements rex.Service by forwarding the calls to the nodes. The IDs
+// of the processes, the groups, the workflows and the schedules are
+// namespaced by their nodes, so that the same process has different IDs on
+// the proxy and on its node. The groups created through the proxy keep the
+// IDs given by their callers.
+type Proxy struct {
+	nodes  []*node
+	byName map[string]*node
+
+	m sync.Mutex
+	// processes maps the IDs of the processes on the proxy to their nodes.
+	// They are added when the processes are created or listed, and removed
+	// once their nodes stop reporting them.
+	processes map[uuid.UUID]processRef
+	// groups maps the IDs of the groups on the proxy to their nodes, and
+	// groupIDs the other way around. Groups are tracked as long as some of
+	// their processes are.
+	groups   map[uuid.UUID]processRef
+	groupIDs map[nodeID]uuid.UUID
+}
+
+// NewProxy creates a Proxy in front of the given nodes, whose names must be
+// unique.
+func NewProxy(nodes ...Node) (*Proxy, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("no nodes")
+	}
+	p := &Proxy{
+		byName:    make(map[string]*node),
+		processes: make(map[uuid.UUID]processRef),
+		groups:    make(map[uuid.UUID]processRef),
+		groupIDs:  make(map[nodeID]uuid.UUID),
+	}
+	for _, n := range nodes {
+		if n.Name == "" {
+			return nil, errors.New("node without a name")
+		}
+		if _, ok := p.byName[n.Name]; ok {
+			return nil, fmt.Errorf("duplicate node name %q", n.Name)
+		}
+		added := &node{Node: n, namespace: uuid.NewSHA1(namespace, []byte(n.Name))}
+		p.nodes = append(p.nodes, added)
+		p.byName[n.Name] = added
+	}
+	return p, nil
+}
+
+// Exec creates a process from the supplied path and args on the least
+// loaded node
+func (p *Proxy) Exec(ctx context.Context, path string, args ...string) (uuid.UUID, error) {
+	return p.ExecCommand(ctx, rex.Command{Path: path, Args: args})
+}
+
+// ExecCommand creates a process on the node named by command.Node, or
+// otherwise on the least loaded node among those matching
+// command.NodeSelector. Processes joining an existing group are created on
+// the node of the group.
+func (p *Proxy) ExecCommand(ctx context.Context, command rex.Command) (uuid.UUID, error) {
+	groupID := command.GroupID
+	p.m.Lock()
+	group, inGroup := p.groups[groupID]
+	p.m.Unlock()
+	if inGroup {
+		if command.Node != "" && command.Node != group.node.Name {
+			return uuid.Nil, fmt.Errorf("group %v is on node %q", groupID, group.node.Name)
+		}
+		command.Node = group.node.Name
+		command.GroupID = group.id
+	}
+	target, err := p.selectNode(ctx, command)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	command.Node = ""
+	command.NodeSelector = nil
+
+	id, err := target.Service.ExecCommand(ctx, command)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	if groupID != uuid.Nil && !inGroup {
+		p.groups[groupID] = processRef{node: target, id: groupID}
+		p.groupIDs[nodeID{node: target, id: groupID}] = groupID
+	}
+	return p.registerLocked(target, id, callerID(ctx), groupID), nil
+}
+
+// ListProcessInfo merges the processes of all of the nodes, sorted by their
+// creation time (newest first). Nodes that fail to respond are left out,
+// unless all of them fail.
+func (p *Proxy) ListProcessInfo(ctx context.Context) ([]rex.ProcessInfo, error) {
+	lists, errs := p.listAll(ctx)
+	var infoList []rex.ProcessInfo
+	var firstErr error
+	listed := make(map[*node]bool)
+	seen := make(map[uuid.UUID]bool)
+	for i, n := range p.nodes {
+		if errs[i] != nil {
+			log.Warnf("Failed to list the processes of node %s: %v", n.Name, errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		listed[n] = true
+		for _, info := range lists[i] {
+			info = p.fromNode(n, info)
+			seen[info.ID], seen[info.ServiceID] = true, true
+			infoList = append(infoList, info)
+		}
+	}
+	if firstErr != nil && len(infoList) == 0 {
+		return nil, firstErr
+	}
+	p.evict(callerID(ctx), listed, seen)
+	sort.Slice(infoList, func(i, j int) bool {
+		return infoList[i].Create.After(infoList[j].Create)
+	})
+	return infoList, nil
+}
+
+// GetProcessInfo returns the process info of a process from its node
+func (p *Proxy) GetProcessInfo(ctx context.Context, processID uuid.UUID) (rex.ProcessInfo, error) {
+	ref, err := p.lookup(processID)
+	if err != nil {
+		return rex.ProcessInfo{}, err
+	}
+	info, err := ref.node.Service.GetProcessInfo(ctx, ref.id)
+	if err != nil {
+		return rex.ProcessInfo{}, p.forgetIfNotFound(processID, err)
+	}
+	return p.fromNode(ref.node, info), nil
+}
+
+// Kill sends a signal to a process on its node
+func (p *Proxy) Kill(ctx context.Context, processID uuid.UUID, signal int) error {
+	ref, err := p.lookup(processID)
+	if err != nil {
+		return err
+	}
+	return p.forgetIfNotFound(processID, ref.node.Service.Kill(ctx, ref.id, signal))
+}
+
+// Read reads the stdout or the stderr of a process from its node
+func (p *Proxy) Read(ctx context.Context, processID uuid.UUID, target rex.OutputStream) ([]byte, error) {
+	ref, err := p.lookup(processID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ref.node.Service.Read(ctx, ref.id, target)
+	return content, p.forgetIfNotFound(processID, err)
+}
+
+// Delete removes a process from its node
+func (p *Proxy) Delete(ctx context.Context, processID uuid.UUID) error {
+	ref, err := p.lookup(processID)
+	if err != nil {
+		return err
+	}
+	if err := ref.node.Service.Delete(ctx, ref.id); err != nil {
+		return p.forgetIfNotFound(processID, err)
+	}
+	p.m.Lock()
+	delete(p.processes, processID)
+	p.m.Unlock()
+	return nil
+}
+
+// selectNode picks the node that a command is to be executed on
+func (p *Proxy) selectNode(ctx context.Context, command rex.Command) (*node, error) {
+	if command.Node != "" {
+		n, ok := p.byName[command.Node]
+		if !ok {
+			return nil, fmt.Errorf("unknown node %q", command.Node)
+		}
+		if !matchLabels(n.Labels, command.NodeSelector) {
+			return nil, fmt.Errorf("node %q does not match the node selector", command.Node)
+		}
+		return n, nil
+	}
+
+	var candidates []*node
+	for _, n := range p.nodes {
+		if matchLabels(n.Labels, command.NodeSelector) {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no node matches the node selector")
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	loads := make([]int, len(candidates))
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for i, n := range candidates {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			loads[i], errs[i] = rex.LoadOf(ctx, n.Service)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var selected *node
+	minLoad := 0
+	for i, n := range candidates {
+		if errs[i] != nil {
+			log.Warnf("Failed to get the load of node %s: %v", n.Name, errs[i])
+			continue
+		}
+		if selected == nil || loads[i] < minLoad {
+			selected, minLoad = n, loads[i]
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("none of the nodes is reachable: %w", errs[0])
+	}
+	return selected, nil
+}
+
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// listAll lists the processes of all of the nodes concurrently
+func (p *Proxy) listAll(ctx context.Context) ([][]rex.ProcessInfo, []error) {
+	lists := make([][]rex.ProcessInfo, len(p.nodes))
+	errs := make([]error, len(p.nodes))
+	var wg sync.WaitGroup
+	for i, n := range p.nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			lists[i], errs[i] = n.Service.ListProcessInfo(ctx)
+		}(i, n)
+	}
+	wg.Wait()
+	return lists, errs
+}
+
+// lookup locates a process that the proxy has created or listed. The IDs
+// that the proxy has not seen, including the forged ones, are not looked for
+// on the nodes.
+func (p *Proxy) lookup(processID uuid.UUID) (processRef, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if ref, ok := p.processes[processID]; ok {
+		return ref, nil
+	}
+	return processRef{}, rex.ErrNotFound
+}
+
+// forgetIfNotFound stops tracking a process if err says that its node no
+// longer has it. It returns err as is.
+func (p *Proxy) forgetIfNotFound(processID uuid.UUID, err error) error {
+	if err == rex.ErrNotFound {
+		p.m.Lock()
+		delete(p.processes, processID)
+		p.evictGroupsLocked()
+		p.m.Unlock()
+	}
+	return err
+}
+
+// evict stops tracking the processes of owner on the listed nodes that have
+// not been seen in their lists
+func (p *Proxy) evict(owner uuid.UUID, listed map[*node]bool, seen map[uuid.UUID]bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for id, ref := range p.processes {
+		if ref.owner == owner && listed[ref.node] && !seen[id] {
+			delete(p.processes, id)
+		}
+	}
+	p.evictGroupsLocked()
+}
+
+// evictGroupsLocked stops tracking the groups without tracked processes
+func (p *Proxy) evictGroupsLocked() {
+	inUse := make(map[uuid.UUID]bool)
+	for _, ref := range p.processes {
+		inUse[ref.group] = true
+	}
+	for id, ref := range p.groups {
+		if !inUse[id] {
+			delete(p.groups, id)
+			delete(p.groupIDs, nodeID{node: ref.node, id: ref.id})
+		}
+	}
+}
+
+// registerLocked records a process of a node and returns its ID on the
+// proxy
+func (p *Proxy) registerLocked(n *node, id, owner, group uuid.UUID) uuid.UUID {
+	proxyID := n.translate(id)
+	p.processes[proxyID] = processRef{node: n, id: id, owner: owner, group: group}
+	return proxyID
+}
+
+// registerGroupLocked records a group of a node and returns its ID on the
+// proxy
+func (p *Proxy) registerGroupLocked(n *node, id uuid.UUID) uuid.UUID {
+	if proxyID, ok := p.groupIDs[nodeID{node: n, id: id}]; ok {
+		return proxyID
+	}
+	proxyID := n.translate(id)
+	p.groups[proxyID] = processRef{node: n, id: id}
+	p.groupIDs[nodeID{node: n, id: id}] = proxyID
+	return proxyID
+}
+
+// fromNode translates the info of a process from its node to the proxy
+func (p *Proxy) fromNode(n *node, info rex.ProcessInfo) rex.ProcessInfo {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if info.GroupID != uuid.Nil {
+		info.GroupID = p.registerGroupLocked(n, info.GroupID)
+	}
+	info.ID = p.registerLocked(n, info.ID, info.OwnerID, info.GroupID)
+	if info.ServiceID != uuid.Nil {
+		info.ServiceID = p.registerLocked(n, info.ServiceID, info.OwnerID, uuid.Nil)
+	}
+	info.WorkflowID = n.translate(info.WorkflowID)
+	info.ScheduleID = n.translate(info.ScheduleID)
+	info.Node = n.Name
+	return info
+}
+
+// translate namespaces an ID of the node, leaving uuid.Nil as is
+func (n *node) translate(id uuid.UUID) uuid.UUID {
+	if id == uuid.Nil {
+		return uuid.Nil
+	}
+	return uuid.NewSHA1(n.namespace, id[:])
+}
+
+// callerID returns the ID of the caller, or uuid.Nil if it is missing or
+// malformed
+func callerID(ctx context.Context) uuid.UUID {
+	userID, _ := rex.UserIDFromContext(ctx)
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
@@ -0,0 +1,252 @@
+package federation_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/federation"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func newProxy(t *testing.T) (*federation.Proxy, map[string]*localexec.ProcessServer) {
+	servers := map[string]*localexec.ProcessServer{
+		"eu-1": localexec.NewServer(os.TempDir()),
+		"us-1": localexec.NewServer(os.TempDir()),
+	}
+	proxy, err := federation.NewProxy(
+		federation.Node{Name: "eu-1", Labels: map[string]string{"zone": "eu"}, Service: servers["eu-1"]},
+		federation.Node{Name: "us-1", Labels: map[string]string{"zone": "us"}, Service: servers["us-1"]},
+	)
+	if err != nil {
+		t.Fatalf("While creating the proxy: %v", err)
+	}
+	return proxy, servers
+}
+
+func TestProxy_NodeSelection(t *testing.T) {
+	proxy, servers := newProxy(t)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := proxy.ExecCommand(ctx, rex.Command{Path: "sleep", Args: []string{"10"}, Node: "us-1"})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	defer proxy.Kill(ctx, procID, int(syscall.SIGKILL))
+	info, err := proxy.GetProcessInfo(ctx, procID)
+	if err != nil {
+		t.Fatalf("While calling GetProcessInfo: %v", err)
+	}
+	if info.Node != "us-1" || info.ID != procID {
+		t.Errorf("Expected process %v on us-1, got %v on %q", procID, info.ID, info.Node)
+	}
+	if _, err := servers["us-1"].GetProcessInfo(ctx, procID); err != rex.ErrNotFound {
+		t.Errorf("Expected the IDs on the proxy to be namespaced, got %v", err)
+	}
+
+	// eu-1 is less loaded now
+	procID, err = proxy.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer proxy.Kill(ctx, procID, int(syscall.SIGKILL))
+	if info, _ := proxy.GetProcessInfo(ctx, procID); info.Node != "eu-1" {
+		t.Errorf("Expected the least loaded node eu-1 to be selected, got %q", info.Node)
+	}
+
+	procID, err = proxy.ExecCommand(ctx, rex.Command{Path: "true", NodeSelector: map[string]string{"zone": "us"}})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if info, _ := proxy.GetProcessInfo(ctx, procID); info.Node != "us-1" {
+		t.Errorf("Expected the node matching the selector to be selected, got %q", info.Node)
+	}
+
+	if _, err := proxy.ExecCommand(ctx, rex.Command{Path: "true", Node: "ap-1"}); err == nil {
+		t.Errorf("Expected an unknown node to be rejected")
+	}
+	if _, err := proxy.ExecCommand(ctx, rex.Command{Path: "true",
+		NodeSelector: map[string]string{"zone": "ap"}}); err == nil {
+		t.Errorf("Expected a selector without matching nodes to be rejected")
+	}
+}
+
+func TestProxy_ListAndLookup(t *testing.T) {
+	proxy, servers := newProxy(t)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	// Created behind the back of the proxy
+	nodeProcID, err := servers["eu-1"].Exec(ctx, "echo", "hello")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	if _, err := proxy.ExecCommand(ctx, rex.Command{Path: "true", Node: "us-1"}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	infoList, err := proxy.ListProcessInfo(ctx)
+	if err != nil {
+		t.Fatalf("While calling ListProcessInfo: %v", err)
+	}
+	nodes := map[string]int{}
+	for _, info := range infoList {
+		nodes[info.Node]++
+	}
+	if len(infoList) != 2 || nodes["eu-1"] != 1 || nodes["us-1"] != 1 {
+		t.Errorf("Expected a process on each node, got %v", nodes)
+	}
+
+	fresh, err := federation.NewProxy(
+		federation.Node{Name: "eu-1", Service: servers["eu-1"]},
+		federation.Node{Name: "us-1", Service: servers["us-1"]},
+	)
+	if err != nil {
+		t.Fatalf("While creating the proxy: %v", err)
+	}
+	for _, info := range infoList {
+		if info.Node != "eu-1" {
+			continue
+		}
+		if _, err := fresh.GetProcessInfo(ctx, info.ID); err != rex.ErrNotFound {
+			t.Errorf("Expected a new proxy not to look for unlisted processes, got %v", err)
+		}
+		if _, err := fresh.ListProcessInfo(ctx); err != nil {
+			t.Fatalf("While calling ListProcessInfo: %v", err)
+		}
+		found, err := fresh.GetProcessInfo(ctx, info.ID)
+		if err != nil {
+			t.Fatalf("Expected a new proxy to find the listed process, got %v", err)
+		}
+		if found.Path != info.Path || found.ID == nodeProcID {
+			t.Errorf("Expected the namespaced process %v, got %+v", info.ID, found)
+		}
+	}
+	if _, err := fresh.GetProcessInfo(ctx, uuid.New()); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+}
+
+func TestProxy_NamespacedIDs(t *testing.T) {
+	proxy, servers := newProxy(t)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	groupID := uuid.New()
+	procID, err := proxy.ExecCommand(ctx, rex.Command{Path: "sleep", Args: []string{"10"},
+		Node: "us-1", GroupID: groupID})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	defer proxy.Kill(ctx, procID, int(syscall.SIGKILL))
+	// us-1 is more loaded, but the group is there
+	procID, err = proxy.ExecCommand(ctx, rex.Command{Path: "true", GroupID: groupID})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	info, err := proxy.GetProcessInfo(ctx, procID)
+	if err != nil {
+		t.Fatalf("While calling GetProcessInfo: %v", err)
+	}
+	if info.Node != "us-1" || info.GroupID != groupID {
+		t.Errorf("Expected the process to join group %v on us-1, got %v on %q", groupID, info.GroupID, info.Node)
+	}
+
+	// Created behind the back of the proxy
+	nodeGroupID, scheduleID, workflowID := uuid.New(), uuid.New(), uuid.New()
+	if _, err := servers["eu-1"].ExecCommand(ctx, rex.Command{Path: "true",
+		GroupID: nodeGroupID, ScheduleID: scheduleID, WorkflowID: workflowID}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	infoList, err := proxy.ListProcessInfo(ctx)
+	if err != nil {
+		t.Fatalf("While calling ListProcessInfo: %v", err)
+	}
+	var proxyGroupID uuid.UUID
+	for _, info := range infoList {
+		if info.Node != "eu-1" {
+			continue
+		}
+		if info.GroupID == nodeGroupID || info.ScheduleID == scheduleID || info.WorkflowID == workflowID ||
+			info.ScheduleID == uuid.Nil || info.WorkflowID == uuid.Nil {
+			t.Errorf("Expected the IDs on the proxy to be namespaced, got %+v", info)
+		}
+		proxyGroupID = info.GroupID
+	}
+	procID, err = proxy.ExecCommand(ctx, rex.Command{Path: "true", GroupID: proxyGroupID})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if info, _ := proxy.GetProcessInfo(ctx, procID); info.Node != "eu-1" || info.GroupID != proxyGroupID {
+		t.Errorf("Expected the process to join group %v on eu-1, got %v on %q", proxyGroupID, info.GroupID, info.Node)
+	}
+	if _, err := proxy.ExecCommand(ctx, rex.Command{Path: "true", GroupID: proxyGroupID, Node: "us-1"}); err == nil {
+		t.Errorf("Expected joining a group on another node to be rejected")
+	}
+}
+
+func TestProxy_Eviction(t *testing.T) {
+	proxy, servers := newProxy(t)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	var procIDs []uuid.UUID
+	for _, node := range []string{"eu-1", "us-1"} {
+		procID, err := proxy.ExecCommand(ctx, rex.Command{Path: "true", Node: node})
+		if err != nil {
+			t.Fatalf("While calling ExecCommand: %v", err)
+		}
+		procIDs = append(procIDs, procID)
+	}
+	infoList, err := proxy.ListProcessInfo(ctx)
+	if err != nil {
+		t.Fatalf("While calling ListProcessInfo: %v", err)
+	}
+	if federation.TrackedProcesses(proxy) != 2 {
+		t.Fatalf("Expected both processes to be tracked, got %d", federation.TrackedProcesses(proxy))
+	}
+
+	// Deleted behind the back of the proxy
+	for _, server := range servers {
+		nodeList, err := server.ListProcessInfo(ctx)
+		if err != nil {
+			t.Fatalf("While calling ListProcessInfo: %v", err)
+		}
+		for _, info := range nodeList {
+			waitForExit(t, server, ctx, info.ID)
+			if err := server.Delete(ctx, info.ID); err != nil {
+				t.Fatalf("While calling Delete: %v", err)
+			}
+		}
+	}
+	if _, err := proxy.GetProcessInfo(ctx, procIDs[0]); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+	if federation.TrackedProcesses(proxy) != 1 {
+		t.Errorf("Expected the process missing from its node to be evicted, got %d tracked",
+			federation.TrackedProcesses(proxy))
+	}
+	if infoList, err = proxy.ListProcessInfo(ctx); err != nil || len(infoList) != 0 {
+		t.Fatalf("Expected no processes to be listed, got %+v, %v", infoList, err)
+	}
+	if federation.TrackedProcesses(proxy) != 0 {
+		t.Errorf("Expected the processes missing from the lists to be evicted, got %d tracked",
+			federation.TrackedProcesses(proxy))
+	}
+}
+
+func waitForExit(t *testing.T, s rex.Service, ctx context.Context, procID uuid.UUID) {
+	for i := 0; i < 100; i++ {
+		info, err := s.GetProcessInfo(ctx, procID)
+		if err != nil {
+			t.Fatalf("While calling GetProcessInfo: %v", err)
+		}
+		if info.State == rex.ProcessExited {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Process %v did not exit", procID)
+}
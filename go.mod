@@ -5,11 +5,13 @@ go 1.14
 require (
 	github.com/go-playground/validator/v10 v10.4.0
 	github.com/golang/protobuf v1.4.2
+	github.com/google/cel-go v0.6.0
 	github.com/google/uuid v1.1.2
 	github.com/kataras/tablewriter v0.0.0-20180708051242-e063d29b7c23
 	github.com/mattn/go-runewidth v0.0.9 // indirect
 	github.com/sirupsen/logrus v1.6.0
 	golang.org/x/sys v0.0.0-20200923182605-d9f96fdee20d // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
 	google.golang.org/grpc v1.32.0
 	google.golang.org/protobuf v1.25.0
 	gopkg.in/yaml.v2 v2.3.0
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/farnasirim/rex/proto"
+)
+
+// CheckAccessOnBehalfAction is the action that the callers of CheckAccess
+// need on top of /Rex/CheckAccess to check on behalf of other principals, or
+// with groups or roles that they do not have. Since "*" does not match "/"
+// within patterns, only "*" and the patterns spelling out /Rex/CheckAccess/
+// match it.
+const CheckAccessOnBehalfAction = "/Rex/CheckAccess/OnBehalf"
+
+// knownActions holds the full names of the methods of the services in
+// rex.proto, so that new methods can be referred to by the rules as soon as
+// they are added, along with the actions that are checked within the methods
+var knownActions = append(actionsOf(proto.File_rex_proto), CheckAccessOnBehalfAction)
+
+// Actions returns the full names of the methods that the rules can refer
+// to, e.g. /Rex/Exec, along with CheckAccessOnBehalfAction.
+func Actions() []string {
+	return append([]string(nil), knownActions...)
+}
+
+func actionsOf(file protoreflect.FileDescriptor) []string {
+	var actions []string
+	services := file.Services()
+	for i := 0; i < services.Len(); i++ {
+		service := services.Get(i)
+		methods := service.Methods()
+		for j := 0; j < methods.Len(); j++ {
+			actions = append(actions, "/"+string(service.FullName())+"/"+string(methods.Get(j).Name()))
+		}
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// findMethod returns the method of the services in rex.proto with the given
+// full name, e.g. /Rex/Exec, or nil if there is none
+func findMethod(action string) protoreflect.MethodDescriptor {
+	services := proto.File_rex_proto.Services()
+	for i := 0; i < services.Len(); i++ {
+		service := services.Get(i)
+		methods := service.Methods()
+		for j := 0; j < methods.Len(); j++ {
+			if "/"+string(service.FullName())+"/"+string(methods.Get(j).Name()) == action {
+				return methods.Get(j)
+			}
+		}
+	}
+	return nil
+}
+
+// newRequest creates an empty request of method
+func newRequest(method protoreflect.MethodDescriptor) (protoreflect.Message, error) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+	if err != nil {
+		return nil, err
+	}
+	return messageType.New(), nil
+}
+
+// Patterns is a list of glob patterns as understood by path.Match, where a
+// bare "*" matches everything. It can be written in JSON as a single string
+// as well as a list of strings.
+type Patterns []string
+
+// UnmarshalJSON accepts either a string or a list of strings
+func (p *Patterns) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*p = Patterns{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// match reports whether s matches any of the patterns
+func (p Patterns) match(s string) bool {
+	for _, pattern := range p {
+		if matchGlob(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// specificity is 0 if the patterns include "*", 2 if none of them has any
+// special characters, and 1 otherwise
+func (p Patterns) specificity() int {
+	ret := 2
+	for _, pattern := range p {
+		if pattern == "*" {
+			return 0
+		}
+		if strings.ContainsAny(pattern, `*?[\`) {
+			ret = 1
+		}
+	}
+	return ret
+}
+
+// matchAny reports whether any of names matches any of the patterns
+func (p Patterns) matchAny(names []string) bool {
+	for _, name := range names {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(pattern, s string) bool {
+	if pattern == "*" {
+		return true
+	}
+	matched, _ := path.Match(pattern, s)
+	return matched
+}
+
+// newValidator creates a validator that also understands:
+//   - action: a glob pattern that matches at least one of the known actions
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterValidation("action", func(fl validator.FieldLevel) bool {
+		pattern := fl.Field().String()
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false
+		}
+		for _, action := range knownActions {
+			if matchGlob(pattern, action) {
+				return true
+			}
+		}
+		return false
+	})
+	return validate
+}
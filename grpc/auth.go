@@ -22,6 +22,35 @@ func AuthInfoInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler) (resp interface{}, err error) {
 
+	ctx, err = withAuthInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugln(info.FullMethod)
+
+	return handler(ctx, req)
+}
+
+// AuthInfoStreamInterceptor is the streaming counterpart of
+// AuthInfoInterceptor.
+func AuthInfoStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	ctx, err := withAuthInfo(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	log.Debugln(info.FullMethod)
+
+	return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+}
+
+func withAuthInfo(ctx context.Context) (context.Context, error) {
 	grpcPeer, ok := peer.FromContext(ctx)
 	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated,
@@ -40,10 +69,7 @@ func AuthInfoInterceptor(
 		log.Warnln("Peer used multiple certificates. Using the first one.")
 	}
 
-	log.Debugln("CN:", tlsInfo.State.PeerCertificates[0].Subject.CommonName)
-	ctx = rex.WithUserID(ctx, tlsInfo.State.PeerCertificates[0].Subject.CommonName)
-
-	log.Debugln(info.FullMethod)
-
-	return handler(ctx, req)
+	cert := tlsInfo.State.PeerCertificates[0]
+	log.Debugln("CN:", cert.Subject.CommonName)
+	return withPeerCertificate(rex.WithUserID(ctx, cert.Subject.CommonName), cert), nil
 }
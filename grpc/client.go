@@ -3,12 +3,20 @@ package grpc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/empty"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	"github.com/farnasirim/rex"
 	"github.com/farnasirim/rex/proto"
@@ -22,12 +30,13 @@ type Client struct {
 // Exec implementes rex.Service.Exec by sending it over GRPC to a remote
 // implementation of rex.Service
 func (c *Client) Exec(ctx context.Context, path string, args ...string) (uuid.UUID, error) {
-	req := &proto.ExecRequest{
-		Path: path,
-		Args: args,
-	}
+	return c.ExecCommand(ctx, rex.Command{Path: path, Args: args})
+}
 
-	execResponse, err := c.grpcClient.Exec(ctx, req)
+// ExecCommand implements rex.Service.ExecCommand by sending it over GRPC to a
+// remote implementation of rex.Service
+func (c *Client) ExecCommand(ctx context.Context, cmd rex.Command) (uuid.UUID, error) {
+	execResponse, err := c.grpcClient.Exec(ctx, execRequestProtoFromNative(cmd))
 	if err != nil {
 		if st, ok := status.FromError(err); ok {
 			return uuid.Nil, errors.New(st.Message())
@@ -108,17 +117,765 @@ func (c *Client) Read(ctx context.Context, processID uuid.UUID, target rex.Outpu
 	return readResponse.Content, nil
 }
 
+// Delete translates Delete from the native API to the GRPC api to remove a
+// process from the remote implementation of rex.Service
+func (c *Client) Delete(ctx context.Context, processID uuid.UUID) error {
+	_, err := c.grpcClient.Delete(ctx,
+		&proto.DeleteRequest{ProcessUUID: processID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return errors.New(st.Message())
+		}
+		return err
+	}
+	return nil
+}
+
+// Watch translates Watch from the native API to the GRPC api and delivers the
+// events received from the remote implementation of rex.Service on the
+// returned channel
+func (c *Client) Watch(ctx context.Context, filter rex.EventFilter) (<-chan rex.Event, error) {
+	req := &proto.WatchRequest{
+		Labels:        filter.Labels,
+		AfterSequence: filter.AfterSeq,
+	}
+	if filter.OwnerID != uuid.Nil {
+		req.OwnerUUID = filter.OwnerID.String()
+	}
+
+	stream, err := c.grpcClient.Watch(ctx, req)
+	if err == nil {
+		err = waitForStreamAcceptance(stream)
+	}
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return nil, errors.New(st.Message())
+		}
+		return nil, err
+	}
+
+	events := make(chan rex.Event)
+	go func() {
+		defer close(events)
+		for {
+			protoEvent, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.Warnf("Watch stream terminated: %v", err)
+				}
+				return
+			}
+			select {
+			case events <- eventNativeFromProto(protoEvent):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// waitForStreamAcceptance blocks until the server either accepts a server
+// streaming call or fails it, allowing authn/authz errors to be reported
+// before the first message of the stream arrives.
+// GetQuota returns the quota of the caller along with its current usage
+func (c *Client) GetQuota(ctx context.Context) (rex.Quota, rex.ResourceUsage, error) {
+	resp, err := c.grpcClient.GetQuota(ctx, &proto.GetQuotaRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Quota{}, rex.ResourceUsage{}, errors.New(st.Message())
+		}
+		return rex.Quota{}, rex.ResourceUsage{}, err
+	}
+
+	quota := rex.Quota{
+		MaxRunning:          int(resp.GetQuota().GetMaxRunning()),
+		MaxPerHour:          int(resp.GetQuota().GetMaxPerHour()),
+		MaxOutputBytes:      resp.GetQuota().GetMaxOutputBytes(),
+		MaxCPUSecondsPerDay: resp.GetQuota().GetMaxCPUSecondsPerDay(),
+	}
+	usage := rex.ResourceUsage{
+		Running:           int(resp.GetUsage().GetRunning()),
+		CreatedLastHour:   int(resp.GetUsage().GetCreatedLastHour()),
+		OutputBytes:       resp.GetUsage().GetOutputBytes(),
+		CPUSecondsLastDay: resp.GetUsage().GetCpuSecondsLastDay(),
+		Queued:            int(resp.GetUsage().GetQueued()),
+	}
+	return quota, usage, nil
+}
+
+// CreateSchedule forwards a CreateSchedule request to a remote GRPC
+// implementation of rex.Scheduler
+func (c *Client) CreateSchedule(ctx context.Context, spec rex.Schedule) (rex.Schedule, error) {
+	resp, err := c.grpcClient.CreateSchedule(ctx, &proto.CreateScheduleRequest{
+		Cron:     spec.Cron,
+		TimeZone: spec.TimeZone,
+		Command:  execRequestProtoFromNative(spec.Command),
+		Overlap:  proto.Schedule_Overlap(spec.Overlap),
+		Paused:   spec.Paused,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Schedule{}, errors.New(st.Message())
+		}
+		return rex.Schedule{}, err
+	}
+	return scheduleNativeFromProto(resp), nil
+}
+
+// ListSchedules forwards a ListSchedules request to a remote GRPC
+// implementation of rex.Scheduler
+func (c *Client) ListSchedules(ctx context.Context) ([]rex.Schedule, error) {
+	resp, err := c.grpcClient.ListSchedules(ctx, &proto.ListSchedulesRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return nil, errors.New(st.Message())
+		}
+		return nil, err
+	}
+
+	var schedules []rex.Schedule
+	for _, schedule := range resp.GetSchedules() {
+		schedules = append(schedules, scheduleNativeFromProto(schedule))
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule forwards a DeleteSchedule request to a remote GRPC
+// implementation of rex.Scheduler
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleID uuid.UUID) error {
+	_, err := c.grpcClient.DeleteSchedule(ctx,
+		&proto.DeleteScheduleRequest{ScheduleUUID: scheduleID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return errors.New(st.Message())
+		}
+		return err
+	}
+	return nil
+}
+
+// PauseSchedule forwards a PauseSchedule request to a remote GRPC
+// implementation of rex.Scheduler
+func (c *Client) PauseSchedule(ctx context.Context, scheduleID uuid.UUID, paused bool) (rex.Schedule, error) {
+	resp, err := c.grpcClient.PauseSchedule(ctx, &proto.PauseScheduleRequest{
+		ScheduleUUID: scheduleID.String(),
+		Paused:       paused,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Schedule{}, errors.New(st.Message())
+		}
+		return rex.Schedule{}, err
+	}
+	return scheduleNativeFromProto(resp), nil
+}
+
+// GetServiceInfo forwards a GetServiceInfo request to a remote GRPC
+// implementation of rex.Supervisor
+func (c *Client) GetServiceInfo(ctx context.Context, serviceID uuid.UUID) (rex.ServiceInfo, error) {
+	resp, err := c.grpcClient.GetServiceInfo(ctx,
+		&proto.GetServiceInfoRequest{ServiceUUID: serviceID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.ServiceInfo{}, errors.New(st.Message())
+		}
+		return rex.ServiceInfo{}, err
+	}
+
+	info := rex.ServiceInfo{
+		ID:          uuid.MustParse(resp.ServiceUUID),
+		OwnerID:     uuid.MustParse(resp.OwnerUUID),
+		Command:     commandNativeFromProto(resp.Command),
+		State:       rex.ServiceState(resp.State),
+		Restarts:    int(resp.Restarts),
+		NextRestart: time.Unix(resp.NextRestart.GetSeconds(), int64(resp.NextRestart.GetNanos())).UTC(),
+	}
+	for _, incarnation := range resp.Incarnations {
+		info.Incarnations = append(info.Incarnations, processInfoNativeFromProto(incarnation))
+	}
+	return info, nil
+}
+
+// WaitFor forwards a WaitFor request to a remote GRPC implementation of
+// rex.OutputWaiter. Returns context.DeadlineExceeded if the deadline of ctx
+// is exceeded while waiting.
+func (c *Client) WaitFor(ctx context.Context, processID uuid.UUID,
+	target rex.OutputStream, pattern string) (rex.OutputMatch, error) {
+	req := &proto.WaitForRequest{ProcessUUID: processID.String(), Pattern: pattern}
+	if target == rex.StdoutStream {
+		req.Target = proto.ReadRequest_STDOUT
+	} else if target == rex.StderrStream {
+		req.Target = proto.ReadRequest_STDERR
+	}
+	resp, err := c.grpcClient.WaitFor(ctx, req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			if st.Code() == codes.DeadlineExceeded {
+				return rex.OutputMatch{}, context.DeadlineExceeded
+			}
+			return rex.OutputMatch{}, errors.New(st.Message())
+		}
+		return rex.OutputMatch{}, err
+	}
+	return rex.OutputMatch{
+		Matched: resp.Matched,
+		Line:    resp.Line,
+		Offset:  resp.Offset,
+		Process: processInfoNativeFromProto(resp.Process),
+	}, nil
+}
+
+// SubmitWorkflow forwards a SubmitWorkflow request to a remote GRPC
+// implementation of rex.WorkflowRunner
+func (c *Client) SubmitWorkflow(ctx context.Context, spec rex.Workflow) (rex.Workflow, error) {
+	req := &proto.SubmitWorkflowRequest{}
+	for _, step := range spec.Steps {
+		req.Steps = append(req.Steps, workflowStepProtoFromNative(step))
+	}
+	resp, err := c.grpcClient.SubmitWorkflow(ctx, req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Workflow{}, errors.New(st.Message())
+		}
+		return rex.Workflow{}, err
+	}
+	return workflowNativeFromProto(resp), nil
+}
+
+// GetWorkflow forwards a GetWorkflow request to a remote GRPC implementation
+// of rex.WorkflowRunner
+func (c *Client) GetWorkflow(ctx context.Context, workflowID uuid.UUID) (rex.Workflow, error) {
+	resp, err := c.grpcClient.GetWorkflow(ctx,
+		&proto.GetWorkflowRequest{WorkflowUUID: workflowID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Workflow{}, errors.New(st.Message())
+		}
+		return rex.Workflow{}, err
+	}
+	return workflowNativeFromProto(resp), nil
+}
+
+// ListWorkflows forwards a ListWorkflows request to a remote GRPC
+// implementation of rex.WorkflowRunner
+func (c *Client) ListWorkflows(ctx context.Context) ([]rex.Workflow, error) {
+	resp, err := c.grpcClient.ListWorkflows(ctx, &proto.ListWorkflowsRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return nil, errors.New(st.Message())
+		}
+		return nil, err
+	}
+
+	var workflows []rex.Workflow
+	for _, workflow := range resp.GetWorkflows() {
+		workflows = append(workflows, workflowNativeFromProto(workflow))
+	}
+	return workflows, nil
+}
+
+// CancelWorkflow forwards a CancelWorkflow request to a remote GRPC
+// implementation of rex.WorkflowRunner
+func (c *Client) CancelWorkflow(ctx context.Context, workflowID uuid.UUID) (rex.Workflow, error) {
+	resp, err := c.grpcClient.CancelWorkflow(ctx,
+		&proto.CancelWorkflowRequest{WorkflowUUID: workflowID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Workflow{}, errors.New(st.Message())
+		}
+		return rex.Workflow{}, err
+	}
+	return workflowNativeFromProto(resp), nil
+}
+
+// GetGroupInfo forwards a GetGroupInfo request to a remote GRPC
+// implementation of rex.GroupManager
+func (c *Client) GetGroupInfo(ctx context.Context, groupID uuid.UUID) (rex.GroupInfo, error) {
+	resp, err := c.grpcClient.GetGroupInfo(ctx,
+		&proto.GetGroupInfoRequest{GroupUUID: groupID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.GroupInfo{}, errors.New(st.Message())
+		}
+		return rex.GroupInfo{}, err
+	}
+	return groupInfoNativeFromProto(resp), nil
+}
+
+// ListGroups forwards a ListGroups request to a remote GRPC implementation
+// of rex.GroupManager
+func (c *Client) ListGroups(ctx context.Context) ([]rex.GroupInfo, error) {
+	resp, err := c.grpcClient.ListGroups(ctx, &proto.ListGroupsRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return nil, errors.New(st.Message())
+		}
+		return nil, err
+	}
+
+	var groups []rex.GroupInfo
+	for _, info := range resp.GetGroups() {
+		groups = append(groups, groupInfoNativeFromProto(info))
+	}
+	return groups, nil
+}
+
+// KillGroup forwards a KillGroup request to a remote GRPC implementation of
+// rex.GroupManager
+func (c *Client) KillGroup(ctx context.Context, groupID uuid.UUID, signal int) error {
+	_, err := c.grpcClient.KillGroup(ctx,
+		&proto.KillGroupRequest{GroupUUID: groupID.String(), Signal: int32(signal)},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return errors.New(st.Message())
+		}
+		return err
+	}
+	return nil
+}
+
+// WaitGroup forwards a WaitGroup request to a remote GRPC implementation of
+// rex.GroupManager. Returns context.DeadlineExceeded if the deadline of ctx
+// is exceeded while waiting.
+func (c *Client) WaitGroup(ctx context.Context, groupID uuid.UUID,
+	mode rex.GroupWaitMode) (rex.GroupInfo, error) {
+	resp, err := c.grpcClient.WaitGroup(ctx, &proto.WaitGroupRequest{
+		GroupUUID: groupID.String(),
+		Mode:      proto.WaitGroupRequest_Mode(mode),
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			if st.Code() == codes.DeadlineExceeded {
+				return rex.GroupInfo{}, context.DeadlineExceeded
+			}
+			return rex.GroupInfo{}, errors.New(st.Message())
+		}
+		return rex.GroupInfo{}, err
+	}
+	return groupInfoNativeFromProto(resp), nil
+}
+
+// DeleteGroup forwards a DeleteGroup request to a remote GRPC implementation
+// of rex.GroupManager
+func (c *Client) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
+	_, err := c.grpcClient.DeleteGroup(ctx,
+		&proto.DeleteGroupRequest{GroupUUID: groupID.String()},
+	)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return errors.New(st.Message())
+		}
+		return err
+	}
+	return nil
+}
+
+// GetCapacity forwards a GetCapacity request to a remote GRPC implementation
+// of rex.CapacityReporter
+func (c *Client) GetCapacity(ctx context.Context) (rex.Capacity, error) {
+	resp, err := c.grpcClient.GetCapacity(ctx, &proto.GetCapacityRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Capacity{}, errors.New(st.Message())
+		}
+		return rex.Capacity{}, err
+	}
+	return rex.Capacity{
+		Running:       int(resp.GetRunning()),
+		Queued:        int(resp.GetQueued()),
+		MaxConcurrent: int(resp.GetMaxConcurrent()),
+	}, nil
+}
+
+// WhoAmI forwards a WhoAmI request to a remote GRPC implementation of
+// rex.IdentityReporter
+func (c *Client) WhoAmI(ctx context.Context) (rex.Identity, error) {
+	resp, err := c.grpcClient.WhoAmI(ctx, &proto.WhoAmIRequest{})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.Identity{}, errors.New(st.Message())
+		}
+		return rex.Identity{}, err
+	}
+	return rex.Identity{
+		UserID: resp.GetUserID(),
+		Groups: resp.GetGroups(),
+		Roles:  resp.GetRoles(),
+	}, nil
+}
+
+// CheckAccess forwards a CheckAccess request to a remote GRPC implementation
+// of rex.AccessChecker
+func (c *Client) CheckAccess(ctx context.Context, check rex.AccessCheck) (rex.AccessDecision, error) {
+	request, err := accessCheckRequestProtoFromNative(check)
+	if err != nil {
+		return rex.AccessDecision{}, err
+	}
+	resp, err := c.grpcClient.CheckAccess(ctx, &proto.CheckAccessRequest{
+		Method:    check.Method,
+		Request:   request,
+		Principal: check.Principal,
+		Groups:    check.Groups,
+		Roles:     check.Roles,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.AccessDecision{}, errors.New(st.Message())
+		}
+		return rex.AccessDecision{}, err
+	}
+	return rex.AccessDecision{
+		Allowed: resp.GetAllowed(),
+		Rules:   resp.GetRules(),
+		Reason:  resp.GetReason(),
+		Identity: rex.Identity{
+			UserID: resp.GetIdentity().GetUserID(),
+			Groups: resp.GetIdentity().GetGroups(),
+			Roles:  resp.GetIdentity().GetRoles(),
+		},
+	}, nil
+}
+
+// accessCheckRequestProtoFromNative serializes the request of the method of
+// check, filling in those of its fields that check has: the command of Exec
+// requests, and processUUID and signal
+func accessCheckRequestProtoFromNative(check rex.AccessCheck) ([]byte, error) {
+	method := findMethod(check.Method)
+	if method == nil {
+		return nil, fmt.Errorf("unknown method %q", check.Method)
+	}
+	request, err := newRequest(method)
+	if err != nil {
+		return nil, err
+	}
+	if check.Command != nil {
+		if exec, ok := request.Interface().(*proto.ExecRequest); ok {
+			protobuf.Merge(exec, execRequestProtoFromNative(*check.Command))
+		}
+	}
+	fields := request.Descriptor().Fields()
+	if fd := fields.ByName("processUUID"); fd != nil && check.ProcessID != uuid.Nil {
+		request.Set(fd, protoreflect.ValueOfString(check.ProcessID.String()))
+	}
+	if fd := fields.ByName("signal"); fd != nil && check.Signal != 0 {
+		request.Set(fd, protoreflect.ValueOfInt32(int32(check.Signal)))
+	}
+	return protobuf.Marshal(request.Interface())
+}
+
+// Upload streams the content read from r to a remote GRPC implementation of
+// rex.FileStore
+func (c *Client) Upload(ctx context.Context, file rex.FileRef,
+	offset int64, checksum string, r io.Reader) (rex.FileInfo, error) {
+	// Canceling the call is the only way to abort the upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.grpcClient.Upload(ctx)
+	if err == nil {
+		err = stream.Send(&proto.UploadRequest{
+			Content: &proto.UploadRequest_Header_{Header: &proto.UploadRequest_Header{
+				File:   fileRefProtoFromNative(file),
+				Offset: offset,
+				Sha256: checksum,
+			}},
+		})
+	}
+	buf := make([]byte, fileChunkSize)
+	for err == nil {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			err = stream.Send(&proto.UploadRequest{
+				Content: &proto.UploadRequest_Chunk{Chunk: buf[:n]},
+			})
+		}
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return rex.FileInfo{}, readErr
+		}
+	}
+	// Send fails with io.EOF once the server has ended the call, whose
+	// status is then reported by CloseAndRecv.
+	var resp *proto.FileInfo
+	if err == nil || err == io.EOF {
+		resp, err = stream.CloseAndRecv()
+	}
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.FileInfo{}, errors.New(st.Message())
+		}
+		return rex.FileInfo{}, err
+	}
+	return fileInfoNativeFromProto(resp), nil
+}
+
+// Download streams a file from a remote GRPC implementation of
+// rex.FileStore. Closing the returned reader cancels the call.
+func (c *Client) Download(ctx context.Context, file rex.FileRef,
+	offset int64) (rex.FileInfo, io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.grpcClient.Download(ctx, &proto.DownloadRequest{
+		File:   fileRefProtoFromNative(file),
+		Offset: offset,
+	})
+	var resp *proto.DownloadResponse
+	if err == nil {
+		resp, err = stream.Recv()
+	}
+	if err == nil && resp.GetInfo() == nil {
+		err = errors.New("download without the file info")
+	}
+	if err != nil {
+		cancel()
+		if st, ok := status.FromError(err); ok {
+			return rex.FileInfo{}, nil, errors.New(st.Message())
+		}
+		return rex.FileInfo{}, nil, err
+	}
+	return fileInfoNativeFromProto(resp.GetInfo()), &downloadReader{stream: stream, cancel: cancel}, nil
+}
+
+// downloadReader reads the chunks of a download
+type downloadReader struct {
+	stream  proto.Rex_DownloadClient
+	cancel  context.CancelFunc
+	pending []byte
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		resp, err := r.stream.Recv()
+		if err != nil {
+			if st, ok := status.FromError(err); ok {
+				return 0, errors.New(st.Message())
+			}
+			return 0, err
+		}
+		r.pending = resp.GetChunk()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *downloadReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// StatFile forwards a StatFile request to a remote GRPC implementation of
+// rex.FileStore
+func (c *Client) StatFile(ctx context.Context, file rex.FileRef) (rex.FileInfo, error) {
+	resp, err := c.grpcClient.StatFile(ctx, &proto.StatFileRequest{File: fileRefProtoFromNative(file)})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return rex.FileInfo{}, errors.New(st.Message())
+		}
+		return rex.FileInfo{}, err
+	}
+	return fileInfoNativeFromProto(resp), nil
+}
+
+func fileRefProtoFromNative(file rex.FileRef) *proto.FileRef {
+	ref := &proto.FileRef{Path: file.Path}
+	if file.ProcessID != uuid.Nil {
+		ref.ProcessUUID = file.ProcessID.String()
+	}
+	return ref
+}
+
+func fileInfoNativeFromProto(info *proto.FileInfo) rex.FileInfo {
+	return rex.FileInfo{
+		Path:     info.GetPath(),
+		Size:     info.GetSize(),
+		SHA256:   info.GetSha256(),
+		Modified: time.Unix(info.GetModified().GetSeconds(), int64(info.GetModified().GetNanos())).UTC(),
+	}
+}
+
+func groupInfoNativeFromProto(info *proto.GroupInfo) rex.GroupInfo {
+	ret := rex.GroupInfo{
+		ID:        uuid.MustParse(info.GroupUUID),
+		OwnerID:   uuid.MustParse(info.OwnerUUID),
+		State:     rex.GroupState(info.State),
+		Queued:    int(info.Queued),
+		Running:   int(info.Running),
+		Succeeded: int(info.Succeeded),
+		Failed:    int(info.Failed),
+	}
+	for _, process := range info.Processes {
+		ret.Processes = append(ret.Processes, processInfoNativeFromProto(process))
+	}
+	return ret
+}
+
+func workflowNativeFromProto(workflow *proto.Workflow) rex.Workflow {
+	ret := rex.Workflow{
+		ID:      uuid.MustParse(workflow.WorkflowUUID),
+		OwnerID: uuid.MustParse(workflow.OwnerUUID),
+		State:   rex.WorkflowState(workflow.State),
+		Create:  time.Unix(workflow.Create.GetSeconds(), int64(workflow.Create.GetNanos())).UTC(),
+		Finish:  time.Unix(workflow.Finish.GetSeconds(), int64(workflow.Finish.GetNanos())).UTC(),
+	}
+	for _, step := range workflow.Steps {
+		ret.Steps = append(ret.Steps, workflowStepNativeFromProto(step))
+	}
+	return ret
+}
+
+func waitForStreamAcceptance(stream grpc.ClientStream) error {
+	md, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	if len(md.Get(streamAcceptedHeader)) > 0 {
+		return nil
+	}
+	// The call has failed with a trailers-only response, in which case the
+	// status can only be observed through RecvMsg.
+	return stream.RecvMsg(new(empty.Empty))
+}
+
 func processInfoNativeFromProto(pInfo *proto.ProcessInfo) rex.ProcessInfo {
-	return rex.ProcessInfo{
-		ID:       uuid.MustParse(pInfo.ProcessUUID),
-		PID:      int(pInfo.Pid),
-		ExitCode: int(pInfo.ExitCode),
-		Path:     pInfo.Path,
-		Args:     pInfo.Args,
-		Running:  pInfo.Running,
-		OwnerID:  uuid.MustParse(pInfo.OwnerUUID),
-		Create:   time.Unix(pInfo.Create.GetSeconds(), int64(pInfo.Create.GetNanos())).UTC(),
-		Exit:     time.Unix(pInfo.Exit.GetSeconds(), int64(pInfo.Exit.GetNanos())).UTC(),
+	info := rex.ProcessInfo{
+		ID:            uuid.MustParse(pInfo.ProcessUUID),
+		PID:           int(pInfo.Pid),
+		ExitCode:      int(pInfo.ExitCode),
+		Path:          pInfo.Path,
+		Args:          pInfo.Args,
+		Running:       pInfo.Running,
+		OwnerID:       uuid.MustParse(pInfo.OwnerUUID),
+		Create:        time.Unix(pInfo.Create.GetSeconds(), int64(pInfo.Create.GetNanos())).UTC(),
+		Exit:          time.Unix(pInfo.Exit.GetSeconds(), int64(pInfo.Exit.GetNanos())).UTC(),
+		Labels:        pInfo.Labels,
+		Callbacks:     pInfo.Callbacks,
+		State:         rex.ProcessState(pInfo.State),
+		QueuePosition: int(pInfo.QueuePosition),
+		Priority:      int(pInfo.Priority),
+		Start:         time.Unix(pInfo.Start.GetSeconds(), int64(pInfo.Start.GetNanos())).UTC(),
+		ScheduleID:    uuidNativeFromProto(pInfo.ScheduleUUID),
+		ServiceID:     uuidNativeFromProto(pInfo.ServiceUUID),
+		Incarnation:   int(pInfo.Incarnation),
+		Health:        rex.HealthState(pInfo.Health),
+		WorkflowID:    uuidNativeFromProto(pInfo.WorkflowUUID),
+		GroupID:       uuidNativeFromProto(pInfo.GroupUUID),
+		Node:          pInfo.Node,
+		Artifacts:     pInfo.Artifacts,
+	}
+	for _, stage := range pInfo.Stages {
+		info.Stages = append(info.Stages, rex.StageInfo{
+			Path:     stage.Path,
+			Args:     stage.Args,
+			PID:      int(stage.Pid),
+			Exited:   stage.Exited,
+			ExitCode: int(stage.ExitCode),
+		})
+	}
+	for _, status := range pInfo.Probes {
+		info.Probes = append(info.Probes, rex.ProbeStatus{
+			Name:                status.Name,
+			Health:              rex.HealthState(status.Health),
+			ConsecutiveFailures: int(status.ConsecutiveFailures),
+			LastCheck:           time.Unix(status.LastCheck.GetSeconds(), int64(status.LastCheck.GetNanos())).UTC(),
+			LastMessage:         status.LastMessage,
+		})
+	}
+	return info
+}
+
+func execRequestProtoFromNative(cmd rex.Command) *proto.ExecRequest {
+	req := &proto.ExecRequest{
+		Path:           cmd.Path,
+		Args:           cmd.Args,
+		Labels:         cmd.Labels,
+		Callbacks:      cmd.Callbacks,
+		Priority:       int32(cmd.Priority),
+		GroupUUID:      uuidProtoFromNative(cmd.GroupID),
+		Node:           cmd.Node,
+		NodeSelector:   cmd.NodeSelector,
+		PrivateWorkDir: cmd.PrivateWorkDir,
+		Artifacts:      cmd.Artifacts,
+		Dir:            cmd.Dir,
+		Env:            cmd.Env,
+	}
+	if cmd.Restart != nil {
+		req.Restart = &proto.RestartPolicy{
+			Mode:        proto.RestartPolicy_Mode(cmd.Restart.Mode),
+			MaxRestarts: int32(cmd.Restart.MaxRestarts),
+			Backoff:     durationProtoFromNative(cmd.Restart.Backoff),
+			MaxBackoff:  durationProtoFromNative(cmd.Restart.MaxBackoff),
+		}
+	}
+	for _, stage := range cmd.Pipeline {
+		req.Pipeline = append(req.Pipeline, &proto.PipelineStage{
+			Path: stage.Path,
+			Args: stage.Args,
+		})
+	}
+	for _, probe := range cmd.Probes {
+		req.Probes = append(req.Probes, &proto.Probe{
+			Name:             probe.Name,
+			Type:             proto.Probe_Type(probe.Type),
+			Command:          probe.Command,
+			Port:             int32(probe.Port),
+			Path:             probe.Path,
+			Interval:         durationProtoFromNative(probe.Interval),
+			Timeout:          durationProtoFromNative(probe.Timeout),
+			FailureThreshold: int32(probe.FailureThreshold),
+			RestartAfter:     int32(probe.RestartAfter),
+		})
+	}
+	return req
+}
+
+func durationProtoFromNative(d time.Duration) *duration.Duration {
+	return &duration.Duration{
+		Seconds: int64(d / time.Second),
+		Nanos:   int32(d % time.Second),
+	}
+}
+
+func scheduleNativeFromProto(schedule *proto.Schedule) rex.Schedule {
+	return rex.Schedule{
+		ID:            uuid.MustParse(schedule.ScheduleUUID),
+		OwnerID:       uuid.MustParse(schedule.OwnerUUID),
+		Cron:          schedule.Cron,
+		TimeZone:      schedule.TimeZone,
+		Command:       commandNativeFromProto(schedule.Command),
+		Overlap:       rex.OverlapPolicy(schedule.Overlap),
+		Paused:        schedule.Paused,
+		Create:        time.Unix(schedule.Create.GetSeconds(), int64(schedule.Create.GetNanos())).UTC(),
+		LastRun:       time.Unix(schedule.LastRun.GetSeconds(), int64(schedule.LastRun.GetNanos())).UTC(),
+		NextRun:       time.Unix(schedule.NextRun.GetSeconds(), int64(schedule.NextRun.GetNanos())).UTC(),
+		LastProcessID: uuidNativeFromProto(schedule.LastProcessUUID),
+		LastError:     schedule.LastError,
+	}
+}
+
+// uuidNativeFromProto is the inverse of uuidProtoFromNative
+func uuidNativeFromProto(id string) uuid.UUID {
+	if id == "" {
+		return uuid.Nil
+	}
+	return uuid.MustParse(id)
+}
+
+func eventNativeFromProto(protoEvent *proto.Event) rex.Event {
+	return rex.Event{
+		Seq:     protoEvent.Sequence,
+		Type:    rex.EventType(protoEvent.Type),
+		Time:    time.Unix(protoEvent.Time.GetSeconds(), int64(protoEvent.Time.GetNanos())).UTC(),
+		Process: processInfoNativeFromProto(protoEvent.Process),
+		Signal:  int(protoEvent.Signal),
 	}
 }
 
@@ -0,0 +1,347 @@
+package grpc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/proto"
+)
+
+// RequestConditions restrict an access rule to the requests whose content
+// falls within them. Each field left empty places no restriction. The
+// commands of Exec, CreateSchedule and SubmitWorkflow requests are all
+// checked, including the stages of their pipelines and their exec probes.
+//
+// Allow rules apply if everything that the request would run or send falls
+// within the conditions, and the fields that have nothing to restrict in a
+// request, e.g. Signals in an Exec request, place no restriction. Deny rules
+// apply if anything does: any of the executables along with its arguments,
+// or any of the signals, so that a command cannot slip past them as a
+// pipeline stage, a probe or a workflow step. Deny rules do not apply to the
+// requests that have nothing for their conditions to restrict.
+type RequestConditions struct {
+	// Executables lists the paths, or glob patterns of the paths, of the
+	// executables that the commands may run.
+	Executables []string `json:",omitempty"`
+	// ResolveExecutables matches Executables against the real paths of the
+	// executables, looked up in PATH and with symbolic links resolved on
+	// the host that enforces the policy, rather than the requested paths.
+	// Either way, relative paths are taken relative to the working
+	// directory of the commands, and never match when it is private, since
+	// it does not exist yet.
+	ResolveExecutables bool `json:",omitempty"`
+	// Args lists regular expressions, one of which every argument of the
+	// commands has to match in full.
+	Args []string `json:",omitempty"`
+	// WorkDirs lists glob patterns of the working directories of the
+	// commands, with symbolic links resolved. Commands that do not ask for
+	// one run in the working directory of the server. Private working
+	// directories always match.
+	WorkDirs []string `json:",omitempty"`
+	// EnvNames lists glob patterns of the names of the environment
+	// variables that the commands may set.
+	EnvNames []string `json:",omitempty"`
+	// Signals lists the signals that Kill and KillGroup may send.
+	Signals []int `json:",omitempty"`
+
+	args []*regexp.Regexp
+}
+
+// compile checks the patterns of the conditions and prepares them for
+// matching
+func (c *RequestConditions) compile() error {
+	for _, patterns := range [][]string{c.Executables, c.WorkDirs, c.EnvNames} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("bad pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	c.args = nil
+	for _, expr := range c.Args {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("bad argument pattern %q: %w", expr, err)
+		}
+		c.args = append(c.args, re)
+	}
+	return nil
+}
+
+// match reports whether the content of req falls within the conditions:
+// all of it for allow rules, and any of it for deny rules
+func (c *RequestConditions) match(req interface{}, allow bool) bool {
+	if !allow {
+		return c.matchAny(req)
+	}
+	commands, signals := requestContent(req)
+	for _, command := range commands {
+		if !c.matchCommand(command) {
+			return false
+		}
+	}
+	for _, signal := range signals {
+		if !c.matchSignal(signal) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether any of the executables or the signals of req
+// falls within the conditions
+func (c *RequestConditions) matchAny(req interface{}) bool {
+	restrictsCommands := len(c.Executables)+len(c.Args)+len(c.WorkDirs)+len(c.EnvNames) > 0
+	restrictsSignals := len(c.Signals) > 0
+	if !restrictsCommands && !restrictsSignals {
+		return true
+	}
+	commands, signals := requestContent(req)
+	if restrictsCommands {
+		for _, command := range commands {
+			if c.matchAnyCommand(command) {
+				return true
+			}
+		}
+	}
+	if restrictsSignals {
+		for _, signal := range signals {
+			if c.matchSignal(signal) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commandArgvs lists the argument vectors of everything that command runs
+func commandArgvs(command rex.Command) [][]string {
+	argvs := [][]string{append([]string{command.Path}, command.Args...)}
+	for _, stage := range command.Pipeline {
+		argvs = append(argvs, append([]string{stage.Path}, stage.Args...))
+	}
+	for _, probe := range command.Probes {
+		if probe.Type == rex.ProbeExec && len(probe.Command) > 0 {
+			argvs = append(argvs, probe.Command)
+		}
+	}
+	return argvs
+}
+
+func (c *RequestConditions) matchCommand(command rex.Command) bool {
+	for _, argv := range commandArgvs(command) {
+		if matched, err := c.matchExecutable(argv[0], command); err != nil || !matched {
+			return false
+		}
+		for _, arg := range argv[1:] {
+			if !c.matchArg(arg) {
+				return false
+			}
+		}
+	}
+
+	if !command.PrivateWorkDir && !c.matchWorkDir(command.Dir) {
+		return false
+	}
+	if len(c.EnvNames) > 0 {
+		for name := range command.Env {
+			if !matchAnyGlob(c.EnvNames, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchAnyCommand reports whether any of the executables of command falls
+// within the conditions along with any of its arguments, in the working
+// directory and with any of the environment variables of the conditions
+func (c *RequestConditions) matchAnyCommand(command rex.Command) bool {
+	if len(c.WorkDirs) > 0 && (command.PrivateWorkDir || !c.matchWorkDir(command.Dir)) {
+		return false
+	}
+	if len(c.EnvNames) > 0 {
+		found := false
+		for name := range command.Env {
+			found = found || matchAnyGlob(c.EnvNames, name)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, argv := range commandArgvs(command) {
+		// The executables that cannot be told apart are denied
+		if matched, err := c.matchExecutable(argv[0], command); err == nil && !matched {
+			continue
+		}
+		if len(c.args) == 0 {
+			return true
+		}
+		for _, arg := range argv[1:] {
+			if c.matchArg(arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchExecutable reports whether the executable at path, as run by command,
+// is one of Executables. Fails if the executable cannot be found.
+func (c *RequestConditions) matchExecutable(path string, command rex.Command) (bool, error) {
+	if len(c.Executables) == 0 {
+		return true, nil
+	}
+	path, err := executablePath(path, command, c.ResolveExecutables)
+	if err != nil {
+		return false, err
+	}
+	return matchAnyGlob(c.Executables, path), nil
+}
+
+func (c *RequestConditions) matchArg(arg string) bool {
+	if len(c.args) == 0 {
+		return true
+	}
+	for _, re := range c.args {
+		if re.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RequestConditions) matchWorkDir(dir string) bool {
+	if len(c.WorkDirs) == 0 {
+		return true
+	}
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return false
+		}
+		dir = wd
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return matchAnyGlob(c.WorkDirs, real)
+}
+
+func (c *RequestConditions) matchSignal(signal int) bool {
+	if len(c.Signals) == 0 {
+		return true
+	}
+	for _, allowed := range c.Signals {
+		if allowed == signal {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceOwnerSelf matches the processes owned by the caller in
+// ResourceConditions.Owner
+const ResourceOwnerSelf = "self"
+
+// ResourceConditions restrict an access rule to the calls acting on the
+// processes that match them. Each field left empty places no restriction.
+type ResourceConditions struct {
+	// Owner is the ID of the owner of the processes, or ResourceOwnerSelf
+	// for the caller.
+	Owner string `json:",omitempty"`
+	// Labels lists labels that the processes must have.
+	Labels map[string]string `json:",omitempty"`
+	// GroupID is the ID of the group that the processes must be in.
+	GroupID string `json:",omitempty"`
+}
+
+func (c *ResourceConditions) match(resource rex.Resource, userID string) bool {
+	switch c.Owner {
+	case "", "*":
+	case ResourceOwnerSelf:
+		if resource.OwnerID != userID {
+			return false
+		}
+	default:
+		if resource.OwnerID != c.Owner {
+			return false
+		}
+	}
+	for key, value := range c.Labels {
+		if actual, ok := resource.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return c.GroupID == "" || c.GroupID == "*" || c.GroupID == resource.GroupID.String()
+}
+
+// requestContent extracts what RequestConditions restrict from a decoded
+// request: the commands that it would run and the signals that it would
+// send
+func requestContent(req interface{}) ([]rex.Command, []int) {
+	switch req := req.(type) {
+	case *proto.ExecRequest:
+		return []rex.Command{commandNativeFromProto(req)}, nil
+	case *proto.CreateScheduleRequest:
+		return []rex.Command{commandNativeFromProto(req.GetCommand())}, nil
+	case *proto.SubmitWorkflowRequest:
+		var commands []rex.Command
+		for _, step := range req.GetSteps() {
+			commands = append(commands, commandNativeFromProto(step.GetCommand()))
+		}
+		return commands, nil
+	case *proto.KillRequest:
+		return nil, []int{int(req.GetSignal())}
+	case *proto.KillGroupRequest:
+		return nil, []int{int(req.GetSignal())}
+	}
+	return nil, nil
+}
+
+// executablePath finds the path of the executable that command would run for
+// path. Names without a slash are kept as they are, unless resolve is set,
+// in which case they are looked up in the PATH of the server as the
+// processes are, and symbolic links are resolved. Relative paths are taken
+// relative to the working directory of command.
+func executablePath(path string, command rex.Command, resolve bool) (string, error) {
+	if !strings.Contains(path, "/") {
+		if !resolve {
+			return path, nil
+		}
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return "", err
+		}
+		path = found
+	}
+	if !filepath.IsAbs(path) {
+		if command.PrivateWorkDir {
+			return "", fmt.Errorf("%s is relative to a private working directory", path)
+		}
+		path = filepath.Join(command.Dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolve {
+		return filepath.EvalSymlinks(abs)
+	}
+	return abs, nil
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
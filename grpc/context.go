@@ -2,12 +2,19 @@ package grpc
 
 import (
 	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 )
 
 type grpcContextKey string
 
 const (
-	methodNameContextKey grpcContextKey = "Rex-GRPC-Context-MethodName"
+	methodNameContextKey  grpcContextKey = "Rex-GRPC-Context-MethodName"
+	requestContextKey     grpcContextKey = "Rex-GRPC-Context-Request"
+	certificateContextKey grpcContextKey = "Rex-GRPC-Context-PeerCertificate"
+	peerAddressContextKey grpcContextKey = "Rex-GRPC-Context-PeerAddress"
 )
 
 func methodNameFromContext(ctx context.Context) (string, bool) {
@@ -18,3 +25,51 @@ func methodNameFromContext(ctx context.Context) (string, bool) {
 func withMethodName(ctx context.Context, methodName string) context.Context {
 	return context.WithValue(ctx, methodNameContextKey, methodName)
 }
+
+func requestFromContext(ctx context.Context) (interface{}, bool) {
+	val := ctx.Value(requestContextKey)
+	return val, val != nil
+}
+
+func withRequest(ctx context.Context, req interface{}) context.Context {
+	return context.WithValue(ctx, requestContextKey, req)
+}
+
+// peerCertificateFromContext returns the certificate that the caller has
+// presented, unless it is a trusted proxy acting on behalf of someone else
+func peerCertificateFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	val, ok := ctx.Value(certificateContextKey).(*x509.Certificate)
+	return val, ok && val != nil
+}
+
+func withPeerCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, certificateContextKey, cert)
+}
+
+// peerAddressFromContext returns the address that the call comes from, which
+// is the one of the principal when a trusted proxy has forwarded it
+func peerAddressFromContext(ctx context.Context) (string, bool) {
+	if val, ok := ctx.Value(peerAddressContextKey).(string); ok {
+		return val, val != ""
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "", false
+	}
+	return p.Addr.String(), true
+}
+
+func withPeerAddress(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, peerAddressContextKey, address)
+}
+
+// wrappedServerStream replaces the context of a grpc.ServerStream, allowing
+// stream interceptors to pass values down to the handlers.
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -6,9 +6,13 @@ import (
 	"errors"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
 )
 
 type errorChain struct {
@@ -72,8 +76,40 @@ func ErrorUnmarshallerInterceptor(
 	invoker grpc.UnaryInvoker,
 	opts ...grpc.CallOption) error {
 
-	topLevelErr := invoker(ctx, method, req, reply, cc, opts...)
+	return unmarshalError(invoker(ctx, method, req, reply, cc, opts...))
+}
 
+// ErrorUnmarshallerStreamInterceptor is the streaming counterpart of
+// ErrorUnmarshallerInterceptor.
+func ErrorUnmarshallerStreamInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, unmarshalError(err)
+	}
+	return &errorUnmarshallingClientStream{stream}, nil
+}
+
+type errorUnmarshallingClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorUnmarshallingClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	return md, unmarshalError(err)
+}
+
+func (s *errorUnmarshallingClientStream) RecvMsg(m interface{}) error {
+	return unmarshalError(s.ClientStream.RecvMsg(m))
+}
+
+func unmarshalError(topLevelErr error) error {
 	if topLevelErr != nil {
 		st, _ := status.FromError(topLevelErr)
 		if st == nil || st.Proto() == nil {
@@ -81,6 +117,14 @@ func ErrorUnmarshallerInterceptor(
 		}
 		errChain, err := errorChainFromJSON(st.Proto().GetMessage())
 		if err != nil {
+			if st.Code() == codes.Unavailable {
+				// Not returned by rex but by the transport, e.g. when the
+				// server cannot be reached
+				return &errorChain{
+					Message: st.Message(),
+					Next:    errorChainFromError(rex.ErrUnavailable),
+				}
+			}
 			return topLevelErr
 		}
 		return errChain
@@ -97,8 +141,48 @@ func ErrorMarshallerInterceptor(
 
 	ret, err := handler(ctx, req)
 	if st, ok := status.FromError(err); !ok {
-		return ret, status.Errorf(st.Code(), errorChainFromError(err).Marshal().Error())
+		return ret, status.Errorf(codeFromError(err, st.Code()), errorChainFromError(err).Marshal().Error())
 	}
 
 	return ret, err
 }
+
+// ErrorMarshallerStreamInterceptor is the streaming counterpart of
+// ErrorMarshallerInterceptor.
+func ErrorMarshallerStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	err := handler(srv, ss)
+	if st, ok := status.FromError(err); !ok {
+		return status.Errorf(codeFromError(err, st.Code()), errorChainFromError(err).Marshal().Error())
+	}
+
+	return err
+}
+
+// codeFromError returns the grpc status code corresponding to the rex error
+// that err wraps, or defaultCode if there is none.
+func codeFromError(err error, defaultCode codes.Code) codes.Code {
+	switch {
+	case errors.Is(err, rex.ErrResourceExhausted):
+		return codes.ResourceExhausted
+	case errors.Is(err, rex.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, rex.ErrAccessDenied):
+		return codes.PermissionDenied
+	case errors.Is(err, rex.ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, rex.ErrNotImplemented):
+		return codes.Unimplemented
+	case errors.Is(err, rex.ErrProcessRunning), errors.Is(err, rex.ErrNotRunning):
+		return codes.FailedPrecondition
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, rex.ErrUnavailable):
+		return codes.Unavailable
+	}
+	return defaultCode
+}
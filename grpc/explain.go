@@ -0,0 +1,158 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// Decision explains the verdict of a policy on a call
+type Decision struct {
+	Allowed bool
+	// Applies is false if none of the rules apply to the call, which is
+	// then denied.
+	Applies bool
+	// Rules lists the rules that have decided, from the outermost policy
+	// set inwards.
+	Rules  []string
+	Reason string
+}
+
+// explainer is implemented by the policies that can tell which of their
+// rules decide on a call
+type explainer interface {
+	explain(ctx context.Context) Decision
+}
+
+// Explain enforces policy on ctx as Enforce does, and finds the rules that
+// decide the verdict. Policies that cannot explain themselves are described
+// as a whole.
+func Explain(ctx context.Context, policy Policy) Decision {
+	if e, ok := policy.(explainer); ok {
+		return e.explain(ctx)
+	}
+	verdict, applies := policy.Enforce(ctx)
+	decision := Decision{Allowed: verdict && applies, Applies: applies}
+	if applies {
+		decision.Rules = []string{describePolicy(policy)}
+	}
+	return decision
+}
+
+func describePolicy(policy Policy) string {
+	if s, ok := policy.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", policy)
+}
+
+func (e *PolicyEnforcer) explain(ctx context.Context) Decision {
+	verdict, applies, decider := e.decide(ctx)
+	if !applies {
+		return Decision{Reason: "none of the policies apply"}
+	}
+	decision := Explain(ctx, e.policies[decider])
+	decision.Allowed, decision.Applies = verdict, true
+	if decision.Reason == "" {
+		effect, other := "denies", "allows"
+		if verdict {
+			effect, other = other, effect
+		}
+		switch {
+		case e.algorithm == FirstApplicable:
+			decision.Reason = fmt.Sprintf("%s: the first policy that applies %s the call", e.algorithm, effect)
+		case verdict == (e.algorithm == PermitOverrides):
+			decision.Reason = fmt.Sprintf("%s: a policy %s the call", e.algorithm, effect)
+		default:
+			decision.Reason = fmt.Sprintf("%s: a policy %s the call and none %s it", e.algorithm, effect, other)
+		}
+	}
+	return decision
+}
+
+func (s *PolicySet) explain(ctx context.Context) Decision {
+	decision := s.enforcer.explain(ctx)
+	if s.Name != "" && decision.Applies {
+		decision.Rules = append([]string{"policy set " + s.Name}, decision.Rules...)
+	}
+	return decision
+}
+
+func (f *PolicyFile) explain(ctx context.Context) Decision {
+	decision := f.set.Load().(*PolicySet).explain(ctx)
+	if decision.Applies {
+		decision.Rules = append([]string{"policy file " + f.path}, decision.Rules...)
+	}
+	return decision
+}
+
+func (r *SimpleAccessRule) String() string {
+	rule := struct {
+		Principal  Patterns
+		Action     Patterns
+		Effect     string
+		Conditions *RequestConditions  `json:",omitempty"`
+		Resource   *ResourceConditions `json:",omitempty"`
+		Priority   int                 `json:",omitempty"`
+	}{r.Principal, r.Action, r.Effect, r.Conditions, r.Resource, r.Priority}
+	return marshalRule(rule)
+}
+
+func (r *ExpressionRule) String() string {
+	rule := struct {
+		Effect     string
+		Expression string
+		Priority   int `json:",omitempty"`
+	}{r.Effect, r.Expression, r.Priority}
+	return marshalRule(rule)
+}
+
+// marshalRule describes a rule in the json representation that it is created
+// from, leaving the characters of the expressions unescaped
+func marshalRule(rule interface{}) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	// The rules have neither unsupported types nor cycles
+	_ = encoder.Encode(rule)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// LogDenials wraps policy to log the rules that decide on the calls that
+// it denies
+func LogDenials(policy Policy) Policy {
+	return &denialLogger{policy: policy}
+}
+
+type denialLogger struct {
+	policy Policy
+}
+
+func (l *denialLogger) Enforce(ctx context.Context) (bool, bool) {
+	verdict, applies := l.policy.Enforce(ctx)
+	if !verdict || !applies {
+		userID, _ := rex.UserIDFromContext(ctx)
+		method, _ := methodNameFromContext(ctx)
+		call := method
+		if resource, ok := rex.ResourceFromContext(ctx); ok {
+			call = fmt.Sprintf("%s on %s", method, resource.ProcessID)
+		}
+		decision := Explain(ctx, l.policy)
+		if len(decision.Rules) == 0 {
+			log.Infof("Denied %s calling %s (%s)", userID, call, decision.Reason)
+		} else {
+			log.Infof("Denied %s calling %s (%s) by %s", userID, call, decision.Reason,
+				strings.Join(decision.Rules, " > "))
+		}
+	}
+	return verdict, applies
+}
+
+func (l *denialLogger) explain(ctx context.Context) Decision {
+	return Explain(ctx, l.policy)
+}
@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/proto"
+)
+
+func TestExplain(t *testing.T) {
+	enforcer := NewPolicyEnforcer(mustPolicy(t, `{"Principal": "*", "Action": "*", "Effect": "allow"}`),
+		mustPolicy(t, `{"Name": "team-a", "Algorithm": "first-applicable", "Policies": [
+			{"Principal": "role:lead", "Action": "/Rex/Kill", "Effect": "allow"},
+			{"Principal": "group:team-a", "Action": "/Rex/Kill", "Effect": "deny"}]}`))
+	ctx := rex.WithGroups(rex.WithUserID(context.Background(), "alice"), []string{"team-a"})
+
+	decision := Explain(withMethodName(ctx, "/Rex/Kill"), enforcer)
+	expectedRules := []string{"policy set team-a",
+		`{"Principal":["group:team-a"],"Action":["/Rex/Kill"],"Effect":"deny"}`}
+	if decision.Allowed || !decision.Applies || !reflect.DeepEqual(decision.Rules, expectedRules) ||
+		!strings.HasPrefix(decision.Reason, "first-applicable") {
+		t.Errorf("Expected the rule of the team to deny the call, got %+v", decision)
+	}
+
+	decision = Explain(withMethodName(rex.WithRoles(ctx, []string{"lead"}), "/Rex/Kill"), enforcer)
+	expectedRules = []string{`{"Principal":["*"],"Action":["*"],"Effect":"allow"}`}
+	if !decision.Allowed || !reflect.DeepEqual(decision.Rules, expectedRules) {
+		t.Errorf("Expected the first rule that allows the call to decide, got %+v", decision)
+	}
+
+	decision = Explain(withMethodName(ctx, "/Rex/Kill"), NewPolicyEnforcer())
+	if decision.Allowed || decision.Applies || decision.Reason == "" {
+		t.Errorf("Expected the call to be denied for lack of policies, got %+v", decision)
+	}
+}
+
+func TestServer_CheckAccess(t *testing.T) {
+	bindings, err := RoleBindingsFromJSON([]byte(`{"groups": {"ops": ["bob"]}}`))
+	if err != nil {
+		t.Fatalf("Caught error while creating role bindings from JSON: %v", err)
+	}
+	policy := NewPolicyEnforcer(
+		mustPolicy(t, `{"Principal": "*", "Action": "*", "Effect": "allow"}`),
+		mustPolicy(t, `{"Principal": "*", "Action": "/Rex/Exec", "Effect": "deny",
+			"Conditions": {"Executables": ["/usr/bin/make"]}}`),
+		mustPolicy(t, `{"Effect": "deny", "Expression": "method == '/Rex/Kill' && request.signal != 15 && !('ops' in groups)"}`))
+	server := NewServer(nil, WithAccessPolicy(policy, NewIdentityMapper(bindings)))
+	ctx := rex.WithGroups(rex.WithUserID(context.Background(), "alice"), nil)
+
+	cases := []struct {
+		check   rex.AccessCheck
+		allowed bool
+	}{
+		{rex.AccessCheck{Method: "/Rex/Exec", Command: &rex.Command{Path: "/usr/bin/make"}}, false},
+		{rex.AccessCheck{Method: "/Rex/Exec", Command: &rex.Command{Path: "/bin/true"}}, true},
+		{rex.AccessCheck{Method: "/Rex/Kill", ProcessID: uuid.New(), Signal: 9}, false},
+		{rex.AccessCheck{Method: "/Rex/Kill", ProcessID: uuid.New(), Signal: 15}, true},
+		{rex.AccessCheck{Method: "/Rex/Kill", Signal: 9, Principal: "bob"}, true},
+		{rex.AccessCheck{Method: "/Rex/Watch"}, true},
+	}
+	for _, c := range cases {
+		request, err := accessCheckRequestProtoFromNative(c.check)
+		if err != nil {
+			t.Fatalf("%+v: %v", c.check, err)
+		}
+		decision, err := server.CheckAccess(ctx, &proto.CheckAccessRequest{
+			Method: c.check.Method, Request: request, Principal: c.check.Principal})
+		if err != nil {
+			t.Fatalf("%+v: %v", c.check, err)
+		}
+		if decision.GetAllowed() != c.allowed || len(decision.GetRules()) == 0 {
+			t.Errorf("%+v: expected allowed to be %v, got %+v", c.check, c.allowed, decision)
+		}
+		if c.check.Principal == "bob" && decision.GetIdentity().GetGroups()[0] != "ops" {
+			t.Errorf("Expected bob to be checked as a member of ops, got %+v", decision.GetIdentity())
+		}
+	}
+
+	if _, err := server.CheckAccess(ctx, &proto.CheckAccessRequest{Method: "/Rex/Nonexistent"}); err == nil {
+		t.Errorf("Expected an unknown method to be rejected")
+	}
+}
+
+func TestServer_CheckAccess_OnBehalf(t *testing.T) {
+	policy := NewPolicyEnforcer(
+		mustPolicy(t, `{"Principal": "*", "Action": "/Rex/*", "Effect": "allow"}`),
+		mustPolicy(t, `{"Principal": "group:ops", "Action": "/Rex/CheckAccess/OnBehalf", "Effect": "allow"}`))
+	server := NewServer(nil, WithAccessPolicy(policy, nil))
+	aliceCtx := rex.WithGroups(rex.WithUserID(context.Background(), "alice"), nil)
+	bobCtx := rex.WithGroups(rex.WithUserID(context.Background(), "bob"), []string{"ops"})
+
+	cases := []struct {
+		ctx     context.Context
+		req     *proto.CheckAccessRequest
+		allowed bool
+	}{
+		{aliceCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch"}, true},
+		{aliceCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch", Principal: "alice"}, true},
+		{aliceCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch", Principal: "bob"}, false},
+		{aliceCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch", Groups: []string{"ops"}}, false},
+		{aliceCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch", Roles: []string{"admin"}}, false},
+		{bobCtx, &proto.CheckAccessRequest{Method: "/Rex/Watch", Principal: "alice"}, true},
+	}
+	for _, c := range cases {
+		_, err := server.CheckAccess(c.ctx, c.req)
+		if c.allowed && err != nil {
+			t.Errorf("%+v: expected the check to be made, got %v", c.req, err)
+		}
+		if !c.allowed && err != rex.ErrAccessDenied {
+			t.Errorf("%+v: expected error %v, actual: %v", c.req, rex.ErrAccessDenied, err)
+		}
+	}
+}
+
+func mustPolicy(t *testing.T, marshalledPolicy string) Policy {
+	policy, err := PolicyFromJSON([]byte(marshalledPolicy))
+	if err != nil {
+		t.Fatalf("Caught error while creating the policy from JSON: %v", err)
+	}
+	return policy
+}
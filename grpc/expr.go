@@ -0,0 +1,302 @@
+package grpc
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// This file compiles the CEL expressions of ExpressionRule. Expressions are
+// parsed and type checked against the variables of their environment once,
+// and then evaluated for each call.
+//
+// The objects that the expressions see, e.g. request and process, are
+// declared as object types of exprTypeProvider, and are represented by
+// map[string]interface{} when evaluated. Their fields hold bool, int64,
+// string, []interface{} and map[string]interface{} values.
+
+// exprObjects maps the names of the object types of the expressions to the
+// types of their fields
+type exprObjects map[string]map[string]*exprpb.Type
+
+// exprTypeProvider adds the object types to the types known to CEL
+type exprTypeProvider struct {
+	ref.TypeProvider
+	objects exprObjects
+}
+
+// FindType implements ref.TypeProvider
+func (p *exprTypeProvider) FindType(typeName string) (*exprpb.Type, bool) {
+	if _, ok := p.objects[typeName]; ok {
+		return decls.NewTypeType(decls.NewObjectType(typeName)), true
+	}
+	return p.TypeProvider.FindType(typeName)
+}
+
+// FindFieldType implements ref.TypeProvider. Reading a field that the value
+// of the object lacks fails the evaluation, and has() tells whether it is
+// there.
+func (p *exprTypeProvider) FindFieldType(messageType, fieldName string) (*ref.FieldType, bool) {
+	fields, ok := p.objects[messageType]
+	if !ok {
+		return p.TypeProvider.FindFieldType(messageType, fieldName)
+	}
+	t, ok := fields[fieldName]
+	if !ok {
+		return nil, false
+	}
+	return &ref.FieldType{
+		Type: t,
+		IsSet: func(target interface{}) bool {
+			object, _ := target.(map[string]interface{})
+			_, ok := object[fieldName]
+			return ok
+		},
+		GetFrom: func(target interface{}) (interface{}, error) {
+			object, _ := target.(map[string]interface{})
+			value, ok := object[fieldName]
+			if !ok {
+				return nil, fmt.Errorf("no such field %q", fieldName)
+			}
+			return value, nil
+		},
+	}, true
+}
+
+// newExprEnv creates the environment of the expressions over vars, whose
+// object types are described by objects
+func newExprEnv(vars map[string]*exprpb.Type, objects exprObjects) (*cel.Env, error) {
+	registry := types.NewRegistry()
+	declarations := []*exprpb.Decl{
+		decls.NewFunction("inCIDR", decls.NewOverload("inCIDR_string_string",
+			[]*exprpb.Type{decls.String, decls.String}, decls.Bool)),
+	}
+	for name, t := range vars {
+		declarations = append(declarations, decls.NewVar(name, t))
+	}
+	return cel.NewEnv(
+		cel.CustomTypeProvider(&exprTypeProvider{TypeProvider: registry, objects: objects}),
+		cel.CustomTypeAdapter(registry),
+		cel.Declarations(declarations...),
+	)
+}
+
+// exprFunctions implements the functions that the expressions can call on
+// top of the standard ones of CEL
+var exprFunctions = []*functions.Overload{{
+	Operator: "inCIDR",
+	Binary: func(ip, cidr ref.Val) ref.Val {
+		ipString, ok := ip.(types.String)
+		cidrString, ok2 := cidr.(types.String)
+		if !ok || !ok2 {
+			return types.NoSuchOverloadErr()
+		}
+		_, network, err := net.ParseCIDR(string(cidrString))
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		parsed := net.ParseIP(string(ipString))
+		if parsed == nil {
+			return types.NewErr("invalid IP address %q", ipString)
+		}
+		return types.Bool(network.Contains(parsed))
+	},
+}}
+
+// compiledExpr is a type checked expression
+type compiledExpr struct {
+	program cel.Program
+	// uses holds the names of the variables that the expression refers to
+	uses map[string]bool
+}
+
+// compileExpr parses source and checks that it is a boolean expression over
+// the variables of env. The constant patterns of matches and the constant
+// networks of inCIDR are checked as well.
+func compileExpr(source string, env *cel.Env) (*compiledExpr, error) {
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !proto.Equal(ast.ResultType(), decls.Bool) {
+		return nil, fmt.Errorf("expression is of type %s, not bool", checker.FormatCheckedType(ast.ResultType()))
+	}
+	checked, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConstants(checked.Expr); err != nil {
+		return nil, err
+	}
+	uses := map[string]bool{}
+	for _, reference := range checked.ReferenceMap {
+		if reference.Name != "" {
+			uses[reference.Name] = true
+		}
+	}
+	program, err := env.Program(ast, cel.Functions(exprFunctions...))
+	if err != nil {
+		return nil, err
+	}
+	return &compiledExpr{program: program, uses: uses}, nil
+}
+
+// eval evaluates the expression with the given values of the variables
+func (e *compiledExpr) eval(vars map[string]interface{}) (bool, error) {
+	value, _, err := e.program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %s, not bool", value.Type().TypeName())
+	}
+	return bool(result), nil
+}
+
+// checkConstants checks the constant arguments of matches and inCIDR in e
+// and its subexpressions
+func checkConstants(e *exprpb.Expr) error {
+	switch kind := e.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		return checkConstants(kind.SelectExpr.Operand)
+	case *exprpb.Expr_CallExpr:
+		call := kind.CallExpr
+		args := call.Args
+		if call.Target != nil {
+			args = append([]*exprpb.Expr{call.Target}, args...)
+		}
+		for i, arg := range args {
+			if err := checkConstants(arg); err != nil {
+				return err
+			}
+			constant, ok := arg.ExprKind.(*exprpb.Expr_ConstExpr)
+			if !ok || i != len(args)-1 {
+				continue
+			}
+			value := constant.ConstExpr.GetStringValue()
+			switch call.Function {
+			case "matches":
+				if _, err := regexp.Compile(value); err != nil {
+					return err
+				}
+			case "inCIDR":
+				if _, _, err := net.ParseCIDR(value); err != nil {
+					return err
+				}
+			}
+		}
+	case *exprpb.Expr_ListExpr:
+		for _, element := range kind.ListExpr.Elements {
+			if err := checkConstants(element); err != nil {
+				return err
+			}
+		}
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range kind.StructExpr.Entries {
+			if key := entry.GetMapKey(); key != nil {
+				if err := checkConstants(key); err != nil {
+					return err
+				}
+			}
+			if err := checkConstants(entry.Value); err != nil {
+				return err
+			}
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := kind.ComprehensionExpr
+		for _, sub := range []*exprpb.Expr{comprehension.IterRange, comprehension.AccuInit,
+			comprehension.LoopCondition, comprehension.LoopStep, comprehension.Result} {
+			if err := checkConstants(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// protoMessage converts a message to the value of an object, holding all of
+// the fields of the message whether they are set or not
+func protoMessage(message protoreflect.Message) map[string]interface{} {
+	object := map[string]interface{}{}
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		object[string(fd.Name())] = protoValue(fd, message.Get(fd))
+	}
+	return object
+}
+
+// protoValue converts the value of the field fd of a message
+func protoValue(fd protoreflect.FieldDescriptor, value protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := value.List()
+		values := make([]interface{}, list.Len())
+		for i := range values {
+			values[i] = protoScalar(fd, list.Get(i))
+		}
+		return values
+	case fd.IsMap():
+		values := map[string]interface{}{}
+		value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			values[key.String()] = protoScalar(fd.MapValue(), value)
+			return true
+		})
+		return values
+	}
+	return protoScalar(fd, value)
+}
+
+func protoScalar(fd protoreflect.FieldDescriptor, value protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return value.Bool()
+	case protoreflect.StringKind:
+		return value.String()
+	case protoreflect.BytesKind:
+		return string(value.Bytes())
+	case protoreflect.EnumKind:
+		return int64(value.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessage(value.Message())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return int64(value.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return int64(value.Float())
+	}
+	return value.Int()
+}
+
+// protoType is the static type of the values of the field fd
+func protoType(fd protoreflect.FieldDescriptor) *exprpb.Type {
+	switch {
+	case fd.IsList():
+		return decls.NewListType(protoScalarType(fd))
+	case fd.IsMap():
+		return decls.NewMapType(decls.String, protoScalarType(fd.MapValue()))
+	}
+	return protoScalarType(fd)
+}
+
+func protoScalarType(fd protoreflect.FieldDescriptor) *exprpb.Type {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return decls.Bool
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return decls.String
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return decls.Dyn
+	}
+	return decls.Int
+}
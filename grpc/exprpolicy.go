@@ -0,0 +1,227 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/proto"
+)
+
+// ExpressionRule defines access rules whose condition is a CEL expression
+// over the call, e.g.
+//
+//	method == "/Rex/Kill" && request.signal != 15 && !("admin" in roles)
+//
+// The expressions can refer to these variables:
+//
+//	principal  string            the user ID of the caller
+//	groups     list(string)      the groups of the caller
+//	roles      list(string)      the roles of the caller
+//	method     string            the full name of the method, e.g. /Rex/Exec
+//	request    object            the fields of the request, as in rex.proto
+//	process    object            id, owner, group and labels of the process
+//	                             that the call acts on
+//	peer       object            address (host:port) and ip of the caller
+//	now        object            hour, minute, weekday (0 for Sunday) and
+//	                             unix, in UTC
+//
+// along with the standard functions and macros of CEL, and inCIDR(ip, cidr).
+// Expressions are type checked when the rule is created.
+type ExpressionRule struct {
+	Effect     string `validate:"oneof=allow deny"`
+	Expression string `validate:"required"`
+	// Priority puts the rule before the ones of lower priority in its
+	// policy set.
+	Priority int
+
+	expr *compiledExpr
+}
+
+// Enforce returns (lowercase(Effect) == "allow", true) if the expression
+// holds, (false, false) otherwise. Like SimpleAccessRules with Resource
+// conditions, rules that refer to process only apply once the process that
+// a call acts on is known, and the rest only before it.
+//
+// An expression that fails to evaluate, e.g. reads a field that the request
+// of the call does not have (including the request of the streaming calls),
+// an index out of range or divides by zero, applies to the call if the rule
+// denies, and does not apply if it allows, so that errors never let a call
+// through. As in CEL, && and || are decided by either of their operands
+// regardless of an error in the other one, hence
+//
+//	method == "/Rex/Kill" && request.signal != 15
+//	request.signal != 15 && method == "/Rex/Kill"
+//
+// both only apply to the Kill calls.
+func (r *ExpressionRule) Enforce(ctx context.Context) (bool, bool) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return false, false
+	}
+	resource, hasResource := rex.ResourceFromContext(ctx)
+	if hasResource != r.expr.uses["process"] {
+		return false, false
+	}
+
+	holds, err := r.expr.eval(expressionVariables(ctx, userID, resource, hasResource))
+	if err != nil {
+		log.Debugf("Policy %q: %v", r.Expression, err)
+		return false, !r.effect()
+	}
+	return r.effect(), holds
+}
+
+func (r *ExpressionRule) priority() int {
+	return r.Priority
+}
+
+func (r *ExpressionRule) effect() bool {
+	return strings.ToLower(r.Effect) == "allow"
+}
+
+// ExpressionRuleFromJSON creates an expression rule from its json
+// representation, with keys Effect and Expression
+func ExpressionRuleFromJSON(marshalledRule []byte) (*ExpressionRule, error) {
+	validate := newValidator()
+
+	var rule ExpressionRule
+	if err := json.Unmarshal(marshalledRule, &rule); err != nil {
+		return nil, err
+	}
+	rule.Effect = strings.ToLower(rule.Effect)
+	if err := validate.Struct(&rule); err != nil {
+		return nil, err
+	}
+	expr, err := compileExpr(rule.Expression, expressionEnv)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", rule.Expression, err)
+	}
+	rule.expr = expr
+
+	return &rule, nil
+}
+
+// expressionEnv declares the variables of the expressions
+var expressionEnv = mustExprEnv(map[string]*exprpb.Type{
+	"principal": decls.String,
+	"groups":    decls.NewListType(decls.String),
+	"roles":     decls.NewListType(decls.String),
+	"method":    decls.String,
+	"request":   decls.NewObjectType("rex.policy.Request"),
+	"process":   decls.NewObjectType("rex.policy.Process"),
+	"peer":      decls.NewObjectType("rex.policy.Peer"),
+	"now":       decls.NewObjectType("rex.policy.Now"),
+}, exprObjects{
+	"rex.policy.Request": requestFields(proto.File_rex_proto),
+	"rex.policy.Process": {
+		"id":     decls.String,
+		"owner":  decls.String,
+		"group":  decls.String,
+		"labels": decls.NewMapType(decls.String, decls.String),
+	},
+	"rex.policy.Peer": {
+		"address": decls.String,
+		"ip":      decls.String,
+	},
+	"rex.policy.Now": {
+		"hour":    decls.Int,
+		"minute":  decls.Int,
+		"weekday": decls.Int,
+		"unix":    decls.Int,
+	},
+})
+
+func mustExprEnv(vars map[string]*exprpb.Type, objects exprObjects) *cel.Env {
+	env, err := newExprEnv(vars, objects)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+// requestFields collects the fields of the requests of the unary methods of
+// the services in file, which are the ones whose request the policies get to
+// see. Fields of the same name and different types are dyn.
+func requestFields(file protoreflect.FileDescriptor) map[string]*exprpb.Type {
+	fields := map[string]*exprpb.Type{}
+	services := file.Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			method := methods.Get(j)
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				continue
+			}
+			messageFields := method.Input().Fields()
+			for k := 0; k < messageFields.Len(); k++ {
+				fd := messageFields.Get(k)
+				t := protoType(fd)
+				if existing, ok := fields[string(fd.Name())]; ok && !protov1.Equal(existing, t) {
+					t = decls.Dyn
+				}
+				fields[string(fd.Name())] = t
+			}
+		}
+	}
+	return fields
+}
+
+// expressionVariables gathers the values of the variables of the
+// expressions. The ones that are not available for the call, e.g. request
+// for streaming calls, are left out, and fail the expressions that use them.
+func expressionVariables(ctx context.Context, userID string, resource rex.Resource,
+	hasResource bool) map[string]interface{} {
+	identity, _ := identityFromContext(ctx)
+	method, _ := methodNameFromContext(ctx)
+	now := time.Now().UTC()
+	vars := map[string]interface{}{
+		"principal": userID,
+		"groups":    identity.Groups,
+		"roles":     identity.Roles,
+		"method":    method,
+		"now": map[string]interface{}{
+			"hour":    int64(now.Hour()),
+			"minute":  int64(now.Minute()),
+			"weekday": int64(now.Weekday()),
+			"unix":    now.Unix(),
+		},
+	}
+	if req, ok := requestFromContext(ctx); ok {
+		if message, ok := req.(protoreflect.ProtoMessage); ok {
+			vars["request"] = protoMessage(message.ProtoReflect())
+		}
+	}
+	if hasResource {
+		group := ""
+		if resource.GroupID != uuid.Nil {
+			group = resource.GroupID.String()
+		}
+		vars["process"] = map[string]interface{}{
+			"id":     resource.ProcessID.String(),
+			"owner":  resource.OwnerID,
+			"group":  group,
+			"labels": resource.Labels,
+		}
+	}
+	if address, ok := peerAddressFromContext(ctx); ok {
+		ip := address
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			ip = host
+		}
+		vars["peer"] = map[string]interface{}{"address": address, "ip": ip}
+	}
+	return vars
+}
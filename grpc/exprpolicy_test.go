@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/peer"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/proto"
+)
+
+func TestExpressionRule_Enforce(t *testing.T) {
+	ctx := rex.WithRoles(rex.WithUserID(context.Background(), "alice"), []string{"dev"})
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 4321}})
+	kill := withRequest(withMethodName(ctx, "/Rex/Kill"), &proto.KillRequest{Signal: 9})
+	exec := withRequest(withMethodName(ctx, "/Rex/Exec"), &proto.ExecRequest{
+		Path: "/bin/echo", Args: []string{"-n", "hello"}, Labels: map[string]string{"env": "prod"}})
+	process := rex.WithResource(kill, rex.Resource{
+		ProcessID: uuid.New(), OwnerID: "bob", Labels: map[string]string{"env": "prod"}})
+
+	cases := []struct {
+		effect, expression string
+		ctx                context.Context
+		verdict, applies   bool
+	}{
+		{"deny", `method == "/Rex/Kill" && request.signal != 15 && !("admin" in roles)`, kill, false, true},
+		{"deny", `method == "/Rex/Kill" && request.signal != 15 && !("dev" in roles)`, kill, false, false},
+		{"allow", `request.path.startsWith("/bin/") && request.args.all(a, a.matches("^[-a-z]+$"))`, exec, true, true},
+		{"allow", `request.labels["env"] == "prod" && size(request.args) == 2`, exec, true, true},
+		{"allow", `inCIDR(peer.ip, "10.0.0.0/8") && principal == 'alice'`, exec, true, true},
+		{"deny", `!inCIDR(peer.ip, "192.168.0.0/16")`, exec, false, true},
+		{"allow", `now.hour >= 0 && now.hour < 24 && now.weekday < 7`, exec, true, true},
+		{"allow", `has(request.signal) ? request.signal == 9 : true`, exec, true, true},
+		{"allow", `has(request.signal) ? request.signal == 9 : false`, kill, true, true},
+		// Fails to evaluate, as Exec requests have no signal, which only
+		// applies to deny rules
+		{"allow", `request.signal == 9`, exec, false, false},
+		{"deny", `request.signal == 9`, exec, false, true},
+		{"deny", `request.args[5] == "x"`, exec, false, true},
+		{"deny", `now.hour / 0 == 1`, exec, false, true},
+		// && and || are decided by either operand regardless of the order
+		{"deny", `method == "/Rex/Kill" && request.signal != 15`, exec, false, false},
+		{"deny", `request.signal != 15 && method == "/Rex/Kill"`, exec, false, false},
+		{"deny", `method == "/Rex/Kill" && request.signal != 15`, kill, false, true},
+		{"deny", `request.signal != 15 && method == "/Rex/Kill"`, kill, false, true},
+		{"deny", `request.signal == 9 || principal == "alice"`, exec, false, true},
+		{"deny", `principal == "alice" || request.signal == 9`, exec, false, true},
+		{"deny", `request.signal == 9 || principal == "bob"`, exec, false, true},
+		{"deny", `request.signal == 9 && principal == "bob"`, exec, false, false},
+		// Only applies once the process is known
+		{"allow", `process.labels["env"] == "prod" && process.owner != principal`, kill, false, false},
+		{"allow", `process.labels["env"] == "prod" && process.owner != principal`, process, true, true},
+		{"allow", `method == "/Rex/Kill"`, process, false, false},
+	}
+	for _, c := range cases {
+		rule, err := ExpressionRuleFromJSON([]byte(`{"effect": "` + c.effect + `", "expression": ` +
+			quoteJSON(c.expression) + `}`))
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if verdict, applies := rule.Enforce(c.ctx); verdict != c.verdict || applies != c.applies {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", c.expression, c.verdict, c.applies, verdict, applies)
+		}
+	}
+}
+
+func TestExpressionRule_Streaming(t *testing.T) {
+	// Streaming calls have no request
+	ctx := rex.WithUserID(context.Background(), "alice")
+	watch := withMethodName(ctx, "/Rex/Watch")
+	upload := withMethodName(ctx, "/Rex/Upload")
+
+	cases := []struct {
+		effect, expression string
+		ctx                context.Context
+		verdict, applies   bool
+	}{
+		{"deny", `method == "/Rex/Watch"`, watch, false, true},
+		{"deny", `request.path.startsWith("/tmp/")`, upload, false, true},
+		{"deny", `method == "/Rex/Upload" && request.signal > 0`, upload, false, true},
+		{"deny", `method == "/Rex/Upload" && request.signal > 0`, watch, false, false},
+		{"deny", `principal == "bob" && has(request.path)`, upload, false, false},
+		{"deny", `principal == "alice" && has(request.path)`, upload, false, true},
+		{"allow", `method == "/Rex/Watch" || request.signal == 0`, watch, true, true},
+		{"allow", `method == "/Rex/Upload" && request.signal == 0`, upload, false, false},
+	}
+	for _, c := range cases {
+		rule, err := ExpressionRuleFromJSON([]byte(`{"effect": "` + c.effect + `", "expression": ` +
+			quoteJSON(c.expression) + `}`))
+		if err != nil {
+			t.Fatalf("%s: %v", c.expression, err)
+		}
+		if verdict, applies := rule.Enforce(c.ctx); verdict != c.verdict || applies != c.applies {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", c.expression, c.verdict, c.applies, verdict, applies)
+		}
+	}
+
+	// A deny rule that fails to evaluate denies the call, even if another
+	// rule allows it
+	enforcer := NewPolicyEnforcer(
+		&SimpleAccessRule{Principal: Patterns{"*"}, Action: Patterns{"*"}, Effect: "allow"},
+		mustExpressionRule(t, `{"effect": "deny", "expression": "request.path.startsWith(\"/tmp/\")"}`),
+	)
+	if verdict, _ := enforcer.Enforce(upload); verdict {
+		t.Errorf("Expected the upload to be denied")
+	}
+}
+
+func mustExpressionRule(t *testing.T, marshalledRule string) *ExpressionRule {
+	rule, err := ExpressionRuleFromJSON([]byte(marshalledRule))
+	if err != nil {
+		t.Fatalf("While creating the rule: %v", err)
+	}
+	return rule
+}
+
+func TestExpressionRule_TypeCheck(t *testing.T) {
+	for _, expression := range []string{
+		`method`,
+		`method == 1`,
+		`request.nonexistent == 1`,
+		`request.signal == "15"`,
+		`process.owner.startsWith(1)`,
+		`inCIDR(peer.ip, "10.0.0.0/33")`,
+		`principal.matches("(")`,
+		`unknown == 1`,
+		`roles.exists(r, r)`,
+		`size(1) == 1`,
+		`method == "/Rex/Exec" &&`,
+		`"unterminated`,
+	} {
+		if _, err := ExpressionRuleFromJSON([]byte(`{"effect": "deny", "expression": ` +
+			quoteJSON(expression) + `}`)); err == nil {
+			t.Errorf("Expected %s to be rejected", expression)
+		}
+	}
+}
+
+func TestPolicyFromJSON(t *testing.T) {
+	policy, err := PolicyFromJSON([]byte(`{"Effect": "Allow", "Expression": "true"}`))
+	if _, ok := policy.(*ExpressionRule); !ok || err != nil {
+		t.Errorf("Expected an ExpressionRule, got %T, %v", policy, err)
+	}
+	policy, err = PolicyFromJSON([]byte(`{"Principal": "*", "Action": "*", "Effect": "Allow"}`))
+	if _, ok := policy.(*SimpleAccessRule); !ok || err != nil {
+		t.Errorf("Expected a SimpleAccessRule, got %T, %v", policy, err)
+	}
+}
+
+func quoteJSON(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
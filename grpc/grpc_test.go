@@ -202,6 +202,10 @@ func (m *processServerMock) Exec(ctx context.Context, path string, args ...strin
 	m.t.Errorf("Not implemented")
 	return uuid.Nil, rex.ErrNotImplemented
 }
+func (m *processServerMock) ExecCommand(ctx context.Context, cmd rex.Command) (uuid.UUID, error) {
+	m.t.Errorf("Not implemented")
+	return uuid.Nil, rex.ErrNotImplemented
+}
 func (m *processServerMock) ListProcessInfo(ctx context.Context) ([]rex.ProcessInfo, error) {
 	m.t.Errorf("Not implemented")
 	return nil, rex.ErrNotImplemented
@@ -217,3 +221,7 @@ func (m *processServerMock) Read(ctx context.Context, processID uuid.UUID, targe
 	m.t.Errorf("Not implemented")
 	return nil, rex.ErrNotImplemented
 }
+func (m *processServerMock) Delete(ctx context.Context, processID uuid.UUID) error {
+	m.t.Errorf("Not implemented")
+	return rex.ErrNotImplemented
+}
@@ -0,0 +1,284 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// rolePrincipalPrefix marks the principals of the access rules that
+	// stand for the principals having a role
+	rolePrincipalPrefix = "role:"
+	// identityURIScheme is the scheme of the SAN URIs of the certificates
+	// that carry groups and roles, e.g. rex:group/ops or rex:role/oncall
+	identityURIScheme = "rex"
+)
+
+// GroupSource is a field of the client certificates that the groups of the
+// principals are read from: "OU", "O", "URI" or "OID:<dotted OID>". URI
+// reads the SAN URIs of the form rex:group/NAME, along with the roles in
+// the ones of the form rex:role/NAME. The extension named by an OID holds an
+// ASN.1 string, e.g. a UTF8String, or a sequence of them.
+type GroupSource string
+
+// ParseGroupSource parses the name of a GroupSource
+func ParseGroupSource(s string) (GroupSource, error) {
+	source := GroupSource(strings.ToUpper(s))
+	switch {
+	case source == "OU" || source == "O" || source == "URI":
+		return source, nil
+	case strings.HasPrefix(string(source), "OID:"):
+		if _, err := parseOID(string(source[len("OID:"):])); err != nil {
+			return "", err
+		}
+		return source, nil
+	}
+	return "", fmt.Errorf("unknown group source %q", s)
+}
+
+// ParseGroupSources parses a comma separated list of GroupSource names
+func ParseGroupSources(list string) ([]GroupSource, error) {
+	var sources []GroupSource
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		source, err := ParseGroupSource(name)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// RoleBindings assign groups and roles to principals on the server side.
+// Members are user IDs, or group:NAME for all of the members of a group.
+type RoleBindings struct {
+	// Groups maps the names of the groups to their members, which can
+	// only be user IDs.
+	Groups map[string][]string
+	// Roles maps the names of the roles to the principals having them.
+	Roles map[string][]string
+}
+
+// RoleBindingsFromJSON creates role bindings from their json representation
+func RoleBindingsFromJSON(marshalledBindings []byte) (*RoleBindings, error) {
+	var bindings RoleBindings
+	if err := json.Unmarshal(marshalledBindings, &bindings); err != nil {
+		return nil, err
+	}
+	for group, members := range bindings.Groups {
+		for _, member := range members {
+			if member == "" || strings.HasPrefix(member, rex.GroupPrincipalPrefix) {
+				return nil, fmt.Errorf("group %s: members must be user IDs, got %q", group, member)
+			}
+		}
+	}
+	for role, members := range bindings.Roles {
+		for _, member := range members {
+			if member == "" || member == rex.GroupPrincipalPrefix {
+				return nil, fmt.Errorf("role %s: bad member %q", role, member)
+			}
+		}
+	}
+	return &bindings, nil
+}
+
+// IdentityMapper finds the groups and the roles of the principals from their
+// certificates and the role bindings of the server.
+type IdentityMapper struct {
+	sources  []GroupSource
+	bindings RoleBindings
+}
+
+// NewIdentityMapper creates an IdentityMapper reading the groups from the
+// given fields of the certificates. bindings may be nil.
+func NewIdentityMapper(bindings *RoleBindings, sources ...GroupSource) *IdentityMapper {
+	m := &IdentityMapper{sources: sources}
+	if bindings != nil {
+		m.bindings = *bindings
+	}
+	return m
+}
+
+// identity finds the groups and the roles of userID, who has presented cert
+// if it is not nil, or has been vouched for by a trusted proxy to be in the
+// given groups and have the given roles otherwise
+func (m *IdentityMapper) identity(userID string, cert *x509.Certificate,
+	groups, roles []string) rex.Identity {
+	groupSet, roleSet := toSet(groups), toSet(roles)
+	if cert != nil {
+		certGroups, certRoles := m.certificateMemberships(cert)
+		for _, group := range certGroups {
+			groupSet[group] = true
+		}
+		for _, role := range certRoles {
+			roleSet[role] = true
+		}
+	}
+	for group, members := range m.bindings.Groups {
+		if toSet(members)[userID] {
+			groupSet[group] = true
+		}
+	}
+	for role, members := range m.bindings.Roles {
+		for _, member := range members {
+			if member == userID || (strings.HasPrefix(member, rex.GroupPrincipalPrefix) &&
+				groupSet[strings.TrimPrefix(member, rex.GroupPrincipalPrefix)]) {
+				roleSet[role] = true
+			}
+		}
+	}
+	return rex.Identity{UserID: userID, Groups: sortedKeys(groupSet), Roles: sortedKeys(roleSet)}
+}
+
+// certificateMemberships reads the groups and the roles in the fields of cert
+// that the mapper is configured with
+func (m *IdentityMapper) certificateMemberships(cert *x509.Certificate) ([]string, []string) {
+	var groups, roles []string
+	for _, source := range m.sources {
+		switch source {
+		case "OU":
+			groups = append(groups, cert.Subject.OrganizationalUnit...)
+		case "O":
+			groups = append(groups, cert.Subject.Organization...)
+		case "URI":
+			for _, uri := range cert.URIs {
+				if uri.Scheme != identityURIScheme {
+					continue
+				}
+				if name := strings.TrimPrefix(uri.Opaque, "group/"); name != uri.Opaque && name != "" {
+					groups = append(groups, name)
+				} else if name := strings.TrimPrefix(uri.Opaque, "role/"); name != uri.Opaque && name != "" {
+					roles = append(roles, name)
+				}
+			}
+		default:
+			oid, _ := parseOID(string(source[len("OID:"):]))
+			groups = append(groups, extensionStrings(cert, oid)...)
+		}
+	}
+	return groups, roles
+}
+
+// extensionStrings decodes the extension of cert with the given OID, which
+// holds a string, e.g. a UTF8String, or a sequence of them
+func extensionStrings(cert *x509.Certificate, oid asn1.ObjectIdentifier) []string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+		var values []string
+		if _, err := asn1.Unmarshal(ext.Value, &values); err == nil {
+			return values
+		}
+		var value string
+		if _, err := asn1.Unmarshal(ext.Value, &value); err == nil {
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(s, ".") {
+		var n int
+		if _, err := fmt.Sscanf(part, "%d", &n); err != nil || fmt.Sprint(n) != part || n < 0 {
+			return nil, fmt.Errorf("bad OID %q", s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("bad OID %q", s)
+	}
+	return oid, nil
+}
+
+// IdentityInterceptor adds the groups and the roles of the caller to the
+// request context. Must come after AuthInfoInterceptor and
+// TrustedProxyInterceptor.
+func IdentityInterceptor(m *IdentityMapper) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
+		return handler(withIdentity(ctx, m), req)
+	}
+}
+
+// IdentityStreamInterceptor is the streaming counterpart of
+// IdentityInterceptor.
+func IdentityStreamInterceptor(m *IdentityMapper) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := withIdentity(ss.Context(), m)
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func withIdentity(ctx context.Context, m *IdentityMapper) context.Context {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	cert, _ := peerCertificateFromContext(ctx)
+	groups, _ := rex.GroupsFromContext(ctx)
+	roles, _ := rex.RolesFromContext(ctx)
+	identity := m.identity(userID, cert, groups, roles)
+	return rex.WithRoles(rex.WithGroups(ctx, identity.Groups), identity.Roles)
+}
+
+// identityFromContext returns the identity of the caller as found by
+// IdentityInterceptor
+func identityFromContext(ctx context.Context) (rex.Identity, bool) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return rex.Identity{}, false
+	}
+	groups, _ := rex.GroupsFromContext(ctx)
+	roles, _ := rex.RolesFromContext(ctx)
+	return rex.Identity{UserID: userID, Groups: groups, Roles: roles}, true
+}
+
+// matchPrincipal reports whether principal, which is a user ID,
+// group:NAME or role:NAME, stands for the caller in ctx. The user ID and the
+// names can be glob patterns.
+func matchPrincipal(ctx context.Context, principal string) bool {
+	identity, ok := identityFromContext(ctx)
+	if !ok {
+		return false
+	}
+	switch {
+	case principal == "*":
+		return true
+	case strings.HasPrefix(principal, rex.GroupPrincipalPrefix):
+		return Patterns{strings.TrimPrefix(principal, rex.GroupPrincipalPrefix)}.matchAny(identity.Groups)
+	case strings.HasPrefix(principal, rolePrincipalPrefix):
+		return Patterns{strings.TrimPrefix(principal, rolePrincipalPrefix)}.matchAny(identity.Roles)
+	}
+	return matchGlob(principal, identity.UserID)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
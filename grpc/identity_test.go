@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/farnasirim/rex"
+)
+
+func TestIdentityMapper_Identity(t *testing.T) {
+	bindings, err := RoleBindingsFromJSON([]byte(`
+	{"groups": {"auditors": ["alice"]}, "roles": {"oncall": ["group:ops"], "admin": ["bob"]}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating role bindings from JSON: %v", err)
+	}
+	sources, err := ParseGroupSources("ou, uri, oid:1.3.6.1.4.1.99999.1")
+	if err != nil {
+		t.Fatalf("Caught error while parsing the group sources: %v", err)
+	}
+	mapper := NewIdentityMapper(bindings, sources...)
+
+	extension, _ := asn1.MarshalWithParams("builders", "utf8")
+	cert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops"}, Organization: []string{"acme"}},
+		URIs: []*url.URL{{Scheme: "rex", Opaque: "role/deployer"},
+			{Scheme: "spiffe", Host: "acme", Path: "/group/ignored"}},
+		Extensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: extension}},
+	}
+	identity := mapper.identity("alice", cert, nil, nil)
+	expected := rex.Identity{
+		UserID: "alice",
+		Groups: []string{"auditors", "builders", "ops"},
+		Roles:  []string{"deployer", "oncall"},
+	}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, identity)
+	}
+
+	// The groups vouched for by a trusted proxy are taken as they are
+	identity = mapper.identity("bob", nil, []string{"ops"}, nil)
+	if !reflect.DeepEqual(identity.Roles, []string{"admin", "oncall"}) {
+		t.Errorf("Expected the roles of bob and of the ops group, got %v", identity.Roles)
+	}
+
+	for _, source := range []string{"CN", "OID:1", "OID:1.x"} {
+		if _, err := ParseGroupSource(source); err == nil {
+			t.Errorf("Expected group source %q to be rejected", source)
+		}
+	}
+}
+
+func TestSimpleAccessRule_GroupPrincipal(t *testing.T) {
+	rule, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "group:ops", "effect": "allow", "action": "/Rex/Kill"}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+	ctx := withMethodName(rex.WithUserID(context.Background(), "alice"), "/Rex/Kill")
+	if _, applies := rule.Enforce(ctx); applies {
+		t.Errorf("Expected the rule not to apply to a user outside of the group")
+	}
+	if _, applies := rule.Enforce(rex.WithGroups(ctx, []string{"dev", "ops"})); !applies {
+		t.Errorf("Expected the rule to apply to a member of the group")
+	}
+
+	rule, err = SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "group:team-a-*", "effect": "allow", "action": "/Rex/Kill"}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+	if _, applies := rule.Enforce(rex.WithGroups(ctx, []string{"team-a-build"})); !applies {
+		t.Errorf("Expected the rule to apply to a member of a matching group")
+	}
+}
@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// onBehalfOfHeader carries the principal that a trusted proxy is
+	// forwarding a request for
+	onBehalfOfHeader = "rex-on-behalf-of"
+	// onBehalfOfGroupHeader and onBehalfOfRoleHeader carry the groups and
+	// the roles of the principal, one per value
+	onBehalfOfGroupHeader = "rex-on-behalf-of-group"
+	onBehalfOfRoleHeader  = "rex-on-behalf-of-role"
+	// onBehalfOfAddressHeader carries the address that the principal has
+	// called the proxy from
+	onBehalfOfAddressHeader = "rex-on-behalf-of-address"
+)
+
+// TrustedProxyInterceptor lets the given principals, e.g. a federation
+// proxy, make requests on behalf of other principals. The user ID that
+// AuthInfoInterceptor has added to the context is replaced with the one in
+// the request metadata if the caller is trusted, along with the groups and
+// the roles of the principal that the proxy has found. Requests of untrusted
+// callers carrying such metadata are rejected.
+func TrustedProxyInterceptor(trusted ...string) grpc.UnaryServerInterceptor {
+	trustedSet := toSet(trusted)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, err = withProxiedUserID(ctx, trustedSet)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TrustedProxyStreamInterceptor is the streaming counterpart of
+// TrustedProxyInterceptor.
+func TrustedProxyStreamInterceptor(trusted ...string) grpc.StreamServerInterceptor {
+	trustedSet := toSet(trusted)
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := withProxiedUserID(ss.Context(), trustedSet)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func withProxiedUserID(ctx context.Context, trusted map[string]bool) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(onBehalfOfHeader)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+	proxyID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, rex.ErrUnauthenticated.Error())
+	}
+	if !trusted[proxyID] || len(values) > 1 || values[0] == "" {
+		return nil, status.Errorf(codes.PermissionDenied, rex.ErrAccessDenied.Error())
+	}
+
+	log.Debugf("%s acting on behalf of %s", proxyID, values[0])
+	// The certificate is the one of the proxy, not of the principal
+	ctx = withPeerCertificate(ctx, nil)
+	ctx = rex.WithGroups(ctx, md.Get(onBehalfOfGroupHeader))
+	ctx = rex.WithRoles(ctx, md.Get(onBehalfOfRoleHeader))
+	// The address is unknown, rather than the one of the proxy, unless
+	// the proxy forwards it
+	var address string
+	if addresses := md.Get(onBehalfOfAddressHeader); len(addresses) == 1 {
+		address = addresses[0]
+	}
+	ctx = withPeerAddress(ctx, address)
+	return rex.WithUserID(ctx, values[0]), nil
+}
+
+// OnBehalfOfClientInterceptor forwards the user ID in the context of the
+// call, if any, to be picked up by TrustedProxyInterceptor on the server.
+func OnBehalfOfClientInterceptor(
+	ctx context.Context,
+	method string,
+	req,
+	reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption) error {
+
+	return invoker(withOnBehalfOf(ctx), method, req, reply, cc, opts...)
+}
+
+// OnBehalfOfStreamClientInterceptor is the streaming counterpart of
+// OnBehalfOfClientInterceptor.
+func OnBehalfOfStreamClientInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+	return streamer(withOnBehalfOf(ctx), desc, cc, method, opts...)
+}
+
+func withOnBehalfOf(ctx context.Context) context.Context {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	kv := []string{onBehalfOfHeader, userID}
+	groups, _ := rex.GroupsFromContext(ctx)
+	for _, group := range groups {
+		kv = append(kv, onBehalfOfGroupHeader, group)
+	}
+	roles, _ := rex.RolesFromContext(ctx)
+	for _, role := range roles {
+		kv = append(kv, onBehalfOfRoleHeader, role)
+	}
+	if address, ok := peerAddressFromContext(ctx); ok {
+		kv = append(kv, onBehalfOfAddressHeader, address)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
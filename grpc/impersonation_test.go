@@ -0,0 +1,40 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/farnasirim/rex"
+	rex_grpc "github.com/farnasirim/rex/grpc"
+)
+
+func TestTrustedProxyInterceptor(t *testing.T) {
+	interceptor := rex_grpc.TrustedProxyInterceptor("proxy")
+	userIDHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		userID, _ := rex.UserIDFromContext(ctx)
+		return userID, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/Rex/Exec"}
+
+	proxied := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("rex-on-behalf-of", "alice"))
+	userID, err := interceptor(rex.WithUserID(proxied, "proxy"), nil, info, userIDHandler)
+	if err != nil || userID != "alice" {
+		t.Errorf("Expected the trusted proxy to act on behalf of %q, got %v, %v", "alice", userID, err)
+	}
+
+	_, err = interceptor(rex.WithUserID(proxied, "mallory"), nil, info, userIDHandler)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected code %v for an untrusted caller, got %v", codes.PermissionDenied, err)
+	}
+
+	userID, err = interceptor(rex.WithUserID(context.Background(), "mallory"), nil, info, userIDHandler)
+	if err != nil || userID != "mallory" {
+		t.Errorf("Expected requests without metadata to be left untouched, got %v, %v", userID, err)
+	}
+}
@@ -0,0 +1,158 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyFile implements Policy with the rules of a YAML or JSON file, which
+// can be reloaded while the server is running. The file holds a PolicySet:
+// a list of SimpleAccessRules, ExpressionRules and nested PolicySets under
+// the Policies key, and optionally the Algorithm that combines them:
+//
+//	algorithm: first-applicable
+//	policies:
+//	  - principal: "*"
+//	    action: "*"
+//	    effect: allow
+type PolicyFile struct {
+	path string
+
+	// m serializes the reloads
+	m       sync.Mutex
+	modTime time.Time
+	size    int64
+	// set holds the *PolicySet of the rules that are in effect
+	set atomic.Value
+}
+
+// NewPolicyFile loads the rules of the file at path. Fails if the file cannot
+// be read or any of its rules is invalid.
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Enforce applies the rules of the file that are in effect, as PolicySet
+// does.
+func (f *PolicyFile) Enforce(ctx context.Context) (bool, bool) {
+	return f.set.Load().(*PolicySet).Enforce(ctx)
+}
+
+// Reload reads the file again and puts its rules in effect all at once. The
+// rules in effect are kept if the file cannot be read or any of its rules is
+// invalid.
+func (f *PolicyFile) Reload() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	// Recorded even if the file turns out to be invalid, so that Watch
+	// waits for it to change again
+	f.modTime, f.size = stat.ModTime(), stat.Size()
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	set, err := PolicySetFromYAML(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.set.Store(set)
+	log.Infof("Loaded %d policies from %s", len(set.enforcer.policies), f.path)
+	return nil
+}
+
+// Watch reloads the file whenever it changes, checking it every interval,
+// until ctx is done. Failed reloads are logged.
+func (f *PolicyFile) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !f.changed() {
+			continue
+		}
+		if err := f.Reload(); err != nil {
+			log.Errorf("Keeping the current policies: %v", err)
+		}
+	}
+}
+
+func (f *PolicyFile) changed() bool {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	f.m.Lock()
+	defer f.m.Unlock()
+	return !stat.ModTime().Equal(f.modTime) || stat.Size() != f.size
+}
+
+// PolicySetFromYAML creates the policy set of a YAML document, which may as
+// well be JSON, with the Policies and Algorithm keys of PolicySetFromJSON
+func PolicySetFromYAML(content []byte) (*PolicySet, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	for key := range document {
+		// Matches the keys of the policies, which are case insensitive
+		if !strings.EqualFold(key, "policies") && !strings.EqualFold(key, "algorithm") {
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	marshalledSet, err := json.Marshal(jsonCompatible(document))
+	if err != nil {
+		return nil, err
+	}
+	return PolicySetFromJSON(marshalledSet)
+}
+
+// jsonCompatible converts the maps that yaml.v2 decodes, whose keys are
+// interface{}, to ones that encoding/json can encode
+func jsonCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[key] = jsonCompatible(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = jsonCompatible(item)
+		}
+		return converted
+	}
+	return value
+}
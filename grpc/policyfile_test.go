@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/farnasirim/rex"
+)
+
+func TestPolicyFile_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rex-policies")
+	if err != nil {
+		t.Fatalf("While creating the temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policies.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("While writing the policy file: %v", err)
+		}
+	}
+
+	write(`
+policies:
+  - principal: alice
+    action: /Rex/Exec
+    effect: allow
+  - effect: deny
+    expression: method == "/Rex/Exec" && principal != "alice"
+`)
+	policyFile, err := NewPolicyFile(path)
+	if err != nil {
+		t.Fatalf("While loading the policy file: %v", err)
+	}
+	ctx := withMethodName(rex.WithUserID(context.Background(), "alice"), "/Rex/Exec")
+	if verdict, applies := policyFile.Enforce(ctx); !verdict || !applies {
+		t.Errorf("Expected the policy of the file to allow the call, got (%v, %v)", verdict, applies)
+	}
+
+	write(`{"Policies": [{"Principal": "alice", "Action": "/Rex/Nonexistent", "Effect": "allow"}]}`)
+	if err := policyFile.Reload(); err == nil {
+		t.Errorf("Expected an invalid policy file to be rejected")
+	}
+	if verdict, applies := policyFile.Enforce(ctx); !verdict || !applies {
+		t.Errorf("Expected the previous policies to be kept, got (%v, %v)", verdict, applies)
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go policyFile.Watch(watchCtx, 10*time.Millisecond)
+	write(`{"Policies": [{"Principal": "alice", "Action": "/Rex/Exec", "Effect": "deny"}]}`)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if verdict, _ := policyFile.Enforce(ctx); !verdict {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the changed policy file to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
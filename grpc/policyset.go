@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CombiningAlgorithm decides the verdict of a PolicyEnforcer from the ones
+// of its policies that apply
+type CombiningAlgorithm string
+
+const (
+	// DenyOverrides denies if any of the policies denies
+	DenyOverrides CombiningAlgorithm = "deny-overrides"
+	// PermitOverrides allows if any of the policies allows
+	PermitOverrides CombiningAlgorithm = "permit-overrides"
+	// FirstApplicable follows the first policy that applies
+	FirstApplicable CombiningAlgorithm = "first-applicable"
+)
+
+// ParseCombiningAlgorithm parses the name of a CombiningAlgorithm
+func ParseCombiningAlgorithm(s string) (CombiningAlgorithm, error) {
+	switch algorithm := CombiningAlgorithm(strings.ToLower(s)); algorithm {
+	case DenyOverrides, PermitOverrides, FirstApplicable:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown combining algorithm %q", s)
+}
+
+// PolicySet is a named group of policies, e.g. the ones of a team, which is
+// combined with its own algorithm and takes part in the enclosing set as a
+// single policy. A set applies if any of its policies does.
+type PolicySet struct {
+	Name      string
+	Algorithm CombiningAlgorithm
+	// Priority puts the set before the policies of lower priority in the
+	// enclosing set.
+	Priority int
+
+	enforcer *PolicyEnforcer
+}
+
+// Enforce applies the policies of the set, as its PolicyEnforcer does.
+func (s *PolicySet) Enforce(ctx context.Context) (bool, bool) {
+	return s.enforcer.Enforce(ctx)
+}
+
+func (s *PolicySet) priority() int {
+	return s.Priority
+}
+
+// PolicySetFromJSON creates a policy set from its json representation, with
+// keys Name, Algorithm (DenyOverrides by default), Priority and Policies.
+// Each of the Policies is created with PolicyFromJSON, so sets can be
+// nested.
+func PolicySetFromJSON(marshalledSet []byte) (*PolicySet, error) {
+	validate := newValidator()
+
+	var set struct {
+		Name      string
+		Algorithm string `validate:"omitempty,oneof=deny-overrides permit-overrides first-applicable"`
+		Priority  int
+		Policies  []json.RawMessage `validate:"min=1"`
+	}
+	if err := json.Unmarshal(marshalledSet, &set); err != nil {
+		return nil, err
+	}
+	set.Algorithm = strings.ToLower(set.Algorithm)
+	if err := validate.Struct(&set); err != nil {
+		return nil, err
+	}
+	algorithm := DenyOverrides
+	if set.Algorithm != "" {
+		algorithm = CombiningAlgorithm(set.Algorithm)
+	}
+
+	policies := make([]Policy, 0, len(set.Policies))
+	for i, marshalledPolicy := range set.Policies {
+		policy, err := PolicyFromJSON(marshalledPolicy)
+		if err != nil {
+			if set.Name != "" {
+				return nil, fmt.Errorf("policy set %s: policy %d: %w", set.Name, i, err)
+			}
+			return nil, fmt.Errorf("policy %d: %w", i, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return &PolicySet{
+		Name:      set.Name,
+		Algorithm: algorithm,
+		Priority:  set.Priority,
+		enforcer:  NewCombiningPolicyEnforcer(algorithm, policies...),
+	}, nil
+}
+
+// PolicyFromJSON creates a PolicySet if the json representation of a policy
+// has a Policies key, an ExpressionRule if it has an Expression key, and a
+// SimpleAccessRule otherwise
+func PolicyFromJSON(marshalledPolicy []byte) (Policy, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(marshalledPolicy, &keys); err != nil {
+		return nil, err
+	}
+	for key := range keys {
+		switch {
+		case strings.EqualFold(key, "policies"):
+			return PolicySetFromJSON(marshalledPolicy)
+		case strings.EqualFold(key, "expression"):
+			return ExpressionRuleFromJSON(marshalledPolicy)
+		}
+	}
+	return SimpleAccessRuleFromJSON(marshalledPolicy)
+}
+
+// policyPriority is the Priority of the policies that have one, and 0 for
+// the rest
+func policyPriority(policy Policy) int {
+	if p, ok := policy.(interface{ priority() int }); ok {
+		return p.priority()
+	}
+	return 0
+}
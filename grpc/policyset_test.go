@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/farnasirim/rex"
+)
+
+func TestPolicyEnforcer_Algorithms(t *testing.T) {
+	allow := &policyMock{func(context.Context) (bool, bool) { return true, true }}
+	deny := &policyMock{func(context.Context) (bool, bool) { return false, true }}
+	skip := &policyMock{func(context.Context) (bool, bool) { return true, false }}
+
+	cases := []struct {
+		algorithm        CombiningAlgorithm
+		policies         []Policy
+		verdict, applies bool
+	}{
+		{DenyOverrides, []Policy{allow, deny}, false, true},
+		{DenyOverrides, []Policy{skip, allow}, true, true},
+		{PermitOverrides, []Policy{deny, allow}, true, true},
+		{PermitOverrides, []Policy{deny, skip}, false, true},
+		{FirstApplicable, []Policy{skip, deny, allow}, false, true},
+		{FirstApplicable, []Policy{skip, allow, deny}, true, true},
+		{FirstApplicable, []Policy{skip}, false, false},
+	}
+	for i, c := range cases {
+		verdict, applies := NewCombiningPolicyEnforcer(c.algorithm, c.policies...).Enforce(context.Background())
+		if verdict != c.verdict || applies != c.applies {
+			t.Errorf("case %d (%s): expected (%v, %v), got (%v, %v)",
+				i, c.algorithm, c.verdict, c.applies, verdict, applies)
+		}
+	}
+}
+
+func TestPolicySet_Priorities(t *testing.T) {
+	// The team leads override the default of the team, wherever they are
+	// listed
+	set, err := PolicySetFromYAML([]byte(`
+algorithm: deny-overrides
+policies:
+  - name: team-a
+    algorithm: first-applicable
+    policies:
+      - principal: group:team-a
+        action: /Rex/Kill
+        effect: deny
+      - principal: role:lead
+        action: /Rex/Kill
+        effect: allow
+        priority: 10
+      - principal: group:team-a
+        action: "*"
+        effect: allow
+`))
+	if err != nil {
+		t.Fatalf("While creating the policy set: %v", err)
+	}
+
+	ctx := rex.WithGroups(rex.WithUserID(context.Background(), "alice"), []string{"team-a"})
+	kill := withMethodName(ctx, "/Rex/Kill")
+	if verdict, applies := set.Enforce(kill); verdict || !applies {
+		t.Errorf("Expected the team default to deny the member, got (%v, %v)", verdict, applies)
+	}
+	if verdict, applies := set.Enforce(rex.WithRoles(kill, []string{"lead"})); !verdict || !applies {
+		t.Errorf("Expected the lead to be allowed, got (%v, %v)", verdict, applies)
+	}
+	if verdict, applies := set.Enforce(withMethodName(ctx, "/Rex/Exec")); !verdict || !applies {
+		t.Errorf("Expected the member to be allowed to exec, got (%v, %v)", verdict, applies)
+	}
+
+	for _, invalid := range []string{
+		`{"Algorithm": "most-recent", "Policies": [{"Effect": "allow", "Expression": "true"}]}`,
+		`{"Name": "empty", "Policies": []}`,
+		`{"Policies": [{"Effect": "allow", "Expression": "nope"}]}`,
+	} {
+		if _, err := PolicyFromJSON([]byte(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// RetryAfterKey is the trailer metadata key which carries the time after
+// which a rate limited request can be retried, formatted as a Go duration
+// (e.g. "250ms").
+const RetryAfterKey = "retry-after"
+
+// RateLimitRule limits the rate of the calls that a principal can make to a
+// method using a token bucket which holds up to Burst tokens and is refilled
+// at Rate tokens per second. Principal and Method are glob patterns as in
+// SimpleAccessRule, e.g. /Rex/Get*, and a single bucket is shared by all of
+// the methods that a rule matches for each principal.
+type RateLimitRule struct {
+	// Principal lists user IDs, or patterns matching them.
+	Principal Patterns `validate:"min=1,dive,required"`
+	// Method lists the full names of the methods, e.g. /Rex/Exec. Each
+	// pattern has to match at least one of Actions().
+	Method Patterns `validate:"min=1,dive,action"`
+	Rate   float64  `validate:"gt=0"`
+	// Burst defaults to Rate rounded up.
+	Burst int `validate:"gte=0"`
+}
+
+// RateLimitRuleFromJSON creates a rate limit rule from its json
+// representation, e.g. {"Principal": "*", "Method": "/Rex/Exec", "Rate": 10}
+func RateLimitRuleFromJSON(marshalledRule []byte) (*RateLimitRule, error) {
+	validate := newValidator()
+
+	var rule RateLimitRule
+	if err := json.Unmarshal(marshalledRule, &rule); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(&rule); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (r *RateLimitRule) burst() float64 {
+	if r.Burst == 0 {
+		return math.Ceil(r.Rate)
+	}
+	return float64(r.Burst)
+}
+
+// specificity orders the rules that match the same call. Exact principals
+// take precedence over exact methods, and patterns over "*".
+func (r *RateLimitRule) specificity() int {
+	return 3*r.Principal.specificity() + r.Method.specificity()
+}
+
+type bucketKey struct {
+	principal string
+	rule      *RateLimitRule
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter applies the most specific RateLimitRule matching each call.
+// Calls that no rule matches are not limited.
+type RateLimiter struct {
+	rules []*RateLimitRule
+	now   func() time.Time
+
+	m       sync.Mutex
+	buckets map[bucketKey]*tokenBucket
+}
+
+// NewRateLimiter creates a RateLimiter from a list of rules. Among equally
+// specific rules matching a call, the first one is applied.
+func NewRateLimiter(rules ...*RateLimitRule) *RateLimiter {
+	return &RateLimiter{
+		rules:   rules,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*tokenBucket),
+	}
+}
+
+// Allow takes a token for a call of principal to method, and reports
+// whether there has been one. Otherwise it returns how long it takes until
+// the next token is available.
+func (l *RateLimiter) Allow(principal, method string) (bool, time.Duration) {
+	rule := l.match(principal, method)
+	if rule == nil {
+		return true, 0
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := l.now()
+	key := bucketKey{principal: principal, rule: rule}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rule.burst(), last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(rule.burst(),
+		bucket.tokens+now.Sub(bucket.last).Seconds()*rule.Rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rule.Rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) match(principal, method string) *RateLimitRule {
+	var best *RateLimitRule
+	for _, rule := range l.rules {
+		if !rule.Principal.match(principal) || !rule.Method.match(method) {
+			continue
+		}
+		if best == nil || rule.specificity() > best.specificity() {
+			best = rule
+		}
+	}
+	return best
+}
+
+// limit returns a ResourceExhausted error along with the trailer to send if
+// the call is not allowed.
+func (l *RateLimiter) limit(ctx context.Context, method string) (metadata.MD, error) {
+	userID, _ := rex.UserIDFromContext(ctx)
+	if allowed, wait := l.Allow(userID, method); !allowed {
+		// Rounded up so that retrying right after the wait succeeds
+		wait = (wait + time.Millisecond - 1).Truncate(time.Millisecond)
+		return metadata.Pairs(RetryAfterKey, wait.String()),
+			status.Errorf(codes.ResourceExhausted,
+				"rate limit exceeded for %s, retry after %v", method, wait)
+	}
+	return nil, nil
+}
+
+// RateLimitInterceptor rejects the calls exceeding the limits of limiter.
+// Must come after AuthInfoInterceptor.
+func RateLimitInterceptor(limiter *RateLimiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+		if trailer, err := limiter.limit(ctx, info.FullMethod); err != nil {
+			if trailerErr := grpc.SetTrailer(ctx, trailer); trailerErr != nil {
+				log.Warnf("Failed to set the retry-after trailer: %v", trailerErr)
+			}
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor is the streaming counterpart of
+// RateLimitInterceptor.
+func RateLimitStreamInterceptor(limiter *RateLimiter) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if trailer, err := limiter.limit(ss.Context(), info.FullMethod); err != nil {
+			ss.SetTrailer(trailer)
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
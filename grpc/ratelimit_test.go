@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/farnasirim/rex"
+)
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	rule, err := RateLimitRuleFromJSON([]byte(`
+	{"principal": "*", "method": "/Rex/Exec", "rate": 2, "burst": 2}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating rate limit rule from JSON: %v", err)
+	}
+	limiter := NewRateLimiter(rule)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("user", "/Rex/Exec"); !allowed {
+			t.Fatalf("Expected call %d to be allowed within the burst", i)
+		}
+	}
+	allowed, wait := limiter.Allow("user", "/Rex/Exec")
+	if allowed || wait != 500*time.Millisecond {
+		t.Errorf("Expected (false, 500ms), got (%v, %v)", allowed, wait)
+	}
+	if allowed, _ := limiter.Allow("other", "/Rex/Exec"); !allowed {
+		t.Errorf("Expected principals to have separate buckets")
+	}
+	if allowed, _ := limiter.Allow("user", "/Rex/Read"); !allowed {
+		t.Errorf("Expected methods without a matching rule to not be limited")
+	}
+
+	now = now.Add(wait)
+	if allowed, _ := limiter.Allow("user", "/Rex/Exec"); !allowed {
+		t.Errorf("Expected the bucket to be refilled after %v", wait)
+	}
+}
+
+func TestRateLimiter_MostSpecificRule(t *testing.T) {
+	limiter := NewRateLimiter(
+		&RateLimitRule{Principal: Patterns{"vip"}, Method: Patterns{"*"}, Rate: 100},
+		&RateLimitRule{Principal: Patterns{"*"}, Method: Patterns{"/Rex/ListProcessInfo"}, Rate: 1},
+	)
+
+	for i := 0; i < 10; i++ {
+		if allowed, _ := limiter.Allow("vip", "/Rex/ListProcessInfo"); !allowed {
+			t.Fatalf("Expected the principal rule to take precedence")
+		}
+	}
+	limiter.Allow("user", "/Rex/ListProcessInfo")
+	if allowed, _ := limiter.Allow("user", "/Rex/ListProcessInfo"); allowed {
+		t.Errorf("Expected the method rule to apply")
+	}
+}
+
+func TestRateLimiter_Patterns(t *testing.T) {
+	rule, err := RateLimitRuleFromJSON([]byte(`
+	{"Principal": ["ci-*", "bot"], "Method": "/Rex/Get*", "Rate": 1}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating rate limit rule from JSON: %v", err)
+	}
+	limiter := NewRateLimiter(
+		rule,
+		&RateLimitRule{Principal: Patterns{"*"}, Method: Patterns{"*"}, Rate: 100},
+	)
+
+	limiter.Allow("ci-1", "/Rex/GetProcessInfo")
+	if allowed, _ := limiter.Allow("ci-1", "/Rex/GetQuota"); allowed {
+		t.Errorf("Expected the matching methods to share the bucket of the pattern rule")
+	}
+	for _, call := range [][2]string{{"ci-1", "/Rex/Exec"}, {"user", "/Rex/GetProcessInfo"}} {
+		if allowed, _ := limiter.Allow(call[0], call[1]); !allowed {
+			t.Errorf("Expected %s calling %s to fall back to the catch-all rule", call[0], call[1])
+		}
+	}
+
+	for _, rule := range []string{
+		`{"Principal": "*", "Method": "/Rex/Nope*", "Rate": 1}`,
+		`{"Principal": "*", "Method": "/Rex/[", "Rate": 1}`,
+		`{"Principal": [], "Method": "*", "Rate": 1}`,
+	} {
+		if _, err := RateLimitRuleFromJSON([]byte(rule)); err == nil {
+			t.Errorf("Expected %s to be rejected", rule)
+		}
+	}
+}
+
+func TestRateLimitInterceptor_ResourceExhausted(t *testing.T) {
+	interceptor := RateLimitInterceptor(NewRateLimiter(
+		&RateLimitRule{Principal: Patterns{"*"}, Method: Patterns{"*"}, Rate: 1},
+	))
+	ctx := rex.WithUserID(context.Background(), "user")
+	info := &grpc.UnaryServerInfo{FullMethod: "/Rex/Exec"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("Expected the first call to be allowed, got: %v", err)
+	}
+	_, err := interceptor(ctx, nil, info, handler)
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("Expected code %v, received: %v", codes.ResourceExhausted, code)
+	}
+}
@@ -3,14 +3,13 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"sort"
 	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"github.com/go-playground/validator/v10"
-
 	"github.com/farnasirim/rex"
 )
 
@@ -25,56 +24,89 @@ type Policy interface {
 // SimpleAccessRule defines access rules of the form
 // "User is/is not allowed to execute Action"
 type SimpleAccessRule struct {
-	Principal string `validate:"required"`
-	// TODO: extract method names from the grpc service. Currently I don't see
-	// a clean way to do this. We can register a dummy service which will
-	// introduce proto._Rex_serviceDesc to the grpc server upon registration,
-	// They GetServiceInfo will reveal the method names. However to avoid
-	// reconstructing the full method name ourselves, we need to extract those
-	// directly from interceptors. One idea would be to loop over the method
-	// names of the above dummy service and through server reflection send a
-	// dummy request to each of its endpoints, allowing for the interceptor
-	// to be invoked. There we steal the full name using UnaryServerInfo.
-	// All of this happens before server startup time.
-	Action string `validate:"oneof=* /Rex/Exec /Rex/Kill /Rex/GetProcessInfo /Rex/ListProcessInfo /Rex/Read"`
-	Effect string `validate:"oneof=allow deny"`
+	// Principal lists user IDs, group:NAME for the members of a group, or
+	// role:NAME for the principals having a role. Group and role names can
+	// be patterns too, e.g. group:team-a-*.
+	Principal Patterns `validate:"min=1,dive,required"`
+	// Action lists the full names of the methods, e.g. /Rex/Exec or
+	// /Rex/Get*. Each pattern has to match at least one of Actions().
+	Action Patterns `validate:"min=1,dive,action"`
+	Effect string   `validate:"oneof=allow deny"`
+	// Conditions, if not nil, restrict the rule to the requests whose
+	// content falls within them.
+	Conditions *RequestConditions
+	// Resource, if not nil, restricts the rule to the calls acting on the
+	// processes that match it.
+	Resource *ResourceConditions
+	// Priority puts the rule before the ones of lower priority in its
+	// policy set.
+	Priority int
 }
 
 // Enforce returns (lowercase(Effect) == "allow", true) if principal and action
-// match those in the context, (false, false) otherwise. Its Principal and
-// Action fields support "*" to always match.
+// match those in the context, and so do the request and the process that the
+// call acts on if the rule has conditions on them, (false, false) otherwise.
+// Its Principal and Action fields support glob patterns, and "*" to always
+// match, which is also the only Action that matches the calls made from
+// within the server.
+//
+// Rules with Resource conditions only apply once the process that a call
+// acts on is known, and the rest only before it, so that each call is first
+// authorized as a whole and then for the process.
 func (r *SimpleAccessRule) Enforce(ctx context.Context) (bool, bool) {
 	userID, ok := rex.UserIDFromContext(ctx)
 	if !ok {
 		return false, false
 	}
-	methodName, ok := methodNameFromContext(ctx)
-	if !ok {
-		return false, false
-	}
+	methodName, _ := methodNameFromContext(ctx)
 
-	return r.effect(), r.matchPrincipal(userID) && r.matchAction(methodName)
+	return r.effect(), r.matchPrincipal(ctx) && r.matchAction(methodName) &&
+		r.matchConditions(ctx) && r.matchResource(ctx, userID)
 }
 
-func (r *SimpleAccessRule) matchPrincipal(principal string) bool {
-	return wildcardMatch(r.Principal, principal)
+func (r *SimpleAccessRule) matchPrincipal(ctx context.Context) bool {
+	for _, principal := range r.Principal {
+		if matchPrincipal(ctx, principal) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *SimpleAccessRule) matchAction(action string) bool {
-	return wildcardMatch(r.Action, action)
+	return r.Action.match(action)
 }
 
-func (r *SimpleAccessRule) effect() bool {
-	return strings.ToLower(r.Effect) == "allow"
+// matchConditions checks the conditions against the decoded request. Calls
+// whose request is not available, e.g. streaming ones, have nothing for the
+// conditions to restrict.
+func (r *SimpleAccessRule) matchConditions(ctx context.Context) bool {
+	if r.Conditions == nil {
+		return true
+	}
+	req, _ := requestFromContext(ctx)
+	return r.Conditions.match(req, r.effect())
 }
 
-func wildcardMatch(maybeWildcard, str string) bool {
-	return maybeWildcard == "*" || maybeWildcard == str
+func (r *SimpleAccessRule) matchResource(ctx context.Context, userID string) bool {
+	resource, ok := rex.ResourceFromContext(ctx)
+	if r.Resource == nil || !ok {
+		return r.Resource == nil && !ok
+	}
+	return r.Resource.match(resource, userID)
+}
+
+func (r *SimpleAccessRule) priority() int {
+	return r.Priority
+}
+
+func (r *SimpleAccessRule) effect() bool {
+	return strings.ToLower(r.Effect) == "allow"
 }
 
 // SimpleAccessRuleFromJSON creates an access rule from its json representation
 func SimpleAccessRuleFromJSON(marshalledAccessRule []byte) (*SimpleAccessRule, error) {
-	validate := validator.New()
+	validate := newValidator()
 
 	var rule SimpleAccessRule
 	if err := json.Unmarshal(marshalledAccessRule, &rule); err != nil {
@@ -84,43 +116,87 @@ func SimpleAccessRuleFromJSON(marshalledAccessRule []byte) (*SimpleAccessRule, e
 	if err := validate.Struct(&rule); err != nil {
 		return nil, err
 	}
+	if rule.Conditions != nil {
+		if err := rule.Conditions.compile(); err != nil {
+			return nil, err
+		}
+	}
 
 	return &rule, nil
 }
 
+// OwnerAccessRule lets the owners of the processes make any call on them. It
+// is the default policy of the processes.
+func OwnerAccessRule() *SimpleAccessRule {
+	return &SimpleAccessRule{
+		Principal: Patterns{"*"},
+		Action:    Patterns{"*"},
+		Effect:    "allow",
+		Resource:  &ResourceConditions{Owner: ResourceOwnerSelf},
+	}
+}
+
 // PolicyEnforcer implements Policy by chaining together other policies and
-// executing them one by one, defaulting
+// executing them one by one, combining their verdicts with a
+// CombiningAlgorithm
 type PolicyEnforcer struct {
-	policies []Policy
+	algorithm CombiningAlgorithm
+	policies  []Policy
 }
 
 // NewPolicyEnforcer creates a PolicyEnforcer from a list (ordered chain)
-// of objects satisfying the Policy interface
+// of objects satisfying the Policy interface, combined with DenyOverrides
 func NewPolicyEnforcer(policies ...Policy) *PolicyEnforcer {
+	return NewCombiningPolicyEnforcer(DenyOverrides, policies...)
+}
+
+// NewCombiningPolicyEnforcer creates a PolicyEnforcer combining policies with
+// algorithm. The policies are ordered by their Priority, highest first, and
+// keep their order otherwise.
+func NewCombiningPolicyEnforcer(algorithm CombiningAlgorithm, policies ...Policy) *PolicyEnforcer {
+	sorted := append([]Policy(nil), policies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return policyPriority(sorted[i]) > policyPriority(sorted[j])
+	})
 	return &PolicyEnforcer{
-		policies: policies,
+		algorithm: algorithm,
+		policies:  sorted,
 	}
 }
 
 // Enforce applies []policies on the context. Returns (false, false) if
-// none of the policies apply, returns (false, true) if at least one of
-// the policies returns (false, true), and returns (true, true) otherwise.
+// none of the policies apply. Otherwise, with DenyOverrides it returns
+// (false, true) if at least one of the policies returns (false, true), and
+// (true, true) otherwise. PermitOverrides does the opposite, and
+// FirstApplicable returns the verdict of the first policy that applies.
 func (e *PolicyEnforcer) Enforce(ctx context.Context) (bool, bool) {
+	verdict, applies, _ := e.decide(ctx)
+	return verdict, applies
+}
+
+// decide enforces the policies, also returning the index of the one that
+// has decided, which is -1 if none of them apply
+func (e *PolicyEnforcer) decide(ctx context.Context) (bool, bool, int) {
 	verdict := false
-	applies := false
+	decider := -1
 
-	for _, policy := range e.policies {
+	for i, policy := range e.policies {
 		thisVerdict, thisApplies := policy.Enforce(ctx)
-		if thisApplies {
-			if !thisVerdict {
-				return false, true
-			}
-			applies = true
-			verdict = true
+		if !thisApplies {
+			continue
+		}
+		switch {
+		case e.algorithm == FirstApplicable,
+			e.algorithm == PermitOverrides && thisVerdict,
+			e.algorithm != PermitOverrides && !thisVerdict:
+			return thisVerdict, true, i
+		}
+		if decider < 0 {
+			verdict, decider = thisVerdict, i
 		}
 	}
 
-	return verdict, applies
+	return verdict, decider >= 0, decider
 }
 
 // PolicyEnforcementInterceptor authorizes the execution of handler
@@ -131,6 +207,7 @@ func PolicyEnforcementInterceptor(p Policy) grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp interface{}, err error) {
 		ctx = withMethodName(ctx, info.FullMethod)
+		ctx = withRequest(ctx, req)
 
 		if authorized, applies := p.Enforce(ctx); !applies || !authorized {
 			return nil, status.Errorf(codes.PermissionDenied,
@@ -140,3 +217,22 @@ func PolicyEnforcementInterceptor(p Policy) grpc.UnaryServerInterceptor {
 		return handler(ctx, req)
 	}
 }
+
+// PolicyEnforcementStreamInterceptor is the streaming counterpart of
+// PolicyEnforcementInterceptor.
+func PolicyEnforcementStreamInterceptor(p Policy) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := withMethodName(ss.Context(), info.FullMethod)
+
+		if authorized, applies := p.Enforce(ctx); !applies || !authorized {
+			return status.Errorf(codes.PermissionDenied,
+				rex.ErrAccessDenied.Error())
+		}
+
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
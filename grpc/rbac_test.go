@@ -2,9 +2,13 @@ package grpc
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/proto"
 )
 
 func TestSimpleAccessRule_MatchPrincipal_Wildcard_Reverse(t *testing.T) {
@@ -44,3 +48,231 @@ func TestPolicyEnforcer_Enforce_DefaultToDeny(t *testing.T) {
 		t.Errorf("Rule must not apply. Was expecting (false, false), got (%v, %v)", verdict, applies)
 	}
 }
+
+func TestSimpleAccessRule_Conditions(t *testing.T) {
+	rule, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "allow", "action": "*", "conditions": {
+		"executables": ["/bin/echo", "/usr/bin/*"], "args": ["-n", "[a-z]+"],
+		"envNames": ["LANG", "LC_*"], "signals": [2, 15]}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+
+	cases := []struct {
+		req     interface{}
+		applies bool
+	}{
+		{&proto.ExecRequest{Path: "/bin/echo", Args: []string{"-n", "hello"}}, true},
+		{&proto.ExecRequest{Path: "/bin/sh", Args: []string{"-c", "true"}}, false},
+		{&proto.ExecRequest{Path: "/bin/echo", Args: []string{"Hello"}}, false},
+		{&proto.ExecRequest{Path: "/usr/bin/env", Env: map[string]string{"LC_ALL": "C"}}, true},
+		{&proto.ExecRequest{Path: "/usr/bin/env", Env: map[string]string{"LD_PRELOAD": "x"}}, false},
+		{&proto.ExecRequest{Path: "/bin/echo", Pipeline: []*proto.PipelineStage{{Path: "/bin/rm"}}}, false},
+		{&proto.ExecRequest{Path: "/bin/echo", Probes: []*proto.Probe{
+			{Type: proto.Probe_EXEC, Command: []string{"/bin/rm", "x"}}}}, false},
+		{&proto.SubmitWorkflowRequest{Steps: []*proto.WorkflowStep{
+			{Command: &proto.ExecRequest{Path: "/usr/bin/true"}},
+			{Command: &proto.ExecRequest{Path: "/sbin/reboot"}}}}, false},
+		{&proto.KillRequest{Signal: 15}, true},
+		{&proto.KillRequest{Signal: 9}, false},
+		{&proto.GetProcessInfoRequest{}, true},
+	}
+	for _, c := range cases {
+		ctx := withRequest(withMethodName(rex.WithUserID(context.Background(), "user"), "/Rex/Exec"), c.req)
+		if verdict, applies := rule.Enforce(ctx); applies != c.applies || !verdict {
+			t.Errorf("Expected (true, %v) for %v, got (%v, %v)", c.applies, c.req, verdict, applies)
+		}
+	}
+
+	if _, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "allow", "action": "*", "conditions": {"args": ["("]}}
+	`)); err == nil {
+		t.Errorf("Expected a bad argument pattern to be rejected")
+	}
+}
+
+func TestSimpleAccessRule_DenyConditions(t *testing.T) {
+	rule, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "deny", "action": "*", "conditions": {
+		"executables": ["/sbin/reboot", "/bin/rm"], "args": ["-rf"], "signals": [9]}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+
+	cases := []struct {
+		req     interface{}
+		applies bool
+	}{
+		{&proto.ExecRequest{Path: "/sbin/reboot", Args: []string{"-rf"}}, true},
+		{&proto.ExecRequest{Path: "/bin/rm", Args: []string{"x", "-rf"}}, true},
+		{&proto.ExecRequest{Path: "/bin/rm", Args: []string{"x"}}, false},
+		{&proto.ExecRequest{Path: "/bin/true", Args: []string{"-rf"}}, false},
+		{&proto.ExecRequest{Path: "/bin/true", Pipeline: []*proto.PipelineStage{
+			{Path: "/bin/cat"}, {Path: "/bin/rm", Args: []string{"-rf", "/"}}}}, true},
+		{&proto.ExecRequest{Path: "/bin/sleep", Args: []string{"10"}, Probes: []*proto.Probe{
+			{Type: proto.Probe_EXEC, Command: []string{"/bin/rm", "-rf", "/"}}}}, true},
+		{&proto.CreateScheduleRequest{Command: &proto.ExecRequest{
+			Path: "/bin/rm", Args: []string{"-rf", "/tmp"}}}, true},
+		{&proto.SubmitWorkflowRequest{Steps: []*proto.WorkflowStep{
+			{Command: &proto.ExecRequest{Path: "/usr/bin/true"}},
+			{Command: &proto.ExecRequest{Path: "/bin/rm", Args: []string{"-rf", "/"}}}}}, true},
+		{&proto.SubmitWorkflowRequest{Steps: []*proto.WorkflowStep{
+			{Command: &proto.ExecRequest{Path: "/usr/bin/true"}}}}, false},
+		{&proto.KillRequest{Signal: 9}, true},
+		{&proto.KillGroupRequest{Signal: 9}, true},
+		{&proto.KillRequest{Signal: 15}, false},
+		{&proto.GetProcessInfoRequest{}, false},
+	}
+	for _, c := range cases {
+		ctx := withRequest(withMethodName(rex.WithUserID(context.Background(), "user"), "/Rex/Exec"), c.req)
+		if verdict, applies := rule.Enforce(ctx); applies != c.applies || verdict {
+			t.Errorf("Expected (false, %v) for %v, got (%v, %v)", c.applies, c.req, verdict, applies)
+		}
+	}
+
+	// Without conditions to match, deny rules apply to every request
+	rule, err = SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "deny", "action": "*", "conditions": {}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+	ctx := withRequest(withMethodName(rex.WithUserID(context.Background(), "user"), "/Rex/Exec"),
+		&proto.ExecRequest{Path: "/bin/true"})
+	if verdict, applies := rule.Enforce(ctx); !applies || verdict {
+		t.Errorf("Expected (false, true), got (%v, %v)", verdict, applies)
+	}
+}
+
+func TestSimpleAccessRule_RelativeExecutables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rex-conditions")
+	if err != nil {
+		t.Fatalf("While creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatalf("While resolving the temporary directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("While creating the executable: %v", err)
+	}
+	if err := os.Symlink("tool", filepath.Join(dir, "reboot")); err != nil {
+		t.Fatalf("While creating the symbolic link: %v", err)
+	}
+
+	deny, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "deny", "action": "*", "conditions": {"executables": ["/sbin/reboot"]}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+	allow, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "*", "effect": "allow", "action": "*", "conditions": {
+		"executables": ["` + dir + `/tool"], "resolveExecutables": true}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+
+	cases := []struct {
+		rule    *SimpleAccessRule
+		req     *proto.ExecRequest
+		applies bool
+	}{
+		{deny, &proto.ExecRequest{Path: "./reboot", Dir: "/sbin"}, true},
+		{deny, &proto.ExecRequest{Path: "../sbin/reboot", Dir: "/usr"}, true},
+		{deny, &proto.ExecRequest{Path: "/sbin/../sbin/reboot"}, true},
+		{deny, &proto.ExecRequest{Path: "./reboot", Dir: dir}, false},
+		// Private working directories do not exist yet
+		{deny, &proto.ExecRequest{Path: "./reboot", PrivateWorkDir: true}, true},
+		{allow, &proto.ExecRequest{Path: "./reboot", Dir: dir}, true},
+		{allow, &proto.ExecRequest{Path: "./tool"}, false},
+		{allow, &proto.ExecRequest{Path: "./tool", PrivateWorkDir: true}, false},
+	}
+	for _, c := range cases {
+		ctx := withRequest(withMethodName(rex.WithUserID(context.Background(), "user"), "/Rex/Exec"), c.req)
+		if verdict, applies := c.rule.Enforce(ctx); applies != c.applies || verdict != c.rule.effect() {
+			t.Errorf("Expected (%v, %v) for %v, got (%v, %v)", c.rule.effect(), c.applies, c.req, verdict, applies)
+		}
+	}
+}
+
+func TestSimpleAccessRule_Resource(t *testing.T) {
+	rule, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": "ops", "effect": "allow", "action": "/Rex/Kill", "resource": {"labels": {"env": "prod"}}}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+	enforcer := NewPolicyEnforcer(rule, OwnerAccessRule())
+
+	ctx := withMethodName(rex.WithUserID(context.Background(), "ops"), "/Rex/Kill")
+	if _, applies := rule.Enforce(ctx); applies {
+		t.Errorf("Expected rules with resource conditions not to apply before the process is known")
+	}
+
+	prod := rex.Resource{OwnerID: "dev", Labels: map[string]string{"env": "prod", "team": "a"}}
+	staging := rex.Resource{OwnerID: "dev", Labels: map[string]string{"env": "staging"}}
+	cases := []struct {
+		userID     string
+		method     string
+		resource   rex.Resource
+		authorized bool
+	}{
+		{"ops", "/Rex/Kill", prod, true},
+		{"ops", "/Rex/Read", prod, false},
+		{"ops", "/Rex/Kill", staging, false},
+		{"dev", "/Rex/Kill", staging, true},
+		{"dev", "", staging, true},
+		{"qa", "/Rex/Kill", prod, false},
+	}
+	for _, c := range cases {
+		ctx := rex.WithResource(rex.WithUserID(context.Background(), c.userID), c.resource)
+		if c.method != "" {
+			ctx = withMethodName(ctx, c.method)
+		}
+		if verdict, applies := enforcer.Enforce(ctx); (verdict && applies) != c.authorized {
+			t.Errorf("Expected %v for %s calling %q on %+v, got (%v, %v)",
+				c.authorized, c.userID, c.method, c.resource, verdict, applies)
+		}
+	}
+}
+
+func TestSimpleAccessRule_Patterns(t *testing.T) {
+	rule, err := SimpleAccessRuleFromJSON([]byte(`
+	{"principal": ["team-a-*", "bob"], "effect": "allow", "action": ["/Rex/Get*", "/Rex/WhoAmI"]}
+	`))
+	if err != nil {
+		t.Fatalf("Caught error while creating simple access rule from JSON: %v", err)
+	}
+
+	cases := []struct {
+		userID, method string
+		applies        bool
+	}{
+		{"team-a-alice", "/Rex/GetProcessInfo", true},
+		{"bob", "/Rex/WhoAmI", true},
+		{"team-b-carol", "/Rex/GetProcessInfo", false},
+		{"bob", "/Rex/Exec", false},
+		{"bob", "", false},
+	}
+	for _, c := range cases {
+		ctx := withMethodName(rex.WithUserID(context.Background(), c.userID), c.method)
+		if _, applies := rule.Enforce(ctx); applies != c.applies {
+			t.Errorf("%s calling %q: expected applies %v, got %v", c.userID, c.method, c.applies, applies)
+		}
+	}
+
+	for _, invalid := range []string{
+		`{"principal": "*", "effect": "allow", "action": "/Rex/Nope*"}`,
+		`{"principal": "*", "effect": "allow", "action": "/Rex/[Exec"}`,
+		`{"principal": [], "effect": "allow", "action": "*"}`,
+		`{"principal": "*", "effect": "allow", "action": []}`,
+	} {
+		if _, err := SimpleAccessRuleFromJSON([]byte(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+
+	"github.com/farnasirim/rex"
+)
+
+// replicaRetryAfter is how long a replica that could not be reached is
+// skipped for when picking the replica of a new process
+const replicaRetryAfter = 30 * time.Second
+
+// PickStrategy specifies how ReplicaClient picks the replica that a new
+// process is created on.
+type PickStrategy int
+
+const (
+	// PickRoundRobin cycles through the replicas.
+	PickRoundRobin PickStrategy = iota
+	// PickLeastLoaded picks the replica with the fewest running and queued
+	// processes.
+	PickLeastLoaded
+)
+
+var pickStrategyNames = map[PickStrategy]string{
+	PickRoundRobin:  "round-robin",
+	PickLeastLoaded: "least-loaded",
+}
+
+func (s PickStrategy) String() string {
+	if name, ok := pickStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("PickStrategy(%d)", int(s))
+}
+
+// ParsePickStrategy returns the strategy with the given name
+func ParsePickStrategy(name string) (PickStrategy, error) {
+	for strategy, strategyName := range pickStrategyNames {
+		if strategyName == name {
+			return strategy, nil
+		}
+	}
+	return PickRoundRobin, fmt.Errorf("unknown pick strategy %q", name)
+}
+
+// Replica is one of several equivalent rex servers
+type Replica struct {
+	// Address identifies the replica, e.g. in the errors and in the
+	// output of CheckReplicas.
+	Address string
+	Conn    grpc.ClientConnInterface
+}
+
+// ReplicaStatus is the outcome of checking a replica
+type ReplicaStatus struct {
+	Address string
+	// Load is the number of the processes of the replica that are either
+	// running or queued.
+	Load int
+	// Err is not nil if the replica could not be checked.
+	Err error
+}
+
+type replica struct {
+	address string
+	client  *Client
+	// downUntil is guarded by ReplicaClient.m
+	downUntil time.Time
+}
+
+// ReplicaClient implements rex.Service on top of several equivalent rex
+// servers. New processes are created on a replica picked as specified by
+// the strategy, skipping the replicas that could not be reached lately, and
+// moving on to the next replica if the picked one cannot be reached. The
+// calls concerning a process are sent to the replica that owns it, which is
+// remembered once known and otherwise looked up by asking the replicas.
+type ReplicaClient struct {
+	strategy PickStrategy
+	now      func() time.Time
+	replicas []*replica
+
+	m      sync.Mutex
+	next   int
+	owners map[uuid.UUID]*replica
+}
+
+// NewReplicaClient creates a ReplicaClient for the given replicas. Their
+// connections must be using ErrorUnmarshallerInterceptor, which allows the
+// replicas that cannot be reached to be told apart from the errors returned
+// by the replicas.
+func NewReplicaClient(strategy PickStrategy, replicas ...Replica) (*ReplicaClient, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("no replicas")
+	}
+	if _, ok := pickStrategyNames[strategy]; !ok {
+		return nil, fmt.Errorf("unknown pick strategy %d", strategy)
+	}
+	rc := &ReplicaClient{
+		strategy: strategy,
+		now:      time.Now,
+		owners:   make(map[uuid.UUID]*replica),
+	}
+	seen := make(map[string]bool)
+	for _, r := range replicas {
+		if seen[r.Address] {
+			return nil, fmt.Errorf("duplicate replica %q", r.Address)
+		}
+		seen[r.Address] = true
+		rc.replicas = append(rc.replicas, &replica{address: r.Address, client: NewClient(r.Conn)})
+	}
+	return rc, nil
+}
+
+// Exec creates a process on one of the replicas
+func (rc *ReplicaClient) Exec(ctx context.Context, path string, args ...string) (uuid.UUID, error) {
+	return rc.ExecCommand(ctx, rex.Command{Path: path, Args: args})
+}
+
+// ExecCommand creates a process on one of the replicas. Only the failures to
+// reach a replica are retried on the others, as a replica that has been
+// reached might have created the process despite failing the call.
+func (rc *ReplicaClient) ExecCommand(ctx context.Context, cmd rex.Command) (uuid.UUID, error) {
+	var lastErr error
+	for _, r := range rc.candidates(ctx) {
+		processID, err := r.client.ExecCommand(ctx, cmd)
+		if rc.record(r, err) {
+			lastErr = fmt.Errorf("%s: %w", r.address, err)
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+		rc.setOwner(processID, r)
+		return processID, nil
+	}
+	return uuid.Nil, lastErr
+}
+
+// ListProcessInfo merges the processes of the replicas, sorted by their
+// creation time (newest first). The replicas that cannot be reached are
+// skipped unless none of them can be.
+func (rc *ReplicaClient) ListProcessInfo(ctx context.Context) ([]rex.ProcessInfo, error) {
+	lists := make([][]rex.ProcessInfo, len(rc.replicas))
+	errs := make([]error, len(rc.replicas))
+	var wg sync.WaitGroup
+	for i, r := range rc.replicas {
+		wg.Add(1)
+		go func(i int, r *replica) {
+			defer wg.Done()
+			lists[i], errs[i] = r.client.ListProcessInfo(ctx)
+		}(i, r)
+	}
+	wg.Wait()
+
+	var infoList []rex.ProcessInfo
+	var firstErr error
+	reached := false
+	for i, r := range rc.replicas {
+		if rc.record(r, errs[i]) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", r.address, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		reached = true
+		for _, info := range lists[i] {
+			rc.setOwner(info.ID, r)
+			infoList = append(infoList, info)
+		}
+	}
+	if !reached {
+		return nil, firstErr
+	}
+	sort.Slice(infoList, func(i, j int) bool {
+		return infoList[i].Create.After(infoList[j].Create)
+	})
+	return infoList, nil
+}
+
+// GetProcessInfo returns the process info of a process from its replica
+func (rc *ReplicaClient) GetProcessInfo(ctx context.Context, processID uuid.UUID) (rex.ProcessInfo, error) {
+	r, err := rc.owner(ctx, processID)
+	if err != nil {
+		return rex.ProcessInfo{}, err
+	}
+	info, err := r.client.GetProcessInfo(ctx, processID)
+	rc.record(r, err)
+	return info, err
+}
+
+// Kill sends a signal to a process through its replica
+func (rc *ReplicaClient) Kill(ctx context.Context, processID uuid.UUID, signal int) error {
+	r, err := rc.owner(ctx, processID)
+	if err != nil {
+		return err
+	}
+	err = r.client.Kill(ctx, processID, signal)
+	rc.record(r, err)
+	return err
+}
+
+// Read reads the output of a process from its replica
+func (rc *ReplicaClient) Read(ctx context.Context, processID uuid.UUID, target rex.OutputStream) ([]byte, error) {
+	r, err := rc.owner(ctx, processID)
+	if err != nil {
+		return nil, err
+	}
+	output, err := r.client.Read(ctx, processID, target)
+	rc.record(r, err)
+	return output, err
+}
+
+// Delete removes a process from its replica
+func (rc *ReplicaClient) Delete(ctx context.Context, processID uuid.UUID) error {
+	r, err := rc.owner(ctx, processID)
+	if err != nil {
+		return err
+	}
+	err = r.client.Delete(ctx, processID)
+	rc.record(r, err)
+	if err == nil {
+		rc.m.Lock()
+		delete(rc.owners, processID)
+		rc.m.Unlock()
+	}
+	return err
+}
+
+// WaitFor waits for a process to print a matching line through its replica
+func (rc *ReplicaClient) WaitFor(ctx context.Context, processID uuid.UUID,
+	target rex.OutputStream, pattern string) (rex.OutputMatch, error) {
+	r, err := rc.owner(ctx, processID)
+	if err != nil {
+		return rex.OutputMatch{}, err
+	}
+	match, err := r.client.WaitFor(ctx, processID, target, pattern)
+	rc.record(r, err)
+	return match, err
+}
+
+// CheckReplicas asks all of the replicas for their load, which also tells
+// whether they can be reached. The statuses are in the order in which the
+// replicas were given to NewReplicaClient.
+func (rc *ReplicaClient) CheckReplicas(ctx context.Context) []ReplicaStatus {
+	return rc.check(ctx, rc.replicas)
+}
+
+// candidates returns the replicas in the order in which a new process should
+// be attempted on them. The replicas that could not be reached lately come
+// last.
+func (rc *ReplicaClient) candidates(ctx context.Context) []*replica {
+	rc.m.Lock()
+	start := rc.next
+	rc.next = (rc.next + 1) % len(rc.replicas)
+	now := rc.now()
+	var up, down []*replica
+	for i := range rc.replicas {
+		r := rc.replicas[(start+i)%len(rc.replicas)]
+		if now.Before(r.downUntil) {
+			down = append(down, r)
+		} else {
+			up = append(up, r)
+		}
+	}
+	rc.m.Unlock()
+
+	if rc.strategy == PickLeastLoaded && len(up) > 1 {
+		loads := make(map[*replica]int)
+		var reachable, failed []*replica
+		for i, status := range rc.check(ctx, up) {
+			if status.Err != nil {
+				failed = append(failed, up[i])
+				continue
+			}
+			loads[up[i]] = status.Load
+			reachable = append(reachable, up[i])
+		}
+		// Ties are broken in the round robin order
+		sort.SliceStable(reachable, func(i, j int) bool {
+			return loads[reachable[i]] < loads[reachable[j]]
+		})
+		up = append(reachable, failed...)
+	}
+	return append(up, down...)
+}
+
+// check is CheckReplicas for a subset of the replicas
+func (rc *ReplicaClient) check(ctx context.Context, replicas []*replica) []ReplicaStatus {
+	statuses := make([]ReplicaStatus, len(replicas))
+	var wg sync.WaitGroup
+	for i, r := range replicas {
+		wg.Add(1)
+		go func(i int, r *replica) {
+			defer wg.Done()
+			load, err := rex.LoadOf(ctx, r.client)
+			rc.record(r, err)
+			statuses[i] = ReplicaStatus{Address: r.address, Load: load, Err: err}
+		}(i, r)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// owner returns the replica that owns a process, asking the replicas in
+// turn if it is not known yet
+func (rc *ReplicaClient) owner(ctx context.Context, processID uuid.UUID) (*replica, error) {
+	rc.m.Lock()
+	r, ok := rc.owners[processID]
+	rc.m.Unlock()
+	if ok {
+		return r, nil
+	}
+
+	var unreachableErr error
+	for _, r := range rc.replicas {
+		_, err := r.client.GetProcessInfo(ctx, processID)
+		if rc.record(r, err) {
+			unreachableErr = fmt.Errorf("%s: %w", r.address, err)
+			continue
+		}
+		if errors.Is(err, rex.ErrNotFound) {
+			continue
+		}
+		// Any other outcome, e.g. access denied, means that the replica
+		// knows the process
+		rc.setOwner(processID, r)
+		return r, nil
+	}
+	if unreachableErr != nil {
+		// The process might be on the replica that cannot be reached
+		return nil, unreachableErr
+	}
+	return nil, rex.ErrNotFound
+}
+
+func (rc *ReplicaClient) setOwner(processID uuid.UUID, r *replica) {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	rc.owners[processID] = r
+}
+
+// record updates the reachability of a replica after a call that has
+// returned err, and reports whether the replica could not be reached.
+func (rc *ReplicaClient) record(r *replica, err error) bool {
+	unreachable := errors.Is(err, rex.ErrUnavailable)
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	if unreachable {
+		r.downUntil = rc.now().Add(replicaRetryAfter)
+	} else {
+		r.downUntil = time.Time{}
+	}
+	return unreachable
+}
@@ -0,0 +1,138 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/farnasirim/rex"
+	rex_grpc "github.com/farnasirim/rex/grpc"
+	"github.com/farnasirim/rex/localexec"
+	"github.com/farnasirim/rex/proto"
+)
+
+// startReplica serves a new local process server on an in-memory listener
+// and returns a connection to it. The caller is taken to be userID.
+func startReplica(t *testing.T, address, userID string) (rex_grpc.Replica, *grpc.Server) {
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(rex.WithUserID(ctx, userID), req)
+		},
+		rex_grpc.ErrorMarshallerInterceptor,
+	))
+	proto.RegisterRexServer(grpcServer, rex_grpc.NewServer(localexec.NewServer(os.TempDir())))
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), address,
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(rex_grpc.ErrorUnmarshallerInterceptor))
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+	})
+	return rex_grpc.Replica{Address: address, Conn: conn}, grpcServer
+}
+
+func TestReplicaClient_Failover(t *testing.T) {
+	userID := uuid.New().String()
+	replicaA, serverA := startReplica(t, "a", userID)
+	replicaB, _ := startReplica(t, "b", userID)
+	ctx := context.Background()
+
+	client, err := rex_grpc.NewReplicaClient(rex_grpc.PickRoundRobin, replicaA, replicaB)
+	if err != nil {
+		t.Fatalf("While creating the client: %v", err)
+	}
+	first, err := client.Exec(ctx, "echo", "first")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	second, err := client.Exec(ctx, "echo", "second")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	clientA := rex_grpc.NewClient(replicaA.Conn)
+	clientB := rex_grpc.NewClient(replicaB.Conn)
+	if _, err := clientA.GetProcessInfo(ctx, first); err != nil {
+		t.Errorf("Expected the first process on the first replica: %v", err)
+	}
+	if _, err := clientB.GetProcessInfo(ctx, second); err != nil {
+		t.Errorf("Expected the second process on the second replica: %v", err)
+	}
+
+	// A fresh client has to find the owner of the process
+	other, err := rex_grpc.NewReplicaClient(rex_grpc.PickRoundRobin, replicaA, replicaB)
+	if err != nil {
+		t.Fatalf("While creating the client: %v", err)
+	}
+	if info, err := other.GetProcessInfo(ctx, second); err != nil || info.ID != second {
+		t.Errorf("Expected to look up the second process, got %+v, %v", info, err)
+	}
+	if _, err := other.GetProcessInfo(ctx, uuid.New()); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v for an unknown process, got %v", rex.ErrNotFound, err)
+	}
+
+	serverA.Stop()
+	// Round robin points at the stopped replica
+	third, err := client.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("Expected Exec to fail over to the second replica: %v", err)
+	}
+	defer client.Kill(ctx, third, int(syscall.SIGKILL))
+	if _, err := clientB.GetProcessInfo(ctx, third); err != nil {
+		t.Errorf("Expected the third process on the second replica: %v", err)
+	}
+	infoList, err := client.ListProcessInfo(ctx)
+	if err != nil {
+		t.Fatalf("Expected the stopped replica to be skipped: %v", err)
+	}
+	if len(infoList) != 2 || infoList[0].ID != third || infoList[1].ID != second {
+		t.Errorf("Expected the processes of the second replica, got %+v", infoList)
+	}
+	statuses := client.CheckReplicas(ctx)
+	if statuses[0].Err == nil || statuses[1].Err != nil || statuses[1].Load != 1 {
+		t.Errorf("Expected only the second replica to be up with a single process, got %+v", statuses)
+	}
+	if _, err := client.GetProcessInfo(ctx, first); err == nil {
+		t.Errorf("Expected the process of the stopped replica to be unreachable")
+	}
+}
+
+func TestReplicaClient_LeastLoaded(t *testing.T) {
+	userID := uuid.New().String()
+	replicaA, _ := startReplica(t, "a", userID)
+	replicaB, _ := startReplica(t, "b", userID)
+	ctx := context.Background()
+
+	busy, err := rex_grpc.NewClient(replicaA.Conn).Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer rex_grpc.NewClient(replicaA.Conn).Kill(ctx, busy, int(syscall.SIGKILL))
+
+	client, err := rex_grpc.NewReplicaClient(rex_grpc.PickLeastLoaded, replicaA, replicaB)
+	if err != nil {
+		t.Fatalf("While creating the client: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		processID, err := client.Exec(ctx, "true")
+		if err != nil {
+			t.Fatalf("While calling Exec: %v", err)
+		}
+		if _, err := rex_grpc.NewClient(replicaB.Conn).GetProcessInfo(ctx, processID); err != nil {
+			t.Errorf("Expected the process on the idle replica: %v", err)
+		}
+	}
+}
@@ -2,23 +2,63 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	protobuf "google.golang.org/protobuf/proto"
 
 	"github.com/farnasirim/rex"
 	"github.com/farnasirim/rex/proto"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/uuid"
 )
 
+// fileChunkSize is the size of the chunks in which files are transferred
+const fileChunkSize = 64 << 10
+
+// streamAcceptedHeader is sent by the server streaming calls as soon as the
+// stream is accepted, before sending any messages.
+const streamAcceptedHeader = "rex-stream-accepted"
+
 // Server implements rex.Service by translating the GRPC api and passing
 // the request to a concrete implementation of rex.Service
 type Server struct {
 	proto.UnimplementedRexServer
 	ps rex.Service
+
+	// policy and identities answer CheckAccess, which is not implemented
+	// if policy is nil
+	policy     Policy
+	identities *IdentityMapper
+}
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithAccessPolicy lets the server explain the decisions of the policy that
+// its interceptors enforce in CheckAccess, finding the identities of the
+// principals that it is asked about with m, which may be nil.
+func WithAccessPolicy(p Policy, m *IdentityMapper) ServerOption {
+	return func(s *Server) {
+		s.policy = p
+		s.identities = m
+	}
 }
 
 // Exec implements the Exec function from the Rex GRPC api.
 func (s *Server) Exec(ctx context.Context, req *proto.ExecRequest) (*proto.ExecResponse, error) {
-	processUUID, err := s.ps.Exec(ctx, req.Path, req.Args...)
+	command := commandNativeFromProto(req)
+	if req.GetGroupUUID() != "" {
+		groupUUID, err := uuid.Parse(req.GetGroupUUID())
+		if err != nil {
+			return nil, err
+		}
+		command.GroupID = groupUUID
+	}
+	processUUID, err := s.ps.ExecCommand(ctx, command)
 	if err != nil {
 		return nil, err
 	}
@@ -91,28 +131,816 @@ func (s *Server) Read(ctx context.Context, req *proto.ReadRequest) (*proto.ReadR
 	return &proto.ReadResponse{Content: output}, nil
 }
 
+// Delete translates a request to delete a process from the gRPC API to the
+// native API.
+func (s *Server) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.DeleteResponse, error) {
+	processUUID, err := uuid.Parse(req.GetProcessUUID())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.DeleteResponse{}, s.ps.Delete(ctx, processUUID)
+}
+
+// Watch streams the lifecycle events of the processes if the underlying
+// rex.Service implements rex.Watcher.
+func (s *Server) Watch(req *proto.WatchRequest, stream proto.Rex_WatchServer) error {
+	watcher, ok := s.ps.(rex.Watcher)
+	if !ok {
+		return rex.ErrNotImplemented
+	}
+
+	filter := rex.EventFilter{
+		Labels:   req.GetLabels(),
+		AfterSeq: req.GetAfterSequence(),
+	}
+	if req.GetOwnerUUID() != "" {
+		ownerUUID, err := uuid.Parse(req.GetOwnerUUID())
+		if err != nil {
+			return err
+		}
+		filter.OwnerID = ownerUUID
+	}
+
+	events, err := watcher.Watch(stream.Context(), filter)
+	if err != nil {
+		return err
+	}
+	// Let the client know that the stream is accepted without having to wait
+	// for the first event.
+	if err := stream.SendHeader(metadata.Pairs(streamAcceptedHeader, "true")); err != nil {
+		return err
+	}
+	for event := range events {
+		if err := stream.Send(eventProtoFromNative(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetQuota returns the quota of the caller if the underlying rex.Service
+// implements rex.QuotaReporter.
+func (s *Server) GetQuota(ctx context.Context, req *proto.GetQuotaRequest) (*proto.GetQuotaResponse, error) {
+	reporter, ok := s.ps.(rex.QuotaReporter)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	quota, usage, err := reporter.GetQuota(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.GetQuotaResponse{
+		Quota: &proto.Quota{
+			MaxRunning:          int64(quota.MaxRunning),
+			MaxPerHour:          int64(quota.MaxPerHour),
+			MaxOutputBytes:      quota.MaxOutputBytes,
+			MaxCPUSecondsPerDay: quota.MaxCPUSecondsPerDay,
+		},
+		Usage: &proto.ResourceUsage{
+			Running:           int64(usage.Running),
+			CreatedLastHour:   int64(usage.CreatedLastHour),
+			OutputBytes:       usage.OutputBytes,
+			CpuSecondsLastDay: usage.CPUSecondsLastDay,
+			Queued:            int64(usage.Queued),
+		},
+	}, nil
+}
+
+// CreateSchedule creates a schedule if the underlying rex.Service implements
+// rex.Scheduler.
+func (s *Server) CreateSchedule(ctx context.Context, req *proto.CreateScheduleRequest) (*proto.Schedule, error) {
+	scheduler, ok := s.ps.(rex.Scheduler)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	schedule, err := scheduler.CreateSchedule(ctx, rex.Schedule{
+		Cron:     req.GetCron(),
+		TimeZone: req.GetTimeZone(),
+		Command:  commandNativeFromProto(req.GetCommand()),
+		Overlap:  rex.OverlapPolicy(req.GetOverlap()),
+		Paused:   req.GetPaused(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scheduleProtoFromNative(schedule), nil
+}
+
+// ListSchedules lists the schedules of the caller if the underlying
+// rex.Service implements rex.Scheduler.
+func (s *Server) ListSchedules(ctx context.Context, req *proto.ListSchedulesRequest) (*proto.ScheduleList, error) {
+	scheduler, ok := s.ps.(rex.Scheduler)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	schedules, err := scheduler.ListSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := &proto.ScheduleList{}
+	for _, schedule := range schedules {
+		ret.Schedules = append(ret.Schedules, scheduleProtoFromNative(schedule))
+	}
+	return ret, nil
+}
+
+// DeleteSchedule removes a schedule if the underlying rex.Service implements
+// rex.Scheduler.
+func (s *Server) DeleteSchedule(ctx context.Context, req *proto.DeleteScheduleRequest) (*proto.DeleteScheduleResponse, error) {
+	scheduler, ok := s.ps.(rex.Scheduler)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	scheduleUUID, err := uuid.Parse(req.GetScheduleUUID())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.DeleteScheduleResponse{}, scheduler.DeleteSchedule(ctx, scheduleUUID)
+}
+
+// PauseSchedule pauses or resumes a schedule if the underlying rex.Service
+// implements rex.Scheduler.
+func (s *Server) PauseSchedule(ctx context.Context, req *proto.PauseScheduleRequest) (*proto.Schedule, error) {
+	scheduler, ok := s.ps.(rex.Scheduler)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	scheduleUUID, err := uuid.Parse(req.GetScheduleUUID())
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := scheduler.PauseSchedule(ctx, scheduleUUID, req.GetPaused())
+	if err != nil {
+		return nil, err
+	}
+	return scheduleProtoFromNative(schedule), nil
+}
+
+func commandNativeFromProto(req *proto.ExecRequest) rex.Command {
+	command := rex.Command{
+		Path:           req.GetPath(),
+		Args:           req.GetArgs(),
+		Labels:         req.GetLabels(),
+		Callbacks:      req.GetCallbacks(),
+		Priority:       int(req.GetPriority()),
+		Node:           req.GetNode(),
+		PrivateWorkDir: req.GetPrivateWorkDir(),
+		Artifacts:      req.GetArtifacts(),
+		Dir:            req.GetDir(),
+	}
+	if len(req.GetNodeSelector()) > 0 {
+		command.NodeSelector = req.GetNodeSelector()
+	}
+	if len(req.GetEnv()) > 0 {
+		command.Env = req.GetEnv()
+	}
+	if restart := req.GetRestart(); restart != nil {
+		command.Restart = &rex.RestartPolicy{
+			Mode:        rex.RestartMode(restart.GetMode()),
+			MaxRestarts: int(restart.GetMaxRestarts()),
+			Backoff:     durationNativeFromProto(restart.GetBackoff()),
+			MaxBackoff:  durationNativeFromProto(restart.GetMaxBackoff()),
+		}
+	}
+	for _, stage := range req.GetPipeline() {
+		command.Pipeline = append(command.Pipeline, rex.PipelineStage{
+			Path: stage.GetPath(),
+			Args: stage.GetArgs(),
+		})
+	}
+	for _, probe := range req.GetProbes() {
+		command.Probes = append(command.Probes, rex.Probe{
+			Name:             probe.GetName(),
+			Type:             rex.ProbeType(probe.GetType()),
+			Command:          probe.GetCommand(),
+			Port:             int(probe.GetPort()),
+			Path:             probe.GetPath(),
+			Interval:         durationNativeFromProto(probe.GetInterval()),
+			Timeout:          durationNativeFromProto(probe.GetTimeout()),
+			FailureThreshold: int(probe.GetFailureThreshold()),
+			RestartAfter:     int(probe.GetRestartAfter()),
+		})
+	}
+	return command
+}
+
+func durationNativeFromProto(d *duration.Duration) time.Duration {
+	return time.Duration(d.GetSeconds())*time.Second + time.Duration(d.GetNanos())
+}
+
+func scheduleProtoFromNative(schedule rex.Schedule) *proto.Schedule {
+	return &proto.Schedule{
+		ScheduleUUID:    schedule.ID.String(),
+		OwnerUUID:       schedule.OwnerID.String(),
+		Cron:            schedule.Cron,
+		TimeZone:        schedule.TimeZone,
+		Command:         execRequestProtoFromNative(schedule.Command),
+		Overlap:         proto.Schedule_Overlap(schedule.Overlap),
+		Paused:          schedule.Paused,
+		Create:          timestampProtoFromNative(schedule.Create),
+		LastRun:         timestampProtoFromNative(schedule.LastRun),
+		NextRun:         timestampProtoFromNative(schedule.NextRun),
+		LastProcessUUID: uuidProtoFromNative(schedule.LastProcessID),
+		LastError:       schedule.LastError,
+	}
+}
+
+// uuidProtoFromNative represents uuid.Nil as an empty string
+func uuidProtoFromNative(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+// GetServiceInfo returns the state of a service if the underlying
+// rex.Service implements rex.Supervisor.
+func (s *Server) GetServiceInfo(ctx context.Context, req *proto.GetServiceInfoRequest) (*proto.ServiceInfo, error) {
+	supervisor, ok := s.ps.(rex.Supervisor)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	serviceUUID, err := uuid.Parse(req.GetServiceUUID())
+	if err != nil {
+		return nil, err
+	}
+	info, err := supervisor.GetServiceInfo(ctx, serviceUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &proto.ServiceInfo{
+		ServiceUUID: info.ID.String(),
+		OwnerUUID:   info.OwnerID.String(),
+		Command:     execRequestProtoFromNative(info.Command),
+		State:       proto.ServiceInfo_State(info.State),
+		Restarts:    int32(info.Restarts),
+		NextRestart: timestampProtoFromNative(info.NextRestart),
+	}
+	for _, incarnation := range info.Incarnations {
+		ret.Incarnations = append(ret.Incarnations, processInfoProtoFromNative(incarnation))
+	}
+	return ret, nil
+}
+
+// WaitFor waits for the output of a process if the underlying rex.Service
+// implements rex.OutputWaiter.
+func (s *Server) WaitFor(ctx context.Context, req *proto.WaitForRequest) (*proto.WaitForResponse, error) {
+	waiter, ok := s.ps.(rex.OutputWaiter)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	processUUID, err := uuid.Parse(req.GetProcessUUID())
+	if err != nil {
+		return nil, err
+	}
+	var outputStream rex.OutputStream
+	if req.GetTarget() == proto.ReadRequest_STDOUT {
+		outputStream = rex.StdoutStream
+	} else if req.GetTarget() == proto.ReadRequest_STDERR {
+		outputStream = rex.StderrStream
+	} else {
+		return nil, rex.ErrInvalidArgument
+	}
+
+	match, err := waiter.WaitFor(ctx, processUUID, outputStream, req.GetPattern())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.WaitForResponse{
+		Matched: match.Matched,
+		Line:    match.Line,
+		Offset:  match.Offset,
+		Process: processInfoProtoFromNative(match.Process),
+	}, nil
+}
+
+// SubmitWorkflow starts a workflow if the underlying rex.Service implements
+// rex.WorkflowRunner.
+func (s *Server) SubmitWorkflow(ctx context.Context, req *proto.SubmitWorkflowRequest) (*proto.Workflow, error) {
+	runner, ok := s.ps.(rex.WorkflowRunner)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	var spec rex.Workflow
+	for _, step := range req.GetSteps() {
+		spec.Steps = append(spec.Steps, workflowStepNativeFromProto(step))
+	}
+	workflow, err := runner.SubmitWorkflow(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	return workflowProtoFromNative(workflow), nil
+}
+
+// GetWorkflow returns the state of a workflow if the underlying rex.Service
+// implements rex.WorkflowRunner.
+func (s *Server) GetWorkflow(ctx context.Context, req *proto.GetWorkflowRequest) (*proto.Workflow, error) {
+	runner, ok := s.ps.(rex.WorkflowRunner)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	workflowUUID, err := uuid.Parse(req.GetWorkflowUUID())
+	if err != nil {
+		return nil, err
+	}
+	workflow, err := runner.GetWorkflow(ctx, workflowUUID)
+	if err != nil {
+		return nil, err
+	}
+	return workflowProtoFromNative(workflow), nil
+}
+
+// ListWorkflows returns the workflows of the caller if the underlying
+// rex.Service implements rex.WorkflowRunner.
+func (s *Server) ListWorkflows(ctx context.Context, req *proto.ListWorkflowsRequest) (*proto.WorkflowList, error) {
+	runner, ok := s.ps.(rex.WorkflowRunner)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	workflows, err := runner.ListWorkflows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := &proto.WorkflowList{}
+	for _, workflow := range workflows {
+		ret.Workflows = append(ret.Workflows, workflowProtoFromNative(workflow))
+	}
+	return ret, nil
+}
+
+// CancelWorkflow cancels a workflow if the underlying rex.Service
+// implements rex.WorkflowRunner.
+func (s *Server) CancelWorkflow(ctx context.Context, req *proto.CancelWorkflowRequest) (*proto.Workflow, error) {
+	runner, ok := s.ps.(rex.WorkflowRunner)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	workflowUUID, err := uuid.Parse(req.GetWorkflowUUID())
+	if err != nil {
+		return nil, err
+	}
+	workflow, err := runner.CancelWorkflow(ctx, workflowUUID)
+	if err != nil {
+		return nil, err
+	}
+	return workflowProtoFromNative(workflow), nil
+}
+
+// GetGroupInfo returns the status of a group if the underlying rex.Service
+// implements rex.GroupManager.
+func (s *Server) GetGroupInfo(ctx context.Context, req *proto.GetGroupInfoRequest) (*proto.GroupInfo, error) {
+	manager, ok := s.ps.(rex.GroupManager)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	groupUUID, err := uuid.Parse(req.GetGroupUUID())
+	if err != nil {
+		return nil, err
+	}
+	info, err := manager.GetGroupInfo(ctx, groupUUID)
+	if err != nil {
+		return nil, err
+	}
+	return groupInfoProtoFromNative(info), nil
+}
+
+// ListGroups returns the groups of the caller if the underlying rex.Service
+// implements rex.GroupManager.
+func (s *Server) ListGroups(ctx context.Context, req *proto.ListGroupsRequest) (*proto.GroupList, error) {
+	manager, ok := s.ps.(rex.GroupManager)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	groups, err := manager.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := &proto.GroupList{}
+	for _, info := range groups {
+		ret.Groups = append(ret.Groups, groupInfoProtoFromNative(info))
+	}
+	return ret, nil
+}
+
+// KillGroup sends a signal to the processes of a group if the underlying
+// rex.Service implements rex.GroupManager.
+func (s *Server) KillGroup(ctx context.Context, req *proto.KillGroupRequest) (*proto.KillGroupResponse, error) {
+	manager, ok := s.ps.(rex.GroupManager)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	groupUUID, err := uuid.Parse(req.GetGroupUUID())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.KillGroupResponse{}, manager.KillGroup(ctx, groupUUID, int(req.GetSignal()))
+}
+
+// WaitGroup waits for the processes of a group if the underlying rex.Service
+// implements rex.GroupManager.
+func (s *Server) WaitGroup(ctx context.Context, req *proto.WaitGroupRequest) (*proto.GroupInfo, error) {
+	manager, ok := s.ps.(rex.GroupManager)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	groupUUID, err := uuid.Parse(req.GetGroupUUID())
+	if err != nil {
+		return nil, err
+	}
+	info, err := manager.WaitGroup(ctx, groupUUID, rex.GroupWaitMode(req.GetMode()))
+	if err != nil {
+		return nil, err
+	}
+	return groupInfoProtoFromNative(info), nil
+}
+
+// DeleteGroup removes the processes of a group if the underlying
+// rex.Service implements rex.GroupManager.
+func (s *Server) DeleteGroup(ctx context.Context, req *proto.DeleteGroupRequest) (*proto.DeleteGroupResponse, error) {
+	manager, ok := s.ps.(rex.GroupManager)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	groupUUID, err := uuid.Parse(req.GetGroupUUID())
+	if err != nil {
+		return nil, err
+	}
+	return &proto.DeleteGroupResponse{}, manager.DeleteGroup(ctx, groupUUID)
+}
+
+// GetCapacity returns the load of the server if the underlying rex.Service
+// implements rex.CapacityReporter.
+func (s *Server) GetCapacity(ctx context.Context, req *proto.GetCapacityRequest) (*proto.Capacity, error) {
+	reporter, ok := s.ps.(rex.CapacityReporter)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	capacity, err := reporter.GetCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Capacity{
+		Running:       int32(capacity.Running),
+		Queued:        int32(capacity.Queued),
+		MaxConcurrent: int32(capacity.MaxConcurrent),
+	}, nil
+}
+
+// Upload writes a file to a workspace if the underlying rex.Service
+// implements rex.FileStore.
+func (s *Server) Upload(stream proto.Rex_UploadServer) error {
+	store, ok := s.ps.(rex.FileStore)
+	if !ok {
+		return rex.ErrNotImplemented
+	}
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := req.GetHeader()
+	if header == nil {
+		return errors.New("upload without a header")
+	}
+	file, err := fileRefNativeFromProto(header.GetFile())
+	if err != nil {
+		return err
+	}
+	info, err := store.Upload(stream.Context(), file, header.GetOffset(), header.GetSha256(),
+		&uploadReader{stream: stream})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(fileInfoProtoFromNative(info))
+}
+
+// uploadReader reads the chunks of an upload
+type uploadReader struct {
+	stream  proto.Rex_UploadServer
+	pending []byte
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.pending = req.GetChunk()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Download streams a file from a workspace if the underlying rex.Service
+// implements rex.FileStore.
+func (s *Server) Download(req *proto.DownloadRequest, stream proto.Rex_DownloadServer) error {
+	store, ok := s.ps.(rex.FileStore)
+	if !ok {
+		return rex.ErrNotImplemented
+	}
+
+	file, err := fileRefNativeFromProto(req.GetFile())
+	if err != nil {
+		return err
+	}
+	info, content, err := store.Download(stream.Context(), file, req.GetOffset())
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	err = stream.Send(&proto.DownloadResponse{
+		Content: &proto.DownloadResponse_Info{Info: fileInfoProtoFromNative(info)},
+	})
+	buf := make([]byte, fileChunkSize)
+	for err == nil {
+		var n int
+		n, err = content.Read(buf)
+		if n > 0 {
+			// Send marshals the message before returning, hence buf can be
+			// reused
+			if err := stream.Send(&proto.DownloadResponse{
+				Content: &proto.DownloadResponse_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// StatFile returns the info of a file in a workspace if the underlying
+// rex.Service implements rex.FileStore.
+func (s *Server) StatFile(ctx context.Context, req *proto.StatFileRequest) (*proto.FileInfo, error) {
+	store, ok := s.ps.(rex.FileStore)
+	if !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	file, err := fileRefNativeFromProto(req.GetFile())
+	if err != nil {
+		return nil, err
+	}
+	info, err := store.StatFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfoProtoFromNative(info), nil
+}
+
+// WhoAmI returns the identity of the caller as found by the interceptors of
+// the server.
+func (s *Server) WhoAmI(ctx context.Context, req *proto.WhoAmIRequest) (*proto.Identity, error) {
+	identity, ok := identityFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+	return &proto.Identity{
+		UserID: identity.UserID,
+		Groups: identity.Groups,
+		Roles:  identity.Roles,
+	}, nil
+}
+
+// CheckAccess explains the decision of the policy of the server on a
+// hypothetical call of the caller, or of the principal in the request, as
+// the call would be authorized before it is made. Checking on behalf of
+// another principal, or with extra groups or roles, requires the policy to
+// allow CheckAccessOnBehalfAction too.
+func (s *Server) CheckAccess(ctx context.Context, req *proto.CheckAccessRequest) (*proto.AccessDecision, error) {
+	if s.policy == nil {
+		return nil, rex.ErrNotImplemented
+	}
+	caller, ok := identityFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+	method := findMethod(req.GetMethod())
+	if method == nil {
+		return nil, rex.ErrInvalidArgument
+	}
+
+	principal := req.GetPrincipal()
+	onBehalf := principal != "" && principal != caller.UserID
+	if onBehalf || len(req.GetGroups()) > 0 || len(req.GetRoles()) > 0 {
+		onBehalfCtx := withRequest(withMethodName(ctx, CheckAccessOnBehalfAction), req)
+		if verdict, applies := s.policy.Enforce(onBehalfCtx); !verdict || !applies {
+			return nil, rex.ErrAccessDenied
+		}
+	}
+
+	cert, _ := peerCertificateFromContext(ctx)
+	userID := caller.UserID
+	groups := append(append([]string(nil), caller.Groups...), req.GetGroups()...)
+	roles := append(append([]string(nil), caller.Roles...), req.GetRoles()...)
+	if onBehalf {
+		userID, cert, groups, roles = principal, nil, req.GetGroups(), req.GetRoles()
+	}
+	identity := s.identities.identity(userID, cert, groups, roles)
+
+	ctx = rex.WithRoles(rex.WithGroups(rex.WithUserID(ctx, userID), identity.Groups), identity.Roles)
+	ctx = withMethodName(ctx, req.GetMethod())
+	// Only the requests of unary calls are available to the policies
+	ctx = withRequest(ctx, nil)
+	if !method.IsStreamingClient() && !method.IsStreamingServer() {
+		request, err := newRequest(method)
+		if err != nil {
+			return nil, err
+		}
+		if err := protobuf.Unmarshal(req.GetRequest(), request.Interface()); err != nil {
+			return nil, rex.ErrInvalidArgument
+		}
+		ctx = withRequest(ctx, request.Interface())
+	}
+
+	decision := Explain(ctx, s.policy)
+	return &proto.AccessDecision{
+		Allowed: decision.Allowed,
+		Rules:   decision.Rules,
+		Reason:  decision.Reason,
+		Identity: &proto.Identity{
+			UserID: identity.UserID,
+			Groups: identity.Groups,
+			Roles:  identity.Roles,
+		},
+	}, nil
+}
+
+func fileRefNativeFromProto(file *proto.FileRef) (rex.FileRef, error) {
+	ref := rex.FileRef{Path: file.GetPath()}
+	if file.GetProcessUUID() != "" {
+		processUUID, err := uuid.Parse(file.GetProcessUUID())
+		if err != nil {
+			return rex.FileRef{}, err
+		}
+		ref.ProcessID = processUUID
+	}
+	return ref, nil
+}
+
+func fileInfoProtoFromNative(info rex.FileInfo) *proto.FileInfo {
+	return &proto.FileInfo{
+		Path:     info.Path,
+		Size:     info.Size,
+		Sha256:   info.SHA256,
+		Modified: timestampProtoFromNative(info.Modified),
+	}
+}
+
+func groupInfoProtoFromNative(info rex.GroupInfo) *proto.GroupInfo {
+	ret := &proto.GroupInfo{
+		GroupUUID: info.ID.String(),
+		OwnerUUID: info.OwnerID.String(),
+		State:     proto.GroupInfo_State(info.State),
+		Queued:    int32(info.Queued),
+		Running:   int32(info.Running),
+		Succeeded: int32(info.Succeeded),
+		Failed:    int32(info.Failed),
+	}
+	for _, process := range info.Processes {
+		ret.Processes = append(ret.Processes, processInfoProtoFromNative(process))
+	}
+	return ret
+}
+
+func workflowStepNativeFromProto(step *proto.WorkflowStep) rex.WorkflowStep {
+	return rex.WorkflowStep{
+		Name:      step.GetName(),
+		Command:   commandNativeFromProto(step.GetCommand()),
+		DependsOn: step.GetDependsOn(),
+		Condition: rex.StepCondition(step.GetCondition()),
+		State:     rex.StepState(step.GetState()),
+		ProcessID: uuidNativeFromProto(step.GetProcessUUID()),
+		ExitCode:  int(step.GetExitCode()),
+		Error:     step.GetError(),
+	}
+}
+
+func workflowStepProtoFromNative(step rex.WorkflowStep) *proto.WorkflowStep {
+	return &proto.WorkflowStep{
+		Name:        step.Name,
+		Command:     execRequestProtoFromNative(step.Command),
+		DependsOn:   step.DependsOn,
+		Condition:   proto.WorkflowStep_Condition(step.Condition),
+		State:       proto.WorkflowStep_State(step.State),
+		ProcessUUID: uuidProtoFromNative(step.ProcessID),
+		ExitCode:    int32(step.ExitCode),
+		Error:       step.Error,
+	}
+}
+
+func workflowProtoFromNative(workflow rex.Workflow) *proto.Workflow {
+	ret := &proto.Workflow{
+		WorkflowUUID: workflow.ID.String(),
+		OwnerUUID:    workflow.OwnerID.String(),
+		State:        proto.Workflow_State(workflow.State),
+		Create:       timestampProtoFromNative(workflow.Create),
+		Finish:       timestampProtoFromNative(workflow.Finish),
+	}
+	for _, step := range workflow.Steps {
+		ret.Steps = append(ret.Steps, workflowStepProtoFromNative(step))
+	}
+	return ret
+}
+
 func processInfoProtoFromNative(proc rex.ProcessInfo) *proto.ProcessInfo {
-	return &proto.ProcessInfo{
-		ProcessUUID: proc.ID.String(),
-		Pid:         int32(proc.PID),
-		ExitCode:    int32(proc.ExitCode),
-		Path:        proc.Path,
-		Args:        proc.Args,
-		Running:     proc.Running,
-		OwnerUUID:   proc.OwnerID.String(),
-		Create: &timestamp.Timestamp{
-			Seconds: proc.Create.Unix(),
-			Nanos:   int32(proc.Create.Nanosecond())},
-		Exit: &timestamp.Timestamp{
-			Seconds: proc.Exit.Unix(),
-			Nanos:   int32(proc.Exit.Nanosecond())},
+	ret := &proto.ProcessInfo{
+		ProcessUUID:   proc.ID.String(),
+		Pid:           int32(proc.PID),
+		ExitCode:      int32(proc.ExitCode),
+		Path:          proc.Path,
+		Args:          proc.Args,
+		Running:       proc.Running,
+		OwnerUUID:     proc.OwnerID.String(),
+		Create:        timestampProtoFromNative(proc.Create),
+		Exit:          timestampProtoFromNative(proc.Exit),
+		Labels:        proc.Labels,
+		Callbacks:     proc.Callbacks,
+		State:         proto.ProcessInfo_State(proc.State),
+		QueuePosition: int32(proc.QueuePosition),
+		Priority:      int32(proc.Priority),
+		Start:         timestampProtoFromNative(proc.Start),
+		ScheduleUUID:  uuidProtoFromNative(proc.ScheduleID),
+		ServiceUUID:   uuidProtoFromNative(proc.ServiceID),
+		Incarnation:   int32(proc.Incarnation),
+		Health:        proto.Health(proc.Health),
+		WorkflowUUID:  uuidProtoFromNative(proc.WorkflowID),
+		GroupUUID:     uuidProtoFromNative(proc.GroupID),
+		Node:          proc.Node,
+		Artifacts:     proc.Artifacts,
+	}
+	for _, stage := range proc.Stages {
+		ret.Stages = append(ret.Stages, &proto.StageInfo{
+			Path:     stage.Path,
+			Args:     stage.Args,
+			Pid:      int32(stage.PID),
+			Exited:   stage.Exited,
+			ExitCode: int32(stage.ExitCode),
+		})
+	}
+	for _, status := range proc.Probes {
+		ret.Probes = append(ret.Probes, &proto.ProbeStatus{
+			Name:                status.Name,
+			Health:              proto.Health(status.Health),
+			ConsecutiveFailures: int32(status.ConsecutiveFailures),
+			LastCheck:           timestampProtoFromNative(status.LastCheck),
+			LastMessage:         status.LastMessage,
+		})
+	}
+	return ret
+}
+
+func eventProtoFromNative(event rex.Event) *proto.Event {
+	return &proto.Event{
+		Sequence: event.Seq,
+		Type:     proto.Event_Type(event.Type),
+		Time:     timestampProtoFromNative(event.Time),
+		Process:  processInfoProtoFromNative(event.Process),
+		Signal:   int32(event.Signal),
+	}
+}
+
+func timestampProtoFromNative(t time.Time) *timestamp.Timestamp {
+	return &timestamp.Timestamp{
+		Seconds: t.Unix(),
+		Nanos:   int32(t.Nanosecond()),
 	}
 }
 
 // NewServer creates a new Server capable of serving its API
 // over GRPC.
-func NewServer(ps rex.Service) *Server {
-	return &Server{
+func NewServer(ps rex.Service, opts ...ServerOption) *Server {
+	s := &Server{
 		ps: ps,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.identities == nil {
+		s.identities = NewIdentityMapper(nil)
+	}
+	return s
 }
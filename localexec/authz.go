@@ -0,0 +1,65 @@
+package localexec
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+)
+
+// WithResourcePolicy makes the ProcessServer authorize the calls that act on
+// a process with enforce, which finds the attributes of the process through
+// rex.ResourceFromContext. A call is allowed only if enforce returns
+// (true, true). Without a resource policy only the owner of a process may act
+// on it.
+func WithResourcePolicy(enforce func(context.Context) (effect bool, applies bool)) Option {
+	return func(ps *ProcessServer) {
+		ps.enforce = enforce
+	}
+}
+
+// authorize checks that the caller may act on the given process
+func (ps *ProcessServer) authorize(ctx context.Context, resource rex.Resource) error {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return rex.ErrUnauthenticated
+	}
+	if ps.enforce == nil {
+		if resource.OwnerID != userID {
+			return rex.ErrAccessDenied
+		}
+		return nil
+	}
+	if authorized, applies := ps.enforce(rex.WithResource(ctx, resource)); !applies || !authorized {
+		return rex.ErrAccessDenied
+	}
+	return nil
+}
+
+func (ph *processHandle) resource() rex.Resource {
+	return rex.Resource{
+		ProcessID: uuid.MustParse(ph.id),
+		OwnerID:   ph.ownerID,
+		Labels:    ph.labels,
+		GroupID:   ph.group,
+	}
+}
+
+func (s *supervisor) resource() rex.Resource {
+	return rex.Resource{
+		ProcessID: uuid.MustParse(s.id),
+		OwnerID:   s.ownerID,
+		Labels:    s.command.Labels,
+	}
+}
+
+// eventResource describes the process of an event, which may no longer exist
+func eventResource(info rex.ProcessInfo) rex.Resource {
+	return rex.Resource{
+		ProcessID: info.ID,
+		OwnerID:   info.OwnerID.String(),
+		Labels:    info.Labels,
+		GroupID:   info.GroupID,
+	}
+}
@@ -0,0 +1,55 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestResourcePolicy(t *testing.T) {
+	owner, auditor := uuid.New().String(), uuid.New().String()
+	// The auditor may act on any process labeled audit=true,
+	// and nobody else may act on the processes of others
+	s := localexec.NewServer(os.TempDir(), localexec.WithResourcePolicy(func(ctx context.Context) (bool, bool) {
+		userID, _ := rex.UserIDFromContext(ctx)
+		resource, ok := rex.ResourceFromContext(ctx)
+		if !ok {
+			return false, false
+		}
+		return true, resource.OwnerID == userID ||
+			(userID == auditor && resource.Labels["audit"] == "true")
+	}))
+	ownerCtx := rex.WithUserID(context.Background(), owner)
+	auditorCtx := rex.WithUserID(context.Background(), auditor)
+
+	audited, err := s.ExecCommand(ownerCtx, rex.Command{
+		Path: "sleep", Args: []string{"10"}, Labels: map[string]string{"audit": "true"}})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	private, err := s.Exec(ownerCtx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+
+	if _, err := s.GetProcessInfo(auditorCtx, audited); err != nil {
+		t.Errorf("Expected the auditor to see the audited process, got %v", err)
+	}
+	if _, err := s.Read(auditorCtx, audited, rex.StdoutStream); err != nil {
+		t.Errorf("Expected the auditor to read the audited process, got %v", err)
+	}
+	if _, err := s.GetProcessInfo(auditorCtx, private); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	for _, procID := range []uuid.UUID{audited, private} {
+		if err := s.Kill(ownerCtx, procID, int(syscall.SIGKILL)); err != nil {
+			t.Errorf("Expected the owner to kill its process, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package localexec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// eventLogSize is the number of most recent events that are retained in
+	// memory to allow watchers to resume after a reconnect.
+	eventLogSize = 4096
+	// maxPendingEvents is the number of events that may be queued for a
+	// single watcher before it is considered too slow and is disconnected.
+	maxPendingEvents = 4096
+)
+
+// eventLog assigns sequence numbers to the published events, retains the
+// most recent ones, and fans them out to the subscribed watchers.
+type eventLog struct {
+	m      sync.Mutex
+	seq    uint64
+	events []rex.Event
+	subs   map[*subscription]struct{}
+}
+
+type subscription struct {
+	filter  rex.EventFilter
+	pending []rex.Event
+	dropped bool
+	notify  chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+// publish records an event of the given type for the process described by
+// info and delivers it to the interested watchers.
+func (l *eventLog) publish(eventType rex.EventType, info rex.ProcessInfo, signal int) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.seq++
+	event := rex.Event{
+		Seq:     l.seq,
+		Type:    eventType,
+		Time:    time.Now().UTC(),
+		Process: info,
+		Signal:  signal,
+	}
+	if len(l.events) == eventLogSize {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:eventLogSize-1]
+	}
+	l.events = append(l.events, event)
+
+	for sub := range l.subs {
+		if sub.dropped || !sub.filter.Match(&event) {
+			continue
+		}
+		if len(sub.pending) == maxPendingEvents {
+			sub.dropped = true
+		} else {
+			sub.pending = append(sub.pending, event)
+		}
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch replays the retained events matching filter and then follows the
+// newly published ones until ctx is done.
+func (l *eventLog) watch(ctx context.Context, filter rex.EventFilter) <-chan rex.Event {
+	sub := &subscription{
+		filter: filter,
+		notify: make(chan struct{}, 1),
+	}
+
+	l.m.Lock()
+	for i := range l.events {
+		if filter.Match(&l.events[i]) {
+			sub.pending = append(sub.pending, l.events[i])
+		}
+	}
+	l.subs[sub] = struct{}{}
+	l.m.Unlock()
+
+	out := make(chan rex.Event)
+	go func() {
+		defer close(out)
+		defer func() {
+			l.m.Lock()
+			delete(l.subs, sub)
+			l.m.Unlock()
+		}()
+
+		for {
+			l.m.Lock()
+			batch, dropped := sub.pending, sub.dropped
+			sub.pending = nil
+			l.m.Unlock()
+
+			for _, event := range batch {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if dropped {
+				return
+			}
+
+			select {
+			case <-sub.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,144 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func receiveEvents(t *testing.T, events <-chan rex.Event, count int) []rex.Event {
+	var received []rex.Event
+	for len(received) < count {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("Events channel closed after %d events, expected %d", len(received), count)
+			}
+			received = append(received, event)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out after receiving %d events, expected %d", len(received), count)
+		}
+	}
+	return received
+}
+
+func TestWatch_Lifecycle(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx, cancel := context.WithCancel(rex.WithUserID(context.Background(), uuid.New().String()))
+	defer cancel()
+
+	events, err := s.Watch(ctx, rex.EventFilter{})
+	if err != nil {
+		t.Fatalf("While calling Watch: %v", err)
+	}
+
+	procID, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	if err := s.Kill(ctx, procID, int(syscall.SIGTERM)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+
+	expected := []rex.EventType{rex.EventCreated, rex.EventStarted, rex.EventSignaled, rex.EventExited}
+	received := receiveEvents(t, events, len(expected))
+	for i, event := range received {
+		if event.Type != expected[i] {
+			t.Errorf("Expected event %d to be %v, got %v", i, expected[i], event.Type)
+		}
+		if event.Process.ID != procID {
+			t.Errorf("Expected event %d to be about %v, got %v", i, procID, event.Process.ID)
+		}
+		if i > 0 && event.Seq <= received[i-1].Seq {
+			t.Errorf("Expected strictly increasing sequence numbers, got %d after %d", event.Seq, received[i-1].Seq)
+		}
+	}
+	if received[2].Signal != int(syscall.SIGTERM) {
+		t.Errorf("Expected the signaled event to carry signal %d, got %d", syscall.SIGTERM, received[2].Signal)
+	}
+	if received[3].Process.Running {
+		t.Errorf("Expected the process to not be running in the exited event")
+	}
+}
+
+func TestWatch_FilterAndResume(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx, cancel := context.WithCancel(rex.WithUserID(context.Background(), uuid.New().String()))
+	defer cancel()
+
+	if _, err := s.ExecCommand(ctx, rex.Command{Path: "true"}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path:   "true",
+		Labels: map[string]string{"team": "a"},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	events, err := s.Watch(ctx, rex.EventFilter{Labels: map[string]string{"team": "a"}})
+	if err != nil {
+		t.Fatalf("While calling Watch: %v", err)
+	}
+	received := receiveEvents(t, events, 2)
+	for _, event := range received {
+		if event.Process.ID != procID {
+			t.Errorf("Expected only the events of the labeled process, got one for %v", event.Process.ID)
+		}
+	}
+
+	resumed, err := s.Watch(ctx, rex.EventFilter{
+		Labels:   map[string]string{"team": "a"},
+		AfterSeq: received[0].Seq,
+	})
+	if err != nil {
+		t.Fatalf("While calling Watch: %v", err)
+	}
+	if event := receiveEvents(t, resumed, 1)[0]; event.Seq != received[1].Seq {
+		t.Errorf("Expected to resume from sequence number %d, got %d", received[1].Seq, event.Seq)
+	}
+}
+
+func TestWatch_Unauthenticated(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+
+	_, err := s.Watch(context.Background(), rex.EventFilter{})
+	if err != rex.ErrUnauthenticated {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrUnauthenticated, err)
+	}
+}
+
+func TestWatch_OtherOwners(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ownerCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	ctx, cancel := context.WithCancel(rex.WithUserID(context.Background(), uuid.New().String()))
+	defer cancel()
+
+	events, err := s.Watch(ctx, rex.EventFilter{})
+	if err != nil {
+		t.Fatalf("While calling Watch: %v", err)
+	}
+	if _, err := s.ExecCommand(ownerCtx, rex.Command{Path: "true", Args: []string{"secret"}}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	procID, err := s.Exec(ctx, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+
+	// The events of the process of the watcher are published after the
+	// ones of the other owner
+	for _, event := range receiveEvents(t, events, 2) {
+		if event.Process.ID != procID {
+			t.Errorf("Expected only the events of the own process, got one for %v", event.Process.ID)
+		}
+	}
+}
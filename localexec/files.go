@@ -0,0 +1,307 @@
+package localexec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// workspaceEnv points the processes to their own workspace
+	workspaceEnv = "REX_WORKSPACE"
+	// userWorkspaceEnv points the processes to the workspace of their owner
+	userWorkspaceEnv = "REX_USER_WORKSPACE"
+)
+
+// WithMaxFileSize limits the size of each of the files uploaded to the
+// workspaces. Zero or less means no limit.
+func WithMaxFileSize(maxFileSize int64) Option {
+	return func(ps *ProcessServer) {
+		ps.maxFileSize = maxFileSize
+	}
+}
+
+// Upload writes the content read from r to a file in the workspace of a
+// process of the caller or of the caller itself. The file is kept if the
+// upload is interrupted, allowing it to be resumed.
+func (ps *ProcessServer) Upload(ctx context.Context, file rex.FileRef,
+	offset int64, checksum string, r io.Reader) (rex.FileInfo, error) {
+	filename, err := ps.resolveFile(ctx, file, true)
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Errorf("Failed to close %s: %v", filename, err)
+		}
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	if offset != 0 && offset != stat.Size() {
+		return rex.FileInfo{}, fmt.Errorf("upload offset %d does not match the size %d of %s",
+			offset, stat.Size(), file.Path)
+	}
+	if err := f.Truncate(offset); err != nil {
+		return rex.FileInfo{}, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return rex.FileInfo{}, err
+	}
+
+	src := r
+	if ps.maxFileSize > 0 {
+		// One more byte than allowed tells the files exceeding the limit
+		src = io.LimitReader(r, ps.maxFileSize-offset+1)
+	}
+	written, err := io.Copy(f, src)
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	if ps.maxFileSize > 0 && offset+written > ps.maxFileSize {
+		removeFile(filename)
+		return rex.FileInfo{}, fmt.Errorf("%w: files are limited to %d bytes",
+			rex.ErrResourceExhausted, ps.maxFileSize)
+	}
+
+	info, err := fileInfo(f, file.Path)
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	if checksum != "" && !strings.EqualFold(checksum, info.SHA256) {
+		removeFile(filename)
+		return rex.FileInfo{}, fmt.Errorf("checksum mismatch: expected %s, got %s", checksum, info.SHA256)
+	}
+	return info, nil
+}
+
+// Download returns the content of a file in the workspace of a process of
+// the caller or of the caller itself
+func (ps *ProcessServer) Download(ctx context.Context, file rex.FileRef,
+	offset int64) (rex.FileInfo, io.ReadCloser, error) {
+	f, err := ps.openFile(ctx, file)
+	if err != nil {
+		return rex.FileInfo{}, nil, err
+	}
+	info, err := fileInfo(f, file.Path)
+	if err == nil && (offset < 0 || offset > info.Size) {
+		err = fmt.Errorf("download offset %d is out of the %d bytes of %s", offset, info.Size, file.Path)
+	}
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return rex.FileInfo{}, nil, err
+	}
+	return info, f, nil
+}
+
+// StatFile returns the info of a file in the workspace of a process of the
+// caller or of the caller itself
+func (ps *ProcessServer) StatFile(ctx context.Context, file rex.FileRef) (rex.FileInfo, error) {
+	f, err := ps.openFile(ctx, file)
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	defer f.Close()
+	return fileInfo(f, file.Path)
+}
+
+// openFile opens an existing regular file of a workspace for reading
+func (ps *ProcessServer) openFile(ctx context.Context, file rex.FileRef) (*os.File, error) {
+	filename, err := ps.resolveFile(ctx, file, false)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filename, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if os.IsNotExist(err) {
+		return nil, rex.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err == nil && !stat.Mode().IsRegular() {
+		err = fmt.Errorf("%s is not a regular file", file.Path)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// resolveFile checks that the caller has access to the workspace of file
+// and returns its path on the disk, creating its directory if create is
+// true. Symbolic links are not allowed to lead out of the workspace.
+func (ps *ProcessServer) resolveFile(ctx context.Context, file rex.FileRef, create bool) (string, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return "", rex.ErrUnauthenticated
+	}
+	// The user ID ends up in the path
+	ownerID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", err
+	}
+	workspace := ps.getUserWorkspace(ownerID.String())
+	if file.ProcessID != uuid.Nil {
+		handle, ok := ps.loadHandle(file.ProcessID)
+		if !ok {
+			return "", rex.ErrNotFound
+		}
+		if err := ps.authorize(ctx, handle.resource()); err != nil {
+			return "", err
+		}
+		workspace = ps.getProcessWorkspace(handle.id)
+	}
+
+	name, ok := relativePath(file.Path)
+	if !ok {
+		return "", fmt.Errorf("file path %q must be relative to the workspace", file.Path)
+	}
+	filename := filepath.Join(workspace, name)
+
+	if create {
+		if err := os.MkdirAll(workspace, 0755); err != nil {
+			return "", err
+		}
+	}
+	realWorkspace, err := filepath.EvalSymlinks(workspace)
+	if os.IsNotExist(err) {
+		return "", rex.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	// The missing directories are only created once the deepest existing
+	// one is known to be inside the workspace
+	dir, missing := filepath.Dir(filename), ""
+	for {
+		_, err := os.Lstat(dir)
+		if err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", err
+		} else if !create {
+			return "", rex.ErrNotFound
+		}
+		missing = filepath.Join(filepath.Base(dir), missing)
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if os.IsNotExist(err) {
+		return "", rex.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if realDir != realWorkspace && !strings.HasPrefix(realDir, realWorkspace+"/") {
+		return "", fmt.Errorf("file path %q leads out of the workspace", file.Path)
+	}
+	if missing != "" {
+		realDir = filepath.Join(realDir, missing)
+		if err := os.MkdirAll(realDir, 0755); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(realDir, filepath.Base(filename)), nil
+}
+
+// prepareWorkspaces creates the workspaces of a process and of its owner,
+// along with the private working directory of the process if it has asked
+// for one, and sets up the environment and the working directory of the
+// stages of the process
+func (ps *ProcessServer) prepareWorkspaces(stages []*exec.Cmd, processID, ownerID string,
+	command rex.Command) error {
+	workspace := ps.getProcessWorkspace(processID)
+	userWorkspace := ps.getUserWorkspace(ownerID)
+	dirs := []string{workspace, userWorkspace}
+	if command.PrivateWorkDir {
+		dirs = append(dirs, ps.getWorkDir(processID))
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	env := os.Environ()
+	for name, value := range command.Env {
+		env = append(env, name+"="+value)
+	}
+	// The later values win, so the workspaces cannot be overridden
+	env = append(env, workspaceEnv+"="+workspace, userWorkspaceEnv+"="+userWorkspace)
+	for _, stage := range stages {
+		stage.Env = env
+		stage.Dir = command.Dir
+		if command.PrivateWorkDir {
+			stage.Dir = ps.getWorkDir(processID)
+		}
+	}
+	return nil
+}
+
+func (ps *ProcessServer) getProcessWorkspace(processID string) string {
+	return path.Join(ps.getProcessDir(processID), "files")
+}
+
+func (ps *ProcessServer) getUserWorkspace(userID string) string {
+	return path.Join(ps.dataDir, "users", userID, "files")
+}
+
+// relativePath cleans name and reports whether it stays within the directory
+// that it is relative to
+func relativePath(name string) (string, bool) {
+	clean := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(clean) || clean == "." ||
+		clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// fileInfo describes f, hashing its whole content
+func fileInfo(f *os.File, name string) (rex.FileInfo, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return rex.FileInfo{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return rex.FileInfo{}, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return rex.FileInfo{}, err
+	}
+	return rex.FileInfo{
+		Path:     filepath.ToSlash(filepath.Clean(name)),
+		Size:     stat.Size(),
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		Modified: stat.ModTime().UTC(),
+	}, nil
+}
+
+func removeFile(filename string) {
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("Failed to remove %s: %v", filename, err)
+	}
+}
@@ -0,0 +1,179 @@
+package localexec_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestFiles_UploadAndDownload(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "rex-files")
+	if err != nil {
+		t.Fatalf("While creating the data dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	s := localexec.NewServer(dataDir, localexec.WithMaxFileSize(10))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	checksum := sha256.Sum256([]byte("0123456789"))
+	file := rex.FileRef{Path: "inputs/digits.txt"}
+	info, err := s.Upload(ctx, file, 0, "", strings.NewReader("01234"))
+	if err != nil || info.Size != 5 {
+		t.Fatalf("Expected the first half to be uploaded, got %+v, %v", info, err)
+	}
+	if _, err := s.Upload(ctx, file, 3, "", strings.NewReader("56789")); err == nil {
+		t.Errorf("Expected a mismatching offset to be rejected")
+	}
+	info, err = s.Upload(ctx, file, 5, hex.EncodeToString(checksum[:]), strings.NewReader("56789"))
+	if err != nil || info.Size != 10 || info.Path != "inputs/digits.txt" {
+		t.Fatalf("Expected the upload to be resumed, got %+v, %v", info, err)
+	}
+
+	info, content, err := s.Download(ctx, file, 4)
+	if err != nil {
+		t.Fatalf("While calling Download: %v", err)
+	}
+	rest, _ := ioutil.ReadAll(content)
+	content.Close()
+	if string(rest) != "456789" || info.SHA256 != hex.EncodeToString(checksum[:]) {
+		t.Errorf("Expected the rest of the file along with its checksum, got %q, %+v", rest, info)
+	}
+
+	if _, err := s.Upload(ctx, file, 0, "bad", strings.NewReader("x")); err == nil {
+		t.Errorf("Expected a checksum mismatch")
+	}
+	if _, err := s.StatFile(ctx, file); err != rex.ErrNotFound {
+		t.Errorf("Expected the file with a bad checksum to be removed, got %v", err)
+	}
+	_, err = s.Upload(ctx, file, 0, "", bytes.NewReader(make([]byte, 11)))
+	if !errors.Is(err, rex.ErrResourceExhausted) {
+		t.Errorf("Expected error %v for a large file, got %v", rex.ErrResourceExhausted, err)
+	}
+	for _, path := range []string{"", "/etc/passwd", "../escape", "a/../../escape"} {
+		if _, err := s.Upload(ctx, rex.FileRef{Path: path}, 0, "", strings.NewReader("x")); err == nil {
+			t.Errorf("Expected path %q to be rejected", path)
+		}
+	}
+}
+
+func TestFiles_ProcessWorkspace(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "rex-files")
+	if err != nil {
+		t.Fatalf("While creating the data dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	s := localexec.NewServer(dataDir)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	if _, err := s.Upload(ctx, rex.FileRef{Path: "input"}, 0, "", strings.NewReader("hello")); err != nil {
+		t.Fatalf("While calling Upload: %v", err)
+	}
+	procID, err := s.Exec(ctx, "sh", "-c",
+		`tr a-z A-Z < "$REX_USER_WORKSPACE/input" > "$REX_WORKSPACE/output"; ln -s / "$REX_WORKSPACE/root"`)
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessExited)
+
+	info, content, err := s.Download(ctx, rex.FileRef{ProcessID: procID, Path: "output"}, 0)
+	if err != nil {
+		t.Fatalf("While calling Download: %v", err)
+	}
+	output, _ := ioutil.ReadAll(content)
+	content.Close()
+	if string(output) != "HELLO" || info.Size != 5 {
+		t.Errorf("Expected the output of the process, got %q, %+v", output, info)
+	}
+	if _, err := s.StatFile(ctx, rex.FileRef{ProcessID: procID, Path: "root/etc/passwd"}); err == nil {
+		t.Errorf("Expected symbolic links not to lead out of the workspace")
+	}
+
+	other := rex.WithUserID(context.Background(), uuid.New().String())
+	if _, err := s.StatFile(other, rex.FileRef{ProcessID: procID, Path: "output"}); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+}
+
+func TestFiles_SymlinkedDirectory(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "rex-files")
+	if err != nil {
+		t.Fatalf("While creating the data dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	outside, err := ioutil.TempDir("", "rex-outside")
+	if err != nil {
+		t.Fatalf("While creating the outside dir: %v", err)
+	}
+	defer os.RemoveAll(outside)
+	s := localexec.NewServer(dataDir)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.Exec(ctx, "sh", "-c", `ln -s "$0" "$REX_WORKSPACE/escape"`, outside)
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessExited)
+	file := rex.FileRef{ProcessID: procID, Path: "escape/created/file"}
+	if _, err := s.Upload(ctx, file, 0, "", strings.NewReader("x")); err == nil {
+		t.Errorf("Expected symbolic links not to lead out of the workspace")
+	}
+	entries, err := ioutil.ReadDir(outside)
+	if err != nil {
+		t.Fatalf("While listing the outside dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing to be created out of the workspace, got %d entries", len(entries))
+	}
+}
+
+func TestFiles_Artifacts(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "rex-files")
+	if err != nil {
+		t.Fatalf("While creating the data dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	s := localexec.NewServer(dataDir)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	if _, err := s.ExecCommand(ctx, rex.Command{Path: "true", Artifacts: []string{"*.txt"}}); err == nil {
+		t.Errorf("Expected artifacts to require a private working directory")
+	}
+
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path:           "sh",
+		Args:           []string{"-c", `pwd > dir.txt; mkdir -p dist/bin; echo x > dist/bin/tool; echo y > scratch.o`},
+		PrivateWorkDir: true,
+		Artifacts:      []string{"*.txt", "dist"},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	info := waitForState(t, s, ctx, procID, rex.ProcessExited)
+	if len(info.Artifacts) != 2 || info.Artifacts[0] != "dir.txt" || info.Artifacts[1] != "dist/bin/tool" {
+		t.Errorf("Expected the matching files to be collected, got %v", info.Artifacts)
+	}
+
+	_, content, err := s.Download(ctx, rex.FileRef{ProcessID: procID, Path: "dir.txt"}, 0)
+	if err != nil {
+		t.Fatalf("While calling Download: %v", err)
+	}
+	workDir, _ := ioutil.ReadAll(content)
+	content.Close()
+	if !strings.Contains(string(workDir), procID.String()) {
+		t.Errorf("Expected the process to run in a directory of its own, got %q", workDir)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(workDir))); !os.IsNotExist(err) {
+		t.Errorf("Expected the working directory to be removed, got %v", err)
+	}
+}
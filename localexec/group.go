@@ -0,0 +1,275 @@
+package localexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// groupPollInterval is the wait between the checks of the processes of a
+// group that is being waited for
+const groupPollInterval = 100 * time.Millisecond
+
+// group is a set of processes of the same owner that are managed as a unit
+type group struct {
+	id      string
+	ownerID string
+	create  time.Time
+
+	m       sync.Mutex
+	members []*processHandle
+	// removed is set once the last member leaves the group, after which the
+	// group is no longer reachable through ProcessServer.groups
+	removed bool
+}
+
+// joinGroup adds a process that is being created to its group, creating the
+// group if it does not exist. Fails if the group belongs to someone else,
+// since the members of a group share its owner. The caller must have been
+// authorized to act on the group with authorizeGroup.
+func (ps *ProcessServer) joinGroup(handle *processHandle) error {
+	for {
+		mustBeGroup, _ := ps.groups.LoadOrStore(handle.group.String(), &group{
+			id:      handle.group.String(),
+			ownerID: handle.ownerID,
+			create:  handle.create,
+		})
+		g := mustBeGroup.(*group)
+		if g.ownerID != handle.ownerID {
+			return rex.ErrAccessDenied
+		}
+
+		g.m.Lock()
+		if g.removed {
+			// Emptied in the meantime, try again with a new group
+			g.m.Unlock()
+			continue
+		}
+		g.members = append(g.members, handle)
+		g.m.Unlock()
+		return nil
+	}
+}
+
+// leaveGroup removes a process from its group, removing the group once it
+// is empty.
+func (ps *ProcessServer) leaveGroup(handle *processHandle) {
+	if handle.group == uuid.Nil {
+		return
+	}
+	mustBeGroup, ok := ps.groups.Load(handle.group.String())
+	if !ok {
+		return
+	}
+	g := mustBeGroup.(*group)
+	g.m.Lock()
+	defer g.m.Unlock()
+	for i, member := range g.members {
+		if member == handle {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	if len(g.members) == 0 && !g.removed {
+		g.removed = true
+		ps.groups.Delete(g.id)
+	}
+}
+
+// GetGroupInfo returns the aggregate status of a group of the caller
+func (ps *ProcessServer) GetGroupInfo(ctx context.Context, groupID uuid.UUID) (rex.GroupInfo, error) {
+	g, err := ps.loadGroup(ctx, groupID)
+	if err != nil {
+		return rex.GroupInfo{}, err
+	}
+	return ps.groupInfo(g), nil
+}
+
+// ListGroups returns the groups that the caller may act on sorted by their
+// creation time (newest first)
+func (ps *ProcessServer) ListGroups(ctx context.Context) ([]rex.GroupInfo, error) {
+	if _, ok := rex.UserIDFromContext(ctx); !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+
+	var groups []*group
+	ps.groups.Range(func(key, value interface{}) bool {
+		if g := value.(*group); ps.authorizeGroup(ctx, g) == nil {
+			groups = append(groups, g)
+		}
+		return true
+	})
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].create.After(groups[j].create)
+	})
+	var infoList []rex.GroupInfo
+	for _, g := range groups {
+		infoList = append(infoList, ps.groupInfo(g))
+	}
+	return infoList, nil
+}
+
+// KillGroup sends a signal to the queued and running processes of a group.
+// Succeeds if the signal is delivered to any of them, or if none of them is
+// queued or running.
+func (ps *ProcessServer) KillGroup(ctx context.Context, groupID uuid.UUID, signal int) error {
+	g, err := ps.loadGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	delivered := false
+	for _, handle := range g.snapshot() {
+		state := handle.getProcessInfo().State
+		if state != rex.ProcessQueued && state != rex.ProcessRunning {
+			continue
+		}
+		if err := ps.signal(handle, signal); err != nil {
+			log.Warnf("Failed to signal process %s of group %s: %v", handle.id, g.id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		return nil
+	}
+	return firstErr
+}
+
+// WaitGroup polls the processes of a group until all or any of them reach
+// their final state.
+func (ps *ProcessServer) WaitGroup(ctx context.Context, groupID uuid.UUID,
+	mode rex.GroupWaitMode) (rex.GroupInfo, error) {
+	if mode != rex.GroupWaitAll && mode != rex.GroupWaitAny {
+		return rex.GroupInfo{}, fmt.Errorf("unknown wait mode %d", mode)
+	}
+	g, err := ps.loadGroup(ctx, groupID)
+	if err != nil {
+		return rex.GroupInfo{}, err
+	}
+
+	ticker := time.NewTicker(groupPollInterval)
+	defer ticker.Stop()
+	for {
+		info := ps.groupInfo(g)
+		finished := info.Succeeded + info.Failed
+		if (mode == rex.GroupWaitAll && finished == len(info.Processes)) ||
+			(mode == rex.GroupWaitAny && finished > 0) {
+			return info, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return rex.GroupInfo{}, ctx.Err()
+		}
+	}
+}
+
+// DeleteGroup removes the processes of a group along with their output,
+// canceling the queued ones. Fails if any of them is running.
+func (ps *ProcessServer) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
+	g, err := ps.loadGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	members := g.snapshot()
+	for _, handle := range members {
+		if handle.getProcessInfo().State == rex.ProcessRunning {
+			return rex.ErrProcessRunning
+		}
+	}
+	for _, handle := range members {
+		// Another call might have deleted the process in the meantime
+		if err := ps.delete(handle); err != nil && !errors.Is(err, rex.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGroup returns a group that the caller may act on
+func (ps *ProcessServer) loadGroup(ctx context.Context, groupID uuid.UUID) (*group, error) {
+	mustBeGroup, ok := ps.groups.Load(groupID.String())
+	if !ok {
+		return nil, rex.ErrNotFound
+	}
+	g := mustBeGroup.(*group)
+	if err := ps.authorizeGroup(ctx, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// authorizeGroup checks that the caller may act on every member of a group.
+// A group that has just been emptied is authorized as a process of its owner
+// without labels.
+func (ps *ProcessServer) authorizeGroup(ctx context.Context, g *group) error {
+	members := g.snapshot()
+	if len(members) == 0 {
+		return ps.authorize(ctx, rex.Resource{OwnerID: g.ownerID, GroupID: uuid.MustParse(g.id)})
+	}
+	for _, handle := range members {
+		if err := ps.authorize(ctx, handle.resource()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupInfo aggregates the state of the processes of a group
+func (ps *ProcessServer) groupInfo(g *group) rex.GroupInfo {
+	info := rex.GroupInfo{
+		ID:      uuid.MustParse(g.id),
+		OwnerID: uuid.MustParse(g.ownerID),
+	}
+	for _, handle := range g.snapshot() {
+		process := ps.getProcessInfo(handle)
+		switch {
+		case process.State == rex.ProcessQueued:
+			info.Queued++
+		case process.State == rex.ProcessRunning:
+			info.Running++
+		case process.State == rex.ProcessExited && process.ExitCode == 0:
+			info.Succeeded++
+		default:
+			info.Failed++
+		}
+		info.Processes = append(info.Processes, process)
+	}
+	sort.SliceStable(info.Processes, func(i, j int) bool {
+		return info.Processes[i].Create.Before(info.Processes[j].Create)
+	})
+
+	switch {
+	case info.Queued+info.Running > 0:
+		info.State = rex.GroupRunning
+	case info.Failed > 0:
+		info.State = rex.GroupFailed
+	default:
+		info.State = rex.GroupSucceeded
+	}
+	return info
+}
+
+// snapshot returns the current members of the group. The members are locked
+// after g.m by the callers of leaveGroup, hence they must not be locked while
+// holding g.m.
+func (g *group) snapshot() []*processHandle {
+	g.m.Lock()
+	defer g.m.Unlock()
+	return append([]*processHandle(nil), g.members...)
+}
@@ -0,0 +1,151 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestGroup_WaitAndKill(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	groupID := uuid.New()
+
+	for _, args := range [][]string{{"-c", "exit 0"}, {"-c", "sleep 10"}, {"-c", "sleep 10"}} {
+		if _, err := s.ExecCommand(ctx, rex.Command{Path: "sh", Args: args, GroupID: groupID}); err != nil {
+			t.Fatalf("While calling ExecCommand: %v", err)
+		}
+	}
+
+	info, err := s.WaitGroup(ctx, groupID, rex.GroupWaitAny)
+	if err != nil {
+		t.Fatalf("While calling WaitGroup: %v", err)
+	}
+	if info.State != rex.GroupRunning || info.Succeeded != 1 || info.Running != 2 {
+		t.Errorf("Expected one succeeded and two running processes, got %+v", info)
+	}
+
+	if err := s.KillGroup(ctx, groupID, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling KillGroup: %v", err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	info, err = s.WaitGroup(timeoutCtx, groupID, rex.GroupWaitAll)
+	if err != nil {
+		t.Fatalf("While calling WaitGroup: %v", err)
+	}
+	if info.State != rex.GroupFailed || info.Succeeded != 1 || info.Failed != 2 {
+		t.Errorf("Expected one succeeded and two failed processes, got %+v", info)
+	}
+	for _, process := range info.Processes {
+		if process.GroupID != groupID {
+			t.Errorf("Expected process %v to be in group %v, got %v", process.ID, groupID, process.GroupID)
+		}
+	}
+
+	if err := s.DeleteGroup(ctx, groupID); err != nil {
+		t.Fatalf("While calling DeleteGroup: %v", err)
+	}
+	if _, err := s.GetGroupInfo(ctx, groupID); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+}
+
+func TestGroup_Ownership(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ownerCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	groupID := uuid.New()
+
+	procID, err := s.ExecCommand(ownerCtx, rex.Command{Path: "sleep", Args: []string{"10"}, GroupID: groupID})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	defer s.Kill(ownerCtx, procID, int(syscall.SIGKILL))
+
+	if _, err := s.ExecCommand(otherCtx, rex.Command{Path: "true", GroupID: groupID}); err != rex.ErrAccessDenied {
+		t.Errorf("Expected joining the group of another user to fail with %v, actual: %v",
+			rex.ErrAccessDenied, err)
+	}
+	if err := s.KillGroup(otherCtx, groupID, int(syscall.SIGKILL)); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	if err := s.DeleteGroup(ownerCtx, groupID); err != rex.ErrProcessRunning {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrProcessRunning, err)
+	}
+
+	groups, err := s.ListGroups(otherCtx)
+	if err != nil || len(groups) != 0 {
+		t.Errorf("Expected no groups for the other user, got %v, %v", groups, err)
+	}
+	groups, err = s.ListGroups(ownerCtx)
+	if err != nil || len(groups) != 1 || len(groups[0].Processes) != 1 {
+		t.Errorf("Expected one group with one process, got %+v, %v", groups, err)
+	}
+}
+
+func TestGroup_ResourcePolicy(t *testing.T) {
+	owner, ops := uuid.New().String(), uuid.New().String()
+	// ops may act on any process, and nobody may act on the processes
+	// labeled env=prod
+	s := localexec.NewServer(os.TempDir(), localexec.WithResourcePolicy(func(ctx context.Context) (bool, bool) {
+		userID, _ := rex.UserIDFromContext(ctx)
+		resource, ok := rex.ResourceFromContext(ctx)
+		if !ok {
+			return false, false
+		}
+		if resource.Labels["env"] == "prod" {
+			return false, true
+		}
+		return true, resource.OwnerID == userID || userID == ops
+	}))
+	ownerCtx := rex.WithUserID(context.Background(), owner)
+	opsCtx := rex.WithUserID(context.Background(), ops)
+
+	groupID := uuid.New()
+	if _, err := s.ExecCommand(ownerCtx, rex.Command{Path: "sleep", Args: []string{"10"}, GroupID: groupID}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if _, err := s.GetGroupInfo(opsCtx, groupID); err != nil {
+		t.Errorf("Expected ops to see the group, got %v", err)
+	}
+	groups, err := s.ListGroups(opsCtx)
+	if err != nil || len(groups) != 1 {
+		t.Errorf("Expected ops to list the group, got %+v, %v", groups, err)
+	}
+	if err := s.KillGroup(opsCtx, groupID, int(syscall.SIGKILL)); err != nil {
+		t.Errorf("Expected ops to kill the group, got %v", err)
+	}
+
+	// The owner may create the processes labeled env=prod, but may not act
+	// on them through their group
+	prodGroupID := uuid.New()
+	prodID, err := s.ExecCommand(ownerCtx, rex.Command{Path: "sleep", Args: []string{"10"},
+		GroupID: prodGroupID, Labels: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if err := s.KillGroup(ownerCtx, prodGroupID, int(syscall.SIGKILL)); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	if err := s.DeleteGroup(ownerCtx, prodGroupID); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	if _, err := s.ExecCommand(ownerCtx, rex.Command{Path: "true", GroupID: prodGroupID}); err != rex.ErrAccessDenied {
+		t.Errorf("Expected joining the group to fail with %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	if _, err := s.GetProcessInfo(ownerCtx, prodID); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	groups, err = s.ListGroups(ownerCtx)
+	if err != nil || len(groups) != 1 || groups[0].ID != groupID {
+		t.Errorf("Expected the owner to list only group %v, got %+v, %v", groupID, groups, err)
+	}
+}
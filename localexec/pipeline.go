@@ -0,0 +1,154 @@
+package localexec
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// newStages creates the processes of command: a single one, or one per stage
+// if command is a pipeline.
+func newStages(command rex.Command) []*exec.Cmd {
+	stages := []*exec.Cmd{exec.Command(command.Path, command.Args...)}
+	for _, stage := range command.Pipeline {
+		stages = append(stages, exec.Command(stage.Path, stage.Args...))
+	}
+	return stages
+}
+
+// lookPath checks that the executable of a stage can be found, relative to
+// the working directory of the stage as it will be once the stage starts
+func lookPath(stage *exec.Cmd) error {
+	name := stage.Path
+	if strings.Contains(name, "/") && !filepath.IsAbs(name) {
+		name = filepath.Join(stage.Dir, name)
+		if !strings.Contains(name, "/") {
+			name = "./" + name
+		}
+	}
+	_, err := exec.LookPath(name)
+	return err
+}
+
+// setOutput directs the stdout of the last stage and the stderr of all of the
+// stages to the given writers
+func setOutput(stages []*exec.Cmd, stdout, stderr io.Writer) {
+	for _, stage := range stages {
+		stage.Stderr = stderr
+	}
+	stages[len(stages)-1].Stdout = stdout
+}
+
+// startStages starts the stages, connecting the stdout of each one to the
+// stdin of the next. If any of them fails to start, the ones that have
+// started are killed and waited for.
+func startStages(stages []*exec.Cmd) error {
+	var pipes []*os.File
+	defer func() {
+		// The stages hold their own copies of the pipes
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+	}()
+	for i := 0; i+1 < len(stages); i++ {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		pipes = append(pipes, reader, writer)
+		stages[i].Stdout = writer
+		stages[i+1].Stdin = reader
+	}
+
+	for i, stage := range stages {
+		if err := stage.Start(); err != nil {
+			for _, started := range stages[:i] {
+				if err := started.Process.Kill(); err != nil {
+					log.Warnf("Failed to kill stage %s: %v", started.Path, err)
+				}
+				started.Wait()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// signalStages sends a signal to all of the stages that have started.
+// Succeeds if it is delivered to any of them.
+func signalStages(stages []*exec.Cmd, signal syscall.Signal) error {
+	var firstErr error
+	delivered := false
+	for _, stage := range stages {
+		if stage.Process == nil {
+			continue
+		}
+		if err := stage.Process.Signal(signal); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		return nil
+	}
+	return firstErr
+}
+
+// waitStages waits for all of the stages of a process to exit, recording the
+// exit of each one as it happens. Returns the error of the last stage.
+func (ps *ProcessServer) waitStages(handle *processHandle) error {
+	if len(handle.stages) == 1 {
+		return handle.cmd.Wait()
+	}
+
+	errs := make([]error, len(handle.stages))
+	var wg sync.WaitGroup
+	for i, stage := range handle.stages {
+		wg.Add(1)
+		go func(i int, stage *exec.Cmd) {
+			defer wg.Done()
+			errs[i] = stage.Wait()
+			handle.m.Lock()
+			handle.stageExited[i] = true
+			handle.m.Unlock()
+		}(i, stage)
+	}
+	wg.Wait()
+	return errs[len(errs)-1]
+}
+
+// stagesInfoLocked describes the stages of a pipeline. Requires the caller
+// to hold ph.m
+func (ph *processHandle) stagesInfoLocked() []rex.StageInfo {
+	var stages []rex.StageInfo
+	for i, stage := range ph.stages {
+		info := rex.StageInfo{
+			Path:   stage.Path,
+			Args:   stage.Args[1:],
+			Exited: ph.stageExited[i],
+		}
+		if stage.Process != nil {
+			info.PID = stage.Process.Pid
+		}
+		if info.Exited {
+			info.ExitCode = stage.ProcessState.ExitCode()
+		}
+		stages = append(stages, info)
+	}
+	return stages
+}
+
+func (ph *processHandle) lastStage() *exec.Cmd {
+	return ph.stages[len(ph.stages)-1]
+}
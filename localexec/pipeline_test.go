@@ -0,0 +1,92 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestPipeline_Output(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path: "printf",
+		Args: []string{"b\\na\\nb\\n"},
+		Pipeline: []rex.PipelineStage{
+			{Path: "sort"},
+			{Path: "uniq", Args: []string{"-c"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	info := waitForState(t, s, ctx, procID, rex.ProcessExited)
+
+	content, err := s.Read(ctx, procID, rex.StdoutStream)
+	if err != nil {
+		t.Fatalf("While calling Read: %v", err)
+	}
+	if expected := "      1 a\n      2 b\n"; string(content) != expected {
+		t.Errorf("Expected output %q, got %q", expected, content)
+	}
+	if len(info.Stages) != 3 {
+		t.Fatalf("Expected 3 stages, got %+v", info.Stages)
+	}
+	for _, stage := range info.Stages {
+		if !stage.Exited || stage.ExitCode != 0 || stage.PID == 0 {
+			t.Errorf("Expected stage %s to have exited with code 0, got %+v", stage.Path, stage)
+		}
+	}
+	if info.PID != info.Stages[0].PID {
+		t.Errorf("Expected the pid of the first stage, got %d", info.PID)
+	}
+}
+
+func TestPipeline_Status(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path: "sh",
+		Args: []string{"-c", "echo failing >&2; exit 3"},
+		Pipeline: []rex.PipelineStage{
+			{Path: "cat"},
+			{Path: "sh", Args: []string{"-c", "cat; echo last >&2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	info := waitForState(t, s, ctx, procID, rex.ProcessExited)
+
+	var exitCodes []int
+	for _, stage := range info.Stages {
+		exitCodes = append(exitCodes, stage.ExitCode)
+	}
+	if len(exitCodes) != 3 || exitCodes[0] != 3 || exitCodes[1] != 0 || exitCodes[2] != 0 {
+		t.Errorf("Expected the exit codes of the stages to be [3 0 0], got %v", exitCodes)
+	}
+	if info.ExitCode != 0 {
+		t.Errorf("Expected the exit code of the last stage, got %d", info.ExitCode)
+	}
+	content, err := s.Read(ctx, procID, rex.StderrStream)
+	if err != nil {
+		t.Fatalf("While calling Read: %v", err)
+	}
+	if string(content) != "failing\nlast\n" {
+		t.Errorf("Expected the stderr of all of the stages, got %q", content)
+	}
+
+	if _, err := s.ExecCommand(ctx, rex.Command{
+		Path:     "true",
+		Pipeline: []rex.PipelineStage{{Path: "/nonexistent"}},
+	}); err == nil {
+		t.Errorf("Expected a pipeline with a missing executable to fail")
+	}
+}
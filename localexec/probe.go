@@ -0,0 +1,213 @@
+package localexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	defaultProbeInterval = 10 * time.Second
+	defaultProbeTimeout  = time.Second
+	// maxProbeMessage limits the size of the output kept from each check
+	maxProbeMessage = 256
+)
+
+// probesWithDefaults validates the probes of command and fills in their
+// unset fields.
+func probesWithDefaults(command rex.Command) ([]rex.Probe, error) {
+	var probes []rex.Probe
+	for _, probe := range command.Probes {
+		switch probe.Type {
+		case rex.ProbeExec:
+			if len(probe.Command) == 0 {
+				return nil, errors.New("exec probe without a command")
+			}
+		case rex.ProbeTCP, rex.ProbeHTTP:
+			if probe.Port < 1 || probe.Port > 65535 {
+				return nil, fmt.Errorf("%v probe with invalid port %d", probe.Type, probe.Port)
+			}
+		default:
+			return nil, fmt.Errorf("unknown probe type %d", probe.Type)
+		}
+		if probe.Interval < 0 || probe.Timeout < 0 || probe.FailureThreshold < 0 || probe.RestartAfter < 0 {
+			return nil, fmt.Errorf("%v probe with negative settings", probe.Type)
+		}
+		if probe.RestartAfter > 0 && command.Restart == nil {
+			return nil, errors.New("probes can only restart supervised services")
+		}
+
+		if probe.Name == "" {
+			probe.Name = probe.Type.String()
+		}
+		if probe.Type == rex.ProbeHTTP && probe.Path == "" {
+			probe.Path = "/"
+		}
+		if probe.Interval == 0 {
+			probe.Interval = defaultProbeInterval
+		}
+		if probe.Timeout == 0 {
+			probe.Timeout = defaultProbeTimeout
+		}
+		if probe.FailureThreshold == 0 {
+			probe.FailureThreshold = 1
+		}
+		probe.Command = append([]string(nil), probe.Command...)
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// startProbesLocked starts checking a process that has just started.
+// Requires the caller to hold handle.m
+func (ps *ProcessServer) startProbesLocked(handle *processHandle) {
+	if len(handle.probes) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	handle.stopProbes = cancel
+	for i := range handle.probes {
+		go ps.runProbe(ctx, handle, i)
+	}
+}
+
+// runProbe checks a process with its i-th probe until ctx is done
+func (ps *ProcessServer) runProbe(ctx context.Context, handle *processHandle, i int) {
+	probe := handle.probes[i]
+	ticker := time.NewTicker(probe.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, probe.Timeout)
+		message, err := check(checkCtx, handle, probe)
+		cancel()
+		if ctx.Err() != nil {
+			// The process has exited in the meantime
+			return
+		}
+		ps.recordProbe(handle, i, message, err)
+	}
+}
+
+// recordProbe updates the health of a process after a check, killing it if
+// the probe says so.
+func (ps *ProcessServer) recordProbe(handle *processHandle, i int, message string, err error) {
+	handle.m.Lock()
+	if handle.state != rex.ProcessRunning {
+		handle.m.Unlock()
+		return
+	}
+	probe := handle.probes[i]
+	status := &handle.probeStatus[i]
+	previousHealth := handle.healthLocked()
+
+	status.LastCheck = time.Now().UTC()
+	if err != nil {
+		status.ConsecutiveFailures++
+		if message != "" {
+			message = err.Error() + ": " + message
+		} else {
+			message = err.Error()
+		}
+		status.LastMessage = truncateMessage(message)
+		if status.ConsecutiveFailures >= probe.FailureThreshold {
+			status.Health = rex.HealthUnhealthy
+		}
+	} else {
+		status.ConsecutiveFailures = 0
+		status.LastMessage = truncateMessage(message)
+		status.Health = rex.HealthHealthy
+	}
+	if health := handle.healthLocked(); health != previousHealth {
+		ps.events.publish(rex.EventHealthChanged, handle.processInfoLocked(), 0)
+	}
+	restart := probe.RestartAfter > 0 && status.ConsecutiveFailures == probe.RestartAfter
+	handle.m.Unlock()
+
+	if restart {
+		log.Infof("Killing process %s after %d failed %s checks", handle.id, probe.RestartAfter, probe.Name)
+		if err := ps.signal(handle, int(syscall.SIGKILL)); err != nil {
+			log.Warnf("Failed to kill unhealthy process %s: %v", handle.id, err)
+		}
+	}
+}
+
+// check runs a single check of probe against a process, returning a short
+// description of the outcome.
+func check(ctx context.Context, handle *processHandle, probe rex.Probe) (string, error) {
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(probe.Port))
+	switch probe.Type {
+	case rex.ProbeExec:
+		cmd := exec.CommandContext(ctx, probe.Command[0], probe.Command[1:]...)
+		cmd.Dir = handle.cmd.Dir
+		cmd.Env = handle.cmd.Env
+		output, err := cmd.CombinedOutput()
+		return strings.TrimSpace(string(output)), err
+
+	case rex.ProbeTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "connected to " + address, nil
+
+	case rex.ProbeHTTP:
+		url := "http://" + address + probe.Path
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return "GET " + url + ": " + resp.Status, nil
+	}
+	return "", fmt.Errorf("unknown probe type %d", probe.Type)
+}
+
+func truncateMessage(message string) string {
+	if len(message) > maxProbeMessage {
+		return message[:maxProbeMessage] + "..."
+	}
+	return message
+}
+
+// healthLocked combines the health reported by the probes of a process.
+// Requires the caller to hold ph.m
+func (ph *processHandle) healthLocked() rex.HealthState {
+	if len(ph.probeStatus) == 0 {
+		return rex.HealthUnknown
+	}
+	health := rex.HealthHealthy
+	for _, status := range ph.probeStatus {
+		if status.Health == rex.HealthUnhealthy {
+			return rex.HealthUnhealthy
+		}
+		if status.Health == rex.HealthUnknown {
+			health = rex.HealthUnknown
+		}
+	}
+	return health
+}
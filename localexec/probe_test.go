@@ -0,0 +1,118 @@
+package localexec_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func waitForHealth(t *testing.T, s rex.Service, ctx context.Context,
+	procID uuid.UUID, health rex.HealthState) rex.ProcessInfo {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, err := s.GetProcessInfo(ctx, procID)
+		if err != nil {
+			t.Fatalf("While calling GetProcessInfo: %v", err)
+		}
+		if info.Health == health {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected process to become %v, it is %v", health, info.Health)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProbe_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("While listening: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path: "sleep",
+		Args: []string{"10"},
+		Probes: []rex.Probe{{
+			Type:             rex.ProbeTCP,
+			Port:             port,
+			Interval:         10 * time.Millisecond,
+			FailureThreshold: 2,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	defer s.Kill(ctx, procID, int(syscall.SIGKILL))
+
+	info := waitForHealth(t, s, ctx, procID, rex.HealthHealthy)
+	if len(info.Probes) != 1 || info.Probes[0].Name != "tcp" {
+		t.Fatalf("Expected the status of the tcp probe, got %+v", info.Probes)
+	}
+
+	listener.Close()
+	info = waitForHealth(t, s, ctx, procID, rex.HealthUnhealthy)
+	if failures := info.Probes[0].ConsecutiveFailures; failures < 2 {
+		t.Errorf("Expected at least 2 consecutive failures, got %d", failures)
+	}
+}
+
+func TestProbe_RestartAfter(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	probe := rex.Probe{
+		Type:         rex.ProbeExec,
+		Command:      []string{"false"},
+		Interval:     10 * time.Millisecond,
+		RestartAfter: 2,
+	}
+	if _, err := s.ExecCommand(ctx, rex.Command{
+		Path:   "sleep",
+		Args:   []string{"10"},
+		Probes: []rex.Probe{probe},
+	}); err == nil {
+		t.Errorf("Expected restarting probes to require a restart policy")
+	}
+
+	serviceID, err := s.ExecCommand(ctx, rex.Command{
+		Path:   "sleep",
+		Args:   []string{"10"},
+		Probes: []rex.Probe{probe},
+		Restart: &rex.RestartPolicy{
+			Mode:        rex.RestartOnFailure,
+			MaxRestarts: 1,
+			Backoff:     10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	info := waitForServiceState(t, s, ctx, serviceID, rex.ServiceFinished)
+	for _, incarnation := range info.Incarnations {
+		if incarnation.Health != rex.HealthUnhealthy {
+			t.Errorf("Expected incarnation %d to be unhealthy, it is %v",
+				incarnation.Incarnation, incarnation.Health)
+		}
+		if message := incarnation.Probes[0].LastMessage; message != "exit status 1" {
+			t.Errorf("Expected the last message to be %q, got %q", "exit status 1", message)
+		}
+	}
+	if len(info.Incarnations) != 2 {
+		t.Errorf("Expected the unhealthy service to be restarted once, got %d incarnations",
+			len(info.Incarnations))
+	}
+}
@@ -0,0 +1,145 @@
+package localexec
+
+import (
+	"sync"
+)
+
+// jobQueue orders the processes that are waiting to be started and keeps
+// track of the running ones, allowing ProcessServer to enforce its limits on
+// the number of concurrently running processes. A limit of zero or less
+// means no limit.
+type jobQueue struct {
+	maxConcurrent   int
+	maxPerPrincipal int
+
+	m               sync.Mutex
+	waiting         []*processHandle
+	running         int
+	runningPerOwner map[string]int
+}
+
+func newJobQueue(maxConcurrent, maxPerPrincipal int) *jobQueue {
+	return &jobQueue{
+		maxConcurrent:   maxConcurrent,
+		maxPerPrincipal: maxPerPrincipal,
+		runningPerOwner: make(map[string]int),
+	}
+}
+
+// tryReserve counts a process of ownerID as running if it can be started
+// right away without jumping the queue, and reports whether it has done so.
+func (q *jobQueue) tryReserve(ownerID string) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if len(q.waiting) > 0 || !q.hasCapacityLocked(ownerID) {
+		return false
+	}
+	q.reserveLocked(ownerID)
+	return true
+}
+
+// push adds a process to the queue behind the processes having the same or
+// a higher priority and returns its 1-based position.
+func (q *jobQueue) push(handle *processHandle) int {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	index := len(q.waiting)
+	for i, waiting := range q.waiting {
+		if waiting.priority < handle.priority {
+			index = i
+			break
+		}
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[index+1:], q.waiting[index:])
+	q.waiting[index] = handle
+	return index + 1
+}
+
+// remove removes a process from the queue. Returns false if the process was
+// not waiting in the queue.
+func (q *jobQueue) remove(handle *processHandle) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	for i, waiting := range q.waiting {
+		if waiting == handle {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the 1-based position of a process in the queue, or zero
+// if it is not waiting in the queue.
+func (q *jobQueue) position(handle *processHandle) int {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	for i, waiting := range q.waiting {
+		if waiting == handle {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// popRunnable removes the processes that can be started within the limits
+// from the queue, in order, and counts them as running.
+func (q *jobQueue) popRunnable() []*processHandle {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	var runnable []*processHandle
+	remaining := q.waiting[:0]
+	for _, handle := range q.waiting {
+		if q.hasCapacityLocked(handle.ownerID) {
+			q.reserveLocked(handle.ownerID)
+			runnable = append(runnable, handle)
+		} else {
+			remaining = append(remaining, handle)
+		}
+	}
+	for i := len(remaining); i < len(q.waiting); i++ {
+		q.waiting[i] = nil
+	}
+	q.waiting = remaining
+	return runnable
+}
+
+// stats returns the number of the running and the waiting processes
+func (q *jobQueue) stats() (running, waiting int) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return q.running, len(q.waiting)
+}
+
+// release stops counting a process of ownerID as running
+func (q *jobQueue) release(ownerID string) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.running--
+	q.runningPerOwner[ownerID]--
+	if q.runningPerOwner[ownerID] == 0 {
+		delete(q.runningPerOwner, ownerID)
+	}
+}
+
+func (q *jobQueue) hasCapacityLocked(ownerID string) bool {
+	if q.maxConcurrent > 0 && q.running >= q.maxConcurrent {
+		return false
+	}
+	if q.maxPerPrincipal > 0 && q.runningPerOwner[ownerID] >= q.maxPerPrincipal {
+		return false
+	}
+	return true
+}
+
+func (q *jobQueue) reserveLocked(ownerID string) {
+	q.running++
+	q.runningPerOwner[ownerID]++
+}
@@ -0,0 +1,193 @@
+package localexec_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func waitForState(t *testing.T, s rex.Service, ctx context.Context,
+	procID uuid.UUID, state rex.ProcessState) rex.ProcessInfo {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, err := s.GetProcessInfo(ctx, procID)
+		if err != nil {
+			t.Fatalf("While calling GetProcessInfo: %v", err)
+		}
+		if info.State == state {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected process to reach state %v, it is %v", state, info.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueue_PriorityAndPosition(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithConcurrencyLimits(1, 0))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	blocker, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	low, err := s.ExecCommand(ctx, rex.Command{Path: "true"})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	high, err := s.ExecCommand(ctx, rex.Command{Path: "true", Priority: 10})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	lowInfo := waitForState(t, s, ctx, low, rex.ProcessQueued)
+	highInfo := waitForState(t, s, ctx, high, rex.ProcessQueued)
+	if highInfo.QueuePosition != 1 || lowInfo.QueuePosition != 2 {
+		t.Errorf("Expected the higher priority process to be first in the queue, got positions %d (high) and %d (low)",
+			highInfo.QueuePosition, lowInfo.QueuePosition)
+	}
+	capacity, err := s.GetCapacity(ctx)
+	if err != nil {
+		t.Fatalf("While calling GetCapacity: %v", err)
+	}
+	if expected := (rex.Capacity{Running: 1, Queued: 2, MaxConcurrent: 1}); capacity != expected {
+		t.Errorf("Expected capacity %+v, got %+v", expected, capacity)
+	}
+
+	if err := s.Kill(ctx, blocker, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	highInfo = waitForState(t, s, ctx, high, rex.ProcessExited)
+	lowInfo = waitForState(t, s, ctx, low, rex.ProcessExited)
+	if lowInfo.Start.Before(highInfo.Start) {
+		t.Errorf("Expected the higher priority process to start first")
+	}
+}
+
+func TestQueue_PerPrincipalLimit(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithConcurrencyLimits(0, 1))
+	ctxA := rex.WithUserID(context.Background(), uuid.New().String())
+	ctxB := rex.WithUserID(context.Background(), uuid.New().String())
+
+	blocker, err := s.Exec(ctxA, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctxA, blocker, int(syscall.SIGKILL))
+
+	queued, err := s.Exec(ctxA, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	other, err := s.Exec(ctxB, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+
+	waitForState(t, s, ctxB, other, rex.ProcessExited)
+	waitForState(t, s, ctxA, queued, rex.ProcessQueued)
+}
+
+func TestQueue_CancelWithKillAndDelete(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithConcurrencyLimits(1, 0))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	blocker, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctx, blocker, int(syscall.SIGKILL))
+
+	killed, err := s.Exec(ctx, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	deleted, err := s.Exec(ctx, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+
+	if err := s.Kill(ctx, killed, int(syscall.SIGINT)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	waitForState(t, s, ctx, killed, rex.ProcessCanceled)
+	if err := s.Kill(ctx, killed, int(syscall.SIGINT)); err != rex.ErrNotRunning {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotRunning, err)
+	}
+
+	if err := s.Delete(ctx, deleted); err != nil {
+		t.Fatalf("While calling Delete: %v", err)
+	}
+	if _, err := s.GetProcessInfo(ctx, deleted); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	if err := s.Delete(ctx, procID); err != rex.ErrProcessRunning {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrProcessRunning, err)
+	}
+
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	if err := s.Delete(otherCtx, procID); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+
+	if err := s.Kill(ctx, procID, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessExited)
+	if err := s.Delete(ctx, procID); err != nil {
+		t.Fatalf("While calling Delete: %v", err)
+	}
+	if _, err := s.Read(ctx, procID, rex.StdoutStream); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+}
+
+func TestQueue_RelativeExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rex-queue")
+	if err != nil {
+		t.Fatalf("While creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("While creating the executable: %v", err)
+	}
+
+	s := localexec.NewServer(os.TempDir(), localexec.WithConcurrencyLimits(1, 0))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	blocker, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctx, blocker, int(syscall.SIGKILL))
+
+	// The executables of the queued processes are looked up in their
+	// working directory
+	procID, err := s.ExecCommand(ctx, rex.Command{Path: "./tool", Dir: dir})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessQueued)
+	if _, err := s.ExecCommand(ctx, rex.Command{Path: "./tool"}); err == nil {
+		t.Errorf("Expected a missing executable to be rejected")
+	}
+}
@@ -0,0 +1,412 @@
+package localexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/farnasirim/rex"
+)
+
+// anyPrincipal is the principal of the quota rule that applies to the
+// principals without a rule of their own.
+const anyPrincipal = "*"
+
+// QuotaRule assigns a quota to a principal. Principal is either a user ID,
+// group:NAME for the members of a group, where NAME can be a glob pattern,
+// or "*".
+//
+// The members of a group share its quota, from the first group rule that
+// matches any of their groups, and each of the groups matching a pattern
+// has a quota of its own. A process counts towards both the quota of its
+// owner, if the owner has a rule of its own, and the quota of the group of
+// its owner. The rule with Principal "*" applies to everyone else.
+type QuotaRule struct {
+	Principal string `validate:"required"`
+	rex.Quota
+}
+
+// QuotaRuleFromJSON creates a quota rule from its json representation, e.g.
+// {"Principal": "*", "MaxRunning": 10, "MaxOutputBytes": 1073741824}
+func QuotaRuleFromJSON(marshalledRule []byte) (*QuotaRule, error) {
+	validate := validator.New()
+
+	var rule QuotaRule
+	if err := json.Unmarshal(marshalledRule, &rule); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(&rule); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(rule.Principal, rex.GroupPrincipalPrefix) {
+		if _, err := path.Match(strings.TrimPrefix(rule.Principal, rex.GroupPrincipalPrefix), ""); err != nil {
+			return nil, fmt.Errorf("bad group pattern %q: %w", rule.Principal, err)
+		}
+	}
+
+	return &rule, nil
+}
+
+// WithQuotas limits the resources that each principal can consume. Later
+// rules for the same principal override the earlier ones. The groups of the
+// principals are taken from the contexts of their calls, see
+// rex.GroupsFromContext.
+func WithQuotas(rules ...QuotaRule) Option {
+	return func(ps *ProcessServer) {
+		for _, rule := range rules {
+			if !strings.HasPrefix(rule.Principal, rex.GroupPrincipalPrefix) {
+				ps.quotas.rules[rule.Principal] = rule.Quota
+				continue
+			}
+			pattern := strings.TrimPrefix(rule.Principal, rex.GroupPrincipalPrefix)
+			if i, ok := ps.quotas.groupRuleIndex(pattern); ok {
+				ps.quotas.groupRules[i].quota = rule.Quota
+			} else {
+				ps.quotas.groupRules = append(ps.quotas.groupRules, groupQuota{pattern, rule.Quota})
+			}
+		}
+	}
+}
+
+// groupQuota is the quota of the members of the groups matching pattern
+type groupQuota struct {
+	pattern string
+	quota   rex.Quota
+}
+
+// cpuRecord is the CPU time of a process that has exited
+type cpuRecord struct {
+	exit    time.Time
+	seconds float64
+}
+
+// quotaAccount names the usages that a process counts towards: those of its
+// owner and, if a group rule applies to the owner, of the group.
+type quotaAccount struct {
+	owner string
+	group string
+}
+
+// keys returns the keys of the usages of the account in quotaTracker.owners
+func (a quotaAccount) keys() []string {
+	if a.group == "" {
+		return []string{a.owner}
+	}
+	return []string{a.owner, rex.GroupPrincipalPrefix + a.group}
+}
+
+// ownerUsage is the resource usage of a single principal or of a group
+type ownerUsage struct {
+	running     int
+	queued      int
+	creations   []time.Time
+	cpu         []cpuRecord
+	outputBytes int64
+}
+
+// quotaTracker keeps track of the resource usage of the principals and
+// enforces the quotas.
+type quotaTracker struct {
+	rules      map[string]rex.Quota
+	groupRules []groupQuota
+
+	m sync.Mutex
+	// owners holds the usage of the principals, and of the groups under
+	// group:NAME
+	owners map[string]*ownerUsage
+	// groups holds the groups that the principals were last seen with, so
+	// that the processes that are created on their behalf, e.g. by
+	// schedules, get the same quota.
+	groups map[string][]string
+}
+
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{
+		rules:  make(map[string]rex.Quota),
+		owners: make(map[string]*ownerUsage),
+		groups: make(map[string][]string),
+	}
+}
+
+func (q *quotaTracker) groupRuleIndex(pattern string) (int, bool) {
+	for i, rule := range q.groupRules {
+		if rule.pattern == pattern {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// setGroups records the groups of ownerID
+func (q *quotaTracker) setGroups(ownerID string, groups []string) {
+	if len(q.groupRules) == 0 {
+		return
+	}
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.groups[ownerID] = append([]string(nil), groups...)
+}
+
+// accountLocked returns the account of the processes of ownerID. Requires
+// the caller to hold q.m
+func (q *quotaTracker) accountLocked(ownerID string) quotaAccount {
+	for _, rule := range q.groupRules {
+		for _, group := range q.groups[ownerID] {
+			if matched, _ := path.Match(rule.pattern, group); matched {
+				return quotaAccount{owner: ownerID, group: group}
+			}
+		}
+	}
+	return quotaAccount{owner: ownerID}
+}
+
+// limitsLocked returns the quotas of the usages of account, in the order of
+// account.keys(). Requires the caller to hold q.m
+func (q *quotaTracker) limitsLocked(account quotaAccount) []rex.Quota {
+	owner, ok := q.rules[account.owner]
+	if !ok && account.group == "" {
+		owner = q.rules[anyPrincipal]
+	}
+	if account.group == "" {
+		return []rex.Quota{owner}
+	}
+	for _, rule := range q.groupRules {
+		if matched, _ := path.Match(rule.pattern, account.group); matched {
+			return []rex.Quota{owner, rule.quota}
+		}
+	}
+	return []rex.Quota{owner, {}}
+}
+
+// limitsOutput tells whether the output of the processes of account is
+// limited
+func (q *quotaTracker) limitsOutput(account quotaAccount) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+	for _, limits := range q.limitsLocked(account) {
+		if limits.MaxOutputBytes > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// admit counts a new process of ownerID if it exceeds neither the quota of
+// ownerID nor that of its group, and returns an error wrapping
+// rex.ErrResourceExhausted otherwise. Every admitted process must eventually
+// be reported to finish with the returned account.
+func (q *quotaTracker) admit(ownerID string) (quotaAccount, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	account := q.accountLocked(ownerID)
+	limits := q.limitsLocked(account)
+	now := time.Now()
+	var usages []*ownerUsage
+	for i, key := range account.keys() {
+		usage := q.usageLocked(key, now)
+		if err := exceeded(limits[i], usage); err != nil {
+			if account.group != "" && i == 1 {
+				err = fmt.Errorf("group %s: %w", account.group, err)
+			}
+			return quotaAccount{}, err
+		}
+		usages = append(usages, usage)
+	}
+
+	for _, usage := range usages {
+		usage.running++
+		usage.creations = append(usage.creations, now)
+	}
+	return account, nil
+}
+
+// exceeded returns an error wrapping rex.ErrResourceExhausted if usage has
+// reached any of limits
+func exceeded(limits rex.Quota, usage *ownerUsage) error {
+	if limits.MaxRunning > 0 && usage.running+usage.queued >= limits.MaxRunning {
+		return fmt.Errorf("%w: quota of %d running processes reached",
+			rex.ErrResourceExhausted, limits.MaxRunning)
+	}
+	if limits.MaxPerHour > 0 && len(usage.creations) >= limits.MaxPerHour {
+		return fmt.Errorf("%w: quota of %d processes per hour reached",
+			rex.ErrResourceExhausted, limits.MaxPerHour)
+	}
+	if limits.MaxOutputBytes > 0 && usage.outputBytes >= limits.MaxOutputBytes {
+		return fmt.Errorf("%w: quota of %d bytes of stored output reached",
+			rex.ErrResourceExhausted, limits.MaxOutputBytes)
+	}
+	if limits.MaxCPUSecondsPerDay > 0 && cpuSeconds(usage.cpu) >= limits.MaxCPUSecondsPerDay {
+		return fmt.Errorf("%w: quota of %g CPU seconds per day reached",
+			rex.ErrResourceExhausted, limits.MaxCPUSecondsPerDay)
+	}
+	return nil
+}
+
+// finish stops counting a process of account as running and records the
+// CPU time that it has consumed.
+func (q *quotaTracker) finish(account quotaAccount, cpu time.Duration) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	now := time.Now()
+	for _, key := range account.keys() {
+		usage := q.usageLocked(key, now)
+		usage.running--
+		if cpu > 0 {
+			usage.cpu = append(usage.cpu, cpuRecord{exit: now, seconds: cpu.Seconds()})
+		}
+	}
+}
+
+// enqueue counts an admitted process of account as queued rather than
+// running
+func (q *quotaTracker) enqueue(account quotaAccount) {
+	q.shift(account, -1, 1)
+}
+
+// start counts a queued process of account as running
+func (q *quotaTracker) start(account quotaAccount) {
+	q.shift(account, 1, -1)
+}
+
+// cancel stops counting a queued process of account
+func (q *quotaTracker) cancel(account quotaAccount) {
+	q.shift(account, 0, -1)
+}
+
+// shift adds running and queued to the processes of account
+func (q *quotaTracker) shift(account quotaAccount, running, queued int) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	now := time.Now()
+	for _, key := range account.keys() {
+		usage := q.usageLocked(key, now)
+		usage.running += running
+		usage.queued += queued
+	}
+}
+
+// addOutput accounts for n bytes of output of a process of account, and
+// returns how many of them fit in the quotas.
+func (q *quotaTracker) addOutput(account quotaAccount, n int) int {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	limits := q.limitsLocked(account)
+	now := time.Now()
+	keys := account.keys()
+	for i, key := range keys {
+		usage := q.usageLocked(key, now)
+		if limit := limits[i].MaxOutputBytes; limit > 0 && usage.outputBytes+int64(n) > limit {
+			n = int(limit - usage.outputBytes)
+			if n < 0 {
+				n = 0
+			}
+		}
+	}
+	for _, key := range keys {
+		q.usageLocked(key, now).outputBytes += int64(n)
+	}
+	return n
+}
+
+// adjustOutput adds delta to the output bytes of account regardless of the
+// quotas
+func (q *quotaTracker) adjustOutput(account quotaAccount, delta int64) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	now := time.Now()
+	for _, key := range account.keys() {
+		q.usageLocked(key, now).outputBytes += delta
+	}
+}
+
+// usage returns the quota and the current usage of ownerID, or those of its
+// group if ownerID only has the quota of its group
+func (q *quotaTracker) usage(ownerID string) (rex.Quota, rex.ResourceUsage) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	account := q.accountLocked(ownerID)
+	limits := q.limitsLocked(account)
+	keys := account.keys()
+	i := 0
+	if _, ok := q.rules[ownerID]; !ok && account.group != "" {
+		i = 1
+	}
+	usage := q.usageLocked(keys[i], time.Now())
+	return limits[i], rex.ResourceUsage{
+		Running:           usage.running,
+		Queued:            usage.queued,
+		CreatedLastHour:   len(usage.creations),
+		OutputBytes:       usage.outputBytes,
+		CPUSecondsLastDay: cpuSeconds(usage.cpu),
+	}
+}
+
+// usageLocked returns the usage under key after dropping the records that
+// have fallen out of their windows. Requires the caller to hold q.m
+func (q *quotaTracker) usageLocked(key string, now time.Time) *ownerUsage {
+	usage, ok := q.owners[key]
+	if !ok {
+		usage = &ownerUsage{}
+		q.owners[key] = usage
+	}
+
+	hourAgo := now.Add(-time.Hour)
+	for len(usage.creations) > 0 && !usage.creations[0].After(hourAgo) {
+		usage.creations = usage.creations[1:]
+	}
+	dayAgo := now.Add(-24 * time.Hour)
+	for len(usage.cpu) > 0 && !usage.cpu[0].exit.After(dayAgo) {
+		usage.cpu = usage.cpu[1:]
+	}
+	return usage
+}
+
+func cpuSeconds(records []cpuRecord) float64 {
+	var total float64
+	for _, record := range records {
+		total += record.seconds
+	}
+	return total
+}
+
+// quotaWriter writes the output of a process to a file for as long as the
+// output quotas of its owner and of its group allow, and discards the rest.
+type quotaWriter struct {
+	file   *os.File
+	handle *processHandle
+	ps     *ProcessServer
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	allowed := w.ps.quotas.addOutput(w.handle.quota, len(p))
+	n, err := w.file.Write(p[:allowed])
+	if n < allowed {
+		// Give back what could not be written
+		w.ps.quotas.adjustOutput(w.handle.quota, int64(n-allowed))
+	}
+	if err != nil {
+		return n, err
+	}
+	if allowed < len(p) {
+		w.ps.truncateOutput(w.handle)
+	}
+	// Pretend that everything has been written so that the process is not
+	// disturbed by the truncation.
+	return len(p), nil
+}
+
+func (w *quotaWriter) Close() error {
+	return w.file.Close()
+}
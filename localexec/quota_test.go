@@ -0,0 +1,227 @@
+package localexec_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestQuota_MaxRunning(t *testing.T) {
+	limited := uuid.New().String()
+	s := localexec.NewServer(os.TempDir(), localexec.WithQuotas(
+		localexec.QuotaRule{Principal: "*", Quota: rex.Quota{MaxRunning: 1}},
+		localexec.QuotaRule{Principal: limited, Quota: rex.Quota{MaxRunning: 2}},
+	))
+
+	ctx := rex.WithUserID(context.Background(), limited)
+	for i := 0; i < 2; i++ {
+		procID, err := s.Exec(ctx, "sleep", "10")
+		if err != nil {
+			t.Fatalf("While calling Exec: %v", err)
+		}
+		defer s.Kill(ctx, procID, int(syscall.SIGKILL))
+	}
+	_, err := s.Exec(ctx, "sleep", "10")
+	if !errors.Is(err, rex.ErrResourceExhausted) {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrResourceExhausted, err)
+	}
+
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	procID, err := s.Exec(otherCtx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	if err := s.Kill(otherCtx, procID, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	waitForState(t, s, otherCtx, procID, rex.ProcessExited)
+	if _, err := s.Exec(otherCtx, "true"); err != nil {
+		t.Errorf("Expected the exited process to free its slot, got: %v", err)
+	}
+}
+
+func TestQuota_Queued(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithConcurrencyLimits(1, 0), localexec.WithQuotas(
+		localexec.QuotaRule{Principal: "*", Quota: rex.Quota{MaxRunning: 2}},
+	))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	blocker, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	queued, err := s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	waitForState(t, s, ctx, queued, rex.ProcessQueued)
+	expectUsage := func(running, queued int) {
+		t.Helper()
+		_, usage, err := s.GetQuota(ctx)
+		if err != nil {
+			t.Fatalf("While calling GetQuota: %v", err)
+		}
+		if usage.Running != running || usage.Queued != queued {
+			t.Errorf("Expected %d running and %d queued processes, got %+v", running, queued, usage)
+		}
+	}
+	expectUsage(1, 1)
+	if _, err := s.Exec(ctx, "true"); !errors.Is(err, rex.ErrResourceExhausted) {
+		t.Errorf("Expected the queued process to count towards MaxRunning, got: %v", err)
+	}
+
+	if err := s.Kill(ctx, queued, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	waitForState(t, s, ctx, queued, rex.ProcessCanceled)
+	expectUsage(1, 0)
+
+	queued, err = s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctx, queued, int(syscall.SIGKILL))
+	if err := s.Kill(ctx, blocker, int(syscall.SIGKILL)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	waitForState(t, s, ctx, queued, rex.ProcessRunning)
+	expectUsage(1, 0)
+}
+
+func TestQuota_MaxPerHour(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithQuotas(
+		localexec.QuotaRule{Principal: "*", Quota: rex.Quota{MaxPerHour: 2}},
+	))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Exec(ctx, "true"); err != nil {
+			t.Fatalf("While calling Exec: %v", err)
+		}
+	}
+	if _, err := s.Exec(ctx, "true"); !errors.Is(err, rex.ErrResourceExhausted) {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrResourceExhausted, err)
+	}
+
+	_, usage, err := s.GetQuota(ctx)
+	if err != nil {
+		t.Fatalf("While calling GetQuota: %v", err)
+	}
+	if usage.CreatedLastHour != 2 {
+		t.Errorf("Expected 2 processes in the last hour, got %d", usage.CreatedLastHour)
+	}
+}
+
+func TestQuota_MaxOutputBytes(t *testing.T) {
+	s := localexec.NewServer(os.TempDir(), localexec.WithQuotas(
+		localexec.QuotaRule{Principal: "*", Quota: rex.Quota{MaxOutputBytes: 4}},
+	))
+	ctx, cancel := context.WithCancel(rex.WithUserID(context.Background(), uuid.New().String()))
+	defer cancel()
+
+	events, err := s.Watch(ctx, rex.EventFilter{})
+	if err != nil {
+		t.Fatalf("While calling Watch: %v", err)
+	}
+	procID, err := s.Exec(ctx, "echo", "hello")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessExited)
+
+	content, err := s.Read(ctx, procID, rex.StdoutStream)
+	if err != nil {
+		t.Fatalf("While calling Read: %v", err)
+	}
+	if string(content) != "hell" {
+		t.Errorf("Expected the output to be truncated to %q, got %q", "hell", content)
+	}
+	truncated := false
+	for _, event := range receiveEvents(t, events, 4) {
+		truncated = truncated || event.Type == rex.EventOutputTruncated
+	}
+	if !truncated {
+		t.Errorf("Expected an %v event", rex.EventOutputTruncated)
+	}
+
+	if _, err := s.Exec(ctx, "true"); !errors.Is(err, rex.ErrResourceExhausted) {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrResourceExhausted, err)
+	}
+	if err := s.Delete(ctx, procID); err != nil {
+		t.Fatalf("While calling Delete: %v", err)
+	}
+	_, usage, err := s.GetQuota(ctx)
+	if err != nil {
+		t.Fatalf("While calling GetQuota: %v", err)
+	}
+	if usage.OutputBytes != 0 {
+		t.Errorf("Expected deleting the process to free its output, %d bytes in use", usage.OutputBytes)
+	}
+}
+
+func TestQuota_Groups(t *testing.T) {
+	owner := uuid.New().String()
+	s := localexec.NewServer(os.TempDir(), localexec.WithQuotas(
+		localexec.QuotaRule{Principal: "*", Quota: rex.Quota{MaxPerHour: 1}},
+		localexec.QuotaRule{Principal: "group:team-*", Quota: rex.Quota{MaxPerHour: 2}},
+		localexec.QuotaRule{Principal: owner, Quota: rex.Quota{MaxPerHour: 3}},
+	))
+	member := func(userID string, groups ...string) context.Context {
+		return rex.WithGroups(rex.WithUserID(context.Background(), userID), groups)
+	}
+	exec := func(ctx context.Context, succeeds bool) {
+		t.Helper()
+		_, err := s.Exec(ctx, "true")
+		if succeeds && err != nil {
+			t.Fatalf("While calling Exec: %v", err)
+		}
+		if !succeeds && !errors.Is(err, rex.ErrResourceExhausted) {
+			t.Errorf("Expected error %v, actual: %v", rex.ErrResourceExhausted, err)
+		}
+	}
+
+	// The members of team-a share its quota
+	alice, bob := member(uuid.New().String(), "ops", "team-a"), member(uuid.New().String(), "team-a")
+	exec(alice, true)
+	exec(bob, true)
+	exec(alice, false)
+	exec(bob, false)
+	quota, usage, err := s.GetQuota(bob)
+	if err != nil {
+		t.Fatalf("While calling GetQuota: %v", err)
+	}
+	if quota.MaxPerHour != 2 || usage.CreatedLastHour != 2 {
+		t.Errorf("Expected the shared quota and usage of team-a, got %+v, %+v", quota, usage)
+	}
+
+	// Each group matching the pattern has a quota of its own
+	carol := member(uuid.New().String(), "team-b")
+	exec(carol, true)
+	exec(carol, true)
+	exec(carol, false)
+
+	// Everyone else
+	dave := member(uuid.New().String(), "ops")
+	exec(dave, true)
+	exec(dave, false)
+
+	// Both the quota of the owner and that of its group apply
+	ownerCtx := member(owner, "team-c")
+	exec(ownerCtx, true)
+	exec(ownerCtx, true)
+	exec(ownerCtx, false)
+	if quota, usage, _ := s.GetQuota(ownerCtx); quota.MaxPerHour != 3 || usage.CreatedLastHour != 2 {
+		t.Errorf("Expected the quota and usage of the owner, got %+v, %+v", quota, usage)
+	}
+
+	if _, err := localexec.QuotaRuleFromJSON([]byte(`{"Principal": "group:[", "MaxRunning": 1}`)); err == nil {
+		t.Errorf("Expected a bad group pattern to be rejected")
+	}
+}
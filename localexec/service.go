@@ -2,6 +2,8 @@ package localexec
 
 import (
 	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -21,47 +23,220 @@ import (
 // ProcessServer implements rex.Service in the Linux environment
 type ProcessServer struct {
 	processes sync.Map
+	services  sync.Map
+	groups    sync.Map
 	dataDir   string
+	events    *eventLog
+	queue     *jobQueue
+	quotas    *quotaTracker
+	validate  func(context.Context, rex.Command) error
+	// enforce authorizes the calls acting on processes
+	enforce func(context.Context) (bool, bool)
+	// maxFileSize limits the size of the uploaded files
+	maxFileSize int64
+}
+
+// Option configures optional behavior of a ProcessServer
+type Option func(*ProcessServer)
+
+// WithCommandValidator makes the ProcessServer call validate on every command
+// before executing it, and reject the command if validate returns an error.
+func WithCommandValidator(validate func(context.Context, rex.Command) error) Option {
+	return func(ps *ProcessServer) {
+		ps.validate = validate
+	}
+}
+
+// WithConcurrencyLimits limits the number of processes that are running at
+// the same time, both server-wide and per principal. The processes exceeding
+// the limits are queued. Zero or less means no limit.
+func WithConcurrencyLimits(maxConcurrent, maxPerPrincipal int) Option {
+	return func(ps *ProcessServer) {
+		ps.queue = newJobQueue(maxConcurrent, maxPerPrincipal)
+	}
 }
 
 // Exec creates a process from the supplied path and args
 func (ps *ProcessServer) Exec(ctx context.Context,
 	path string, args ...string) (uuid.UUID, error) {
-	cmd := exec.Command(path, args...)
+	return ps.ExecCommand(ctx, rex.Command{Path: path, Args: args})
+}
 
+// ExecCommand creates a process as described by command. The process is
+// queued if starting it right away would exceed the concurrency limits of
+// the server. If command has a restart policy, a supervised service is
+// created instead, whose ID is returned.
+func (ps *ProcessServer) ExecCommand(ctx context.Context,
+	command rex.Command) (uuid.UUID, error) {
 	ownerID, ok := rex.UserIDFromContext(ctx)
 	if !ok {
 		return uuid.Nil, rex.ErrUnauthenticated
 	}
+	if groups, ok := rex.GroupsFromContext(ctx); ok {
+		ps.quotas.setGroups(ownerID, groups)
+	}
 
-	processID := uuid.New().String()
-	stdout, stderr, err := ps.createOutputFiles(processID)
+	if ps.validate != nil {
+		if err := ps.validate(ctx, command); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	probes, err := probesWithDefaults(command)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	command.Probes = probes
+	if err := validateArtifacts(command); err != nil {
+		return uuid.Nil, err
+	}
+	if err := validateEnvironment(command); err != nil {
+		return uuid.Nil, err
+	}
+
+	if command.Restart != nil && command.GroupID != uuid.Nil {
+		return uuid.Nil, errors.New("supervised services cannot be added to groups")
+	}
+	if mustBeGroup, ok := ps.groups.Load(command.GroupID.String()); ok {
+		if err := ps.authorizeGroup(ctx, mustBeGroup.(*group)); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	if command.Restart != nil {
+		return ps.startService(ownerID, command)
+	}
+	handle, err := ps.execCommand(ownerID, command, nil, 0)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return uuid.MustParse(handle.id), nil
+}
+
+// execCommand creates a process of ownerID, which is the given incarnation
+// of service if service is not nil.
+func (ps *ProcessServer) execCommand(ownerID string, command rex.Command,
+	service *supervisor, incarnation int) (*processHandle, error) {
+	stages := newStages(command)
+
+	account, err := ps.quotas.admit(ownerID)
+	if err != nil {
+		return nil, err
+	}
 
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
+	processID := uuid.New().String()
+	stdout, stderr, err := ps.createOutputFiles(processID)
+	if err != nil {
+		ps.quotas.finish(account, 0)
+		ps.removeProcessFiles(processID)
+		return nil, err
+	}
+	if err := ps.prepareWorkspaces(stages, processID, ownerID, command); err != nil {
+		ps.quotas.finish(account, 0)
+		stdout.Close()
+		stderr.Close()
+		ps.removeProcessFiles(processID)
+		return nil, err
+	}
 
 	// TODO: would be better to get the exact start time from /proc/$pid/stat
 	// I still don't see an easy way to find the exact exit time however.
 	create := time.Now().UTC()
-	if err := cmd.Start(); err != nil {
-		log.Infof("failed starting a process: %v", err)
-		return uuid.Nil, err
+	handle := newProcessHandle(processID, ownerID, stages, create, command)
+	handle.service = service
+	handle.incarnation = incarnation
+	handle.quota = account
+
+	if ps.quotas.limitsOutput(account) {
+		// The output has to go through a pipe to be cut at the quota.
+		setOutput(stages,
+			&quotaWriter{file: stdout, handle: handle, ps: ps},
+			&quotaWriter{file: stderr, handle: handle, ps: ps})
+	} else {
+		setOutput(stages, stdout, stderr)
+	}
+
+	if handle.group != uuid.Nil {
+		if err := ps.joinGroup(handle); err != nil {
+			ps.quotas.finish(account, 0)
+			closeOutputFiles(handle.lastStage())
+			ps.removeProcessFiles(processID)
+			return nil, err
+		}
+	}
+
+	if ps.queue.tryReserve(ownerID) {
+		if err := startStages(stages); err != nil {
+			ps.queue.release(ownerID)
+			ps.quotas.finish(account, 0)
+			closeOutputFiles(handle.lastStage())
+			ps.leaveGroup(handle)
+			ps.removeProcessFiles(processID)
+			log.Infof("failed starting a process: %v", err)
+			return nil, err
+		}
+		ps.registerProcess(handle)
+		return handle, nil
+	}
+
+	// Report the errors that would otherwise surface only after leaving the
+	// queue.
+	for _, stage := range stages {
+		if err := lookPath(stage); err != nil {
+			ps.quotas.finish(account, 0)
+			closeOutputFiles(handle.lastStage())
+			ps.leaveGroup(handle)
+			ps.removeProcessFiles(processID)
+			return nil, err
+		}
 	}
-	ps.registerProcess(processID, ownerID, cmd, create)
+	ps.quotas.enqueue(account)
+	ps.enqueueProcess(handle)
+	ps.dispatch()
+
+	return handle, nil
+}
 
-	return uuid.MustParse(processID), nil
+// Watch streams the lifecycle events of the processes matching filter that
+// the caller may act on, or of all of them if ctx is made by
+// rex.WithAllEvents.
+func (ps *ProcessServer) Watch(ctx context.Context, filter rex.EventFilter) (<-chan rex.Event, error) {
+	if _, ok := rex.UserIDFromContext(ctx); !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+	events := ps.events.watch(ctx, filter)
+	if rex.AllEventsFromContext(ctx) {
+		return events, nil
+	}
+	out := make(chan rex.Event)
+	go func() {
+		defer close(out)
+		for event := range events {
+			if ps.authorize(ctx, eventResource(event.Process)) != nil {
+				continue
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
 }
 
 // ListProcessInfo returs a list of all processes that have ever been
-// successfully Exec'd into the system, sorted by their creation time (newest
-// first).
+// successfully Exec'd into the system and that the caller may act on, sorted
+// by their creation time (newest first).
 func (ps *ProcessServer) ListProcessInfo(ctx context.Context) ([]rex.ProcessInfo, error) {
+	if _, ok := rex.UserIDFromContext(ctx); !ok {
+		return nil, rex.ErrUnauthenticated
+	}
 	var infoList []rex.ProcessInfo
 	ps.processes.Range(func(key, value interface{}) bool {
-		infoList = append(infoList, value.(*processHandle).getProcessInfo())
+		handle := value.(*processHandle)
+		if ps.authorize(ctx, handle.resource()) == nil {
+			infoList = append(infoList, ps.getProcessInfo(handle))
+		}
 		return true
 	})
 	sort.Slice(infoList, func(i, j int) bool {
@@ -72,67 +247,101 @@ func (ps *ProcessServer) ListProcessInfo(ctx context.Context) ([]rex.ProcessInfo
 
 // GetProcessInfo returns the process info corresponding to the givne processID
 func (ps *ProcessServer) GetProcessInfo(ctx context.Context, processID uuid.UUID) (rex.ProcessInfo, error) {
-	mustBeProcessHandle, ok := ps.processes.Load(processID.String())
+	handle, ok := ps.loadHandle(processID)
 	// Must be a little careful here. We are leaking information about the
 	// process UUID's in the system. Might make more sense to make the not found
 	// case indistinguishable from the unauthorized/unauthenticated case.
-	//
-	// A more thorough and natural (not hardcoded) RBAC implementation will
-	// take care of this in a natural way, since for example in this case, it
-	// *cannot find* a process with the given ID, satisfying the condition of
-	// proc.OwnerID == currentUser
 	if !ok {
 		return rex.ProcessInfo{}, rex.ErrNotFound
 	}
-	userID, ok := rex.UserIDFromContext(ctx)
-	if !ok {
-		return rex.ProcessInfo{}, rex.ErrUnauthenticated
-	}
-	if mustBeProcessHandle.(*processHandle).ownerID != userID {
-		return rex.ProcessInfo{}, rex.ErrAccessDenied
+	if err := ps.authorize(ctx, handle.resource()); err != nil {
+		return rex.ProcessInfo{}, err
 	}
-	return mustBeProcessHandle.(*processHandle).getProcessInfo(), nil
+	return ps.getProcessInfo(handle), nil
 }
 
-// Kill sends a signal to the given process
+// Kill sends a signal to the given process. Queued processes are canceled
+// regardless of the signal. Services are stopped, and the signal is sent to
+// their current incarnation.
 func (ps *ProcessServer) Kill(ctx context.Context, processID uuid.UUID, signal int) error {
+	if mustBeService, ok := ps.services.Load(processID.String()); ok {
+		service := mustBeService.(*supervisor)
+		if err := ps.authorize(ctx, service.resource()); err != nil {
+			return err
+		}
+		return service.stop(signal)
+	}
+
 	mustBeProcessHandle, ok := ps.processes.Load(processID.String())
 	if !ok {
 		return rex.ErrNotFound
 	}
-	userID, ok := rex.UserIDFromContext(ctx)
-	if !ok {
-		return rex.ErrUnauthenticated
-	}
 	handle := mustBeProcessHandle.(*processHandle)
-	if handle.ownerID != userID {
-		return rex.ErrAccessDenied
+	if err := ps.authorize(ctx, handle.resource()); err != nil {
+		return err
 	}
+	return ps.signal(handle, signal)
+}
 
+// signal sends a signal to a process, or cancels it if it is queued
+func (ps *ProcessServer) signal(handle *processHandle, signal int) error {
 	handle.m.Lock()
 	defer handle.m.Unlock()
-	return handle.cmd.Process.Signal(syscall.Signal(signal))
+	if handle.state == rex.ProcessQueued {
+		ps.cancelLocked(handle)
+		return nil
+	}
+	if handle.cmd.Process == nil {
+		return rex.ErrNotRunning
+	}
+	if err := signalStages(handle.stages, syscall.Signal(signal)); err != nil {
+		return err
+	}
+	// Publishing while holding the lock guarantees that the exit event of the
+	// process will not be published before this one.
+	ps.events.publish(rex.EventSignaled, handle.processInfoLocked(), signal)
+	return nil
+}
+
+// GetQuota returns the quota of the calling principal and its current usage
+func (ps *ProcessServer) GetQuota(ctx context.Context) (rex.Quota, rex.ResourceUsage, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return rex.Quota{}, rex.ResourceUsage{}, rex.ErrUnauthenticated
+	}
+	if groups, ok := rex.GroupsFromContext(ctx); ok {
+		ps.quotas.setGroups(userID, groups)
+	}
+	quota, usage := ps.quotas.usage(userID)
+	return quota, usage, nil
+}
+
+// GetCapacity returns the number of the running and the queued processes of
+// the server along with its concurrency limit
+func (ps *ProcessServer) GetCapacity(ctx context.Context) (rex.Capacity, error) {
+	running, queued := ps.queue.stats()
+	capacity := rex.Capacity{Running: running, Queued: queued}
+	if ps.queue.maxConcurrent > 0 {
+		capacity.MaxConcurrent = ps.queue.maxConcurrent
+	}
+	return capacity, nil
 }
 
 // Read reads either the stdout or the stderr of the given process
 func (ps *ProcessServer) Read(ctx context.Context, processID uuid.UUID, target rex.OutputStream) ([]byte, error) {
-	mustBeProcessHandle, ok := ps.processes.Load(processID.String())
+	handle, ok := ps.loadHandle(processID)
 	if !ok {
 		return nil, rex.ErrNotFound
 	}
-	userID, ok := rex.UserIDFromContext(ctx)
-	if !ok {
-		return nil, rex.ErrUnauthenticated
-	}
-	if mustBeProcessHandle.(*processHandle).ownerID != userID {
-		return nil, rex.ErrAccessDenied
+	if err := ps.authorize(ctx, handle.resource()); err != nil {
+		return nil, err
 	}
 
 	var targetFile string
 	if target == rex.StderrStream {
-		targetFile = ps.getStderrFilename(processID.String())
+		targetFile = ps.getStderrFilename(handle.id)
 	} else if target == rex.StdoutStream {
-		targetFile = ps.getStdoutFilename(processID.String())
+		targetFile = ps.getStdoutFilename(handle.id)
 	}
 
 	if targetFile == "" {
@@ -142,37 +351,277 @@ func (ps *ProcessServer) Read(ctx context.Context, processID uuid.UUID, target r
 	return ioutil.ReadFile(targetFile)
 }
 
-func (ps *ProcessServer) registerProcess(processID, ownerID string,
-	cmd *exec.Cmd, create time.Time) {
+// Delete removes a process that is not running, along with its output.
+// Services are removed along with all of their incarnations once they are
+// stopped or finished.
+func (ps *ProcessServer) Delete(ctx context.Context, processID uuid.UUID) error {
+	if mustBeService, ok := ps.services.Load(processID.String()); ok {
+		service := mustBeService.(*supervisor)
+		if err := ps.authorize(ctx, service.resource()); err != nil {
+			return err
+		}
+		return service.delete()
+	}
 
-	handle := &processHandle{
-		id:      processID,
-		ownerID: ownerID,
-		cmd:     cmd,
-		running: true,
-		create:  create,
-		pid:     cmd.Process.Pid,
-	}
-	ps.processes.Store(processID, handle)
-	go func() {
-		err := handle.cmd.Wait()
+	mustBeProcessHandle, ok := ps.processes.Load(processID.String())
+	if !ok {
+		return rex.ErrNotFound
+	}
+	handle := mustBeProcessHandle.(*processHandle)
+	if err := ps.authorize(ctx, handle.resource()); err != nil {
+		return err
+	}
+	return ps.delete(handle)
+}
+
+// delete removes a process that is not running, canceling it if it is queued
+func (ps *ProcessServer) delete(handle *processHandle) error {
+	handle.m.Lock()
+	defer handle.m.Unlock()
+	if handle.deleted {
+		return rex.ErrNotFound
+	}
+	if handle.state == rex.ProcessRunning {
+		return rex.ErrProcessRunning
+	}
+	if handle.state == rex.ProcessQueued {
+		ps.cancelLocked(handle)
+	}
+
+	handle.deleted = true
+	ps.quotas.adjustOutput(handle.quota, -handle.outputBytes)
+	ps.processes.Delete(handle.id)
+	ps.leaveGroup(handle)
+	ps.removeProcessFiles(handle.id)
+	ps.events.publish(rex.EventDeleted, handle.processInfoLocked(), 0)
+	return nil
+}
+
+// registerProcess makes a process that is already started visible
+func (ps *ProcessServer) registerProcess(handle *processHandle) {
+	// Holding the lock prevents the events of a concurrent Kill from being
+	// published before the created and started events.
+	handle.m.Lock()
+	defer handle.m.Unlock()
+
+	handle.state = rex.ProcessRunning
+	handle.start = handle.create
+	handle.pid = handle.cmd.Process.Pid
+
+	ps.processes.Store(handle.id, handle)
+	info := handle.processInfoLocked()
+	ps.events.publish(rex.EventCreated, info, 0)
+	ps.events.publish(rex.EventStarted, info, 0)
+	ps.startProbesLocked(handle)
+	go ps.wait(handle)
+}
+
+// enqueueProcess makes a process visible and puts it in the queue
+func (ps *ProcessServer) enqueueProcess(handle *processHandle) {
+	// Holding the lock prevents the process from being started, and hence
+	// the started event from being published, before the created event.
+	handle.m.Lock()
+	defer handle.m.Unlock()
+
+	ps.processes.Store(handle.id, handle)
+	info := handle.processInfoLocked()
+	info.QueuePosition = ps.queue.push(handle)
+	ps.events.publish(rex.EventCreated, info, 0)
+}
+
+// dispatch starts the queued processes for as long as the limits allow
+func (ps *ProcessServer) dispatch() {
+	for {
+		runnable := ps.queue.popRunnable()
+		if len(runnable) == 0 {
+			return
+		}
+		for _, handle := range runnable {
+			ps.startQueued(handle)
+		}
+	}
+}
+
+// startQueued starts a process that has been popped from the queue. The slot
+// that was reserved for the process is released if it does not start.
+func (ps *ProcessServer) startQueued(handle *processHandle) {
+	handle.m.Lock()
+	defer handle.m.Unlock()
 
-		handle.m.Lock()
-		defer handle.m.Unlock()
+	if handle.state != rex.ProcessQueued {
+		// Canceled while being popped from the queue
+		ps.queue.release(handle.ownerID)
+		return
+	}
 
-		handle.running = false
+	ps.quotas.start(handle.quota)
+	if err := startStages(handle.stages); err != nil {
+		log.Infof("failed starting a queued process: %v", err)
+		ps.queue.release(handle.ownerID)
+		ps.quotas.finish(handle.quota, 0)
+		closeOutputFiles(handle.lastStage())
+		if handle.privateWorkDir {
+			ps.removeWorkDir(handle)
+		}
+		handle.state = rex.ProcessFailed
 		handle.exit = time.Now().UTC()
-		handle.exitcode = handle.cmd.ProcessState.ExitCode()
+		handle.exitcode = -1
 		handle.waitError = err
-	}()
+		ps.exitedLocked(handle)
+		return
+	}
+
+	handle.state = rex.ProcessRunning
+	handle.start = time.Now().UTC()
+	handle.pid = handle.cmd.Process.Pid
+	ps.events.publish(rex.EventStarted, handle.processInfoLocked(), 0)
+	ps.startProbesLocked(handle)
+	go ps.wait(handle)
+}
+
+// cancelLocked cancels a queued process. Requires the caller to hold
+// handle.m
+func (ps *ProcessServer) cancelLocked(handle *processHandle) {
+	// The process might have already been popped from the queue, in which
+	// case startQueued will notice the state change.
+	ps.queue.remove(handle)
+	ps.quotas.cancel(handle.quota)
+	closeOutputFiles(handle.lastStage())
+	if handle.privateWorkDir {
+		ps.removeWorkDir(handle)
+	}
+	handle.state = rex.ProcessCanceled
+	handle.exit = time.Now().UTC()
+	handle.exitcode = -1
+	ps.exitedLocked(handle)
+}
+
+func (ps *ProcessServer) wait(handle *processHandle) {
+	err := ps.waitStages(handle)
+	closeOutputFiles(handle.lastStage())
+	outputBytes := ps.outputSize(handle.id)
+	if _, ok := handle.lastStage().Stdout.(*quotaWriter); !ok {
+		// Otherwise already accounted for while being written
+		ps.quotas.adjustOutput(handle.quota, outputBytes)
+	}
+	var artifacts []string
+	if handle.privateWorkDir {
+		artifacts = ps.collectArtifacts(handle)
+	}
+
+	handle.m.Lock()
+	if handle.stopProbes != nil {
+		handle.stopProbes()
+	}
+	handle.state = rex.ProcessExited
+	handle.exit = time.Now().UTC()
+	handle.exitcode = handle.lastStage().ProcessState.ExitCode()
+	handle.waitError = err
+	handle.outputBytes = outputBytes
+	handle.artifacts = artifacts
+	ps.exitedLocked(handle)
+	handle.m.Unlock()
+
+	var cpu time.Duration
+	for _, stage := range handle.stages {
+		cpu += stage.ProcessState.UserTime() + stage.ProcessState.SystemTime()
+	}
+	ps.quotas.finish(handle.quota, cpu)
+	ps.queue.release(handle.ownerID)
+	ps.dispatch()
+}
+
+// exitedLocked reports that a process has reached its final state. Requires
+// the caller to hold handle.m
+func (ps *ProcessServer) exitedLocked(handle *processHandle) {
+	info := handle.processInfoLocked()
+	ps.events.publish(rex.EventExited, info, 0)
+	if handle.service != nil {
+		go handle.service.exited(handle, info)
+	}
+}
+
+func (ps *ProcessServer) getProcessInfo(handle *processHandle) rex.ProcessInfo {
+	info := handle.getProcessInfo()
+	if info.State == rex.ProcessQueued {
+		info.QueuePosition = ps.queue.position(handle)
+	}
+	return info
+}
+
+// truncateOutput reports that some of the output of a process has been
+// discarded, once per process.
+func (ps *ProcessServer) truncateOutput(handle *processHandle) {
+	handle.m.Lock()
+	defer handle.m.Unlock()
+
+	if handle.truncated {
+		return
+	}
+	handle.truncated = true
+	ps.events.publish(rex.EventOutputTruncated, handle.processInfoLocked(), 0)
+}
+
+// outputSize returns the total size of the stored output of a process
+func (ps *ProcessServer) outputSize(processID string) int64 {
+	var size int64
+	for _, filename := range []string{
+		ps.getStdoutFilename(processID),
+		ps.getStderrFilename(processID),
+	} {
+		stat, err := os.Stat(filename)
+		if err != nil {
+			log.Errorf("Failed to stat output file: %v", err)
+			continue
+		}
+		size += stat.Size()
+	}
+	return size
+}
+
+// loadHandle returns the process with the given ID, or the current
+// incarnation of the service with the given ID.
+func (ps *ProcessServer) loadHandle(id uuid.UUID) (*processHandle, bool) {
+	if mustBeProcessHandle, ok := ps.processes.Load(id.String()); ok {
+		return mustBeProcessHandle.(*processHandle), true
+	}
+	if mustBeService, ok := ps.services.Load(id.String()); ok {
+		return mustBeService.(*supervisor).current(), true
+	}
+	return nil, false
+}
+
+func closeOutputFiles(cmd *exec.Cmd) {
+	for _, output := range []interface{}{cmd.Stdout, cmd.Stderr} {
+		if file, ok := output.(io.Closer); ok {
+			if err := file.Close(); err != nil {
+				log.Errorf("Failed to close output file: %v", err)
+			}
+		}
+	}
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(labels))
+	for key, value := range labels {
+		ret[key] = value
+	}
+	return ret
+}
+
+func (ps *ProcessServer) getProcessDir(processID string) string {
+	return path.Join(ps.dataDir, "proc", processID)
 }
 
 func (ps *ProcessServer) getStdoutFilename(processID string) string {
-	return path.Join(ps.dataDir, "proc", processID, "stdout")
+	return path.Join(ps.getProcessDir(processID), "stdout")
 }
 
 func (ps *ProcessServer) getStderrFilename(processID string) string {
-	return path.Join(ps.dataDir, "proc", processID, "stderr")
+	return path.Join(ps.getProcessDir(processID), "stderr")
 }
 
 // createOutputFiles leaves the responsibility of closing the returned files
@@ -200,32 +649,122 @@ func (ps *ProcessServer) createOutputFiles(processID string) (*os.File, *os.File
 	return stdout, stderr, nil
 }
 
+// removeProcessFiles removes the directory of a process, along with its
+// output, workspace and private working directory
+func (ps *ProcessServer) removeProcessFiles(processID string) {
+	if err := os.RemoveAll(ps.getProcessDir(processID)); err != nil {
+		log.Errorf("Failed to remove the files of process %s: %v", processID, err)
+	}
+}
+
 type processHandle struct {
-	id        string
-	ownerID   string
-	pid       int
-	cmd       *exec.Cmd
-	create    time.Time
-	exit      time.Time
-	exitcode  int
-	running   bool
-	waitError error
-	m         sync.RWMutex
+	id      string
+	ownerID string
+	pid     int
+	// cmd is the first of the stages, which are the processes of a
+	// pipeline, or just the process itself
+	cmd         *exec.Cmd
+	stages      []*exec.Cmd
+	stageExited []bool
+	create      time.Time
+	start       time.Time
+	exit        time.Time
+	exitcode    int
+	state       rex.ProcessState
+	deleted     bool
+	truncated   bool
+	waitError   error
+	labels      map[string]string
+	callbacks   []string
+	priority    int
+	schedule    uuid.UUID
+	workflow    uuid.UUID
+	group       uuid.UUID
+	// service is the supervisor that the process is an incarnation of, if
+	// any
+	service     *supervisor
+	incarnation int
+	// quota is the account of the process in the quotas
+	quota quotaAccount
+	// outputBytes is the size of the stored output once the process exits
+	outputBytes int64
+	probes      []rex.Probe
+	probeStatus []rex.ProbeStatus
+	stopProbes  context.CancelFunc
+	// privateWorkDir tells whether the process runs in a working directory
+	// of its own, out of which the files matching artifactPatterns are
+	// collected as artifacts once it exits
+	privateWorkDir   bool
+	artifactPatterns []string
+	artifacts        []string
+	m                sync.RWMutex
+}
+
+func newProcessHandle(processID, ownerID string, stages []*exec.Cmd,
+	create time.Time, command rex.Command) *processHandle {
+	handle := &processHandle{
+		id:          processID,
+		ownerID:     ownerID,
+		cmd:         stages[0],
+		stages:      stages,
+		stageExited: make([]bool, len(stages)),
+		create:      create,
+		state:       rex.ProcessQueued,
+		labels:      copyLabels(command.Labels),
+		callbacks:   append([]string(nil), command.Callbacks...),
+		priority:    command.Priority,
+		schedule:    command.ScheduleID,
+		workflow:    command.WorkflowID,
+		group:       command.GroupID,
+		probes:      command.Probes,
+
+		privateWorkDir:   command.PrivateWorkDir,
+		artifactPatterns: append([]string(nil), command.Artifacts...),
+	}
+	for _, probe := range command.Probes {
+		handle.probeStatus = append(handle.probeStatus, rex.ProbeStatus{Name: probe.Name})
+	}
+	return handle
 }
 
 func (ph *processHandle) getProcessInfo() rex.ProcessInfo {
 	ph.m.RLock()
 	defer ph.m.RUnlock()
+	return ph.processInfoLocked()
+}
+
+// processInfoLocked requires the caller to hold ph.m
+func (ph *processHandle) processInfoLocked() rex.ProcessInfo {
 	info := rex.ProcessInfo{
-		ID:      uuid.MustParse(ph.id),
-		PID:     ph.pid,
-		Running: ph.running,
-		Path:    ph.cmd.Path,
-		Args:    ph.cmd.Args[1:],
-		Create:  ph.create,
-		OwnerID: uuid.MustParse(ph.ownerID),
-	}
-	if !ph.running {
+		ID:         uuid.MustParse(ph.id),
+		PID:        ph.pid,
+		Running:    ph.state == rex.ProcessRunning,
+		State:      ph.state,
+		Priority:   ph.priority,
+		Path:       ph.cmd.Path,
+		Args:       ph.cmd.Args[1:],
+		Create:     ph.create,
+		Start:      ph.start,
+		OwnerID:    uuid.MustParse(ph.ownerID),
+		Labels:     copyLabels(ph.labels),
+		Callbacks:  append([]string(nil), ph.callbacks...),
+		ScheduleID: ph.schedule,
+		WorkflowID: ph.workflow,
+		GroupID:    ph.group,
+		Artifacts:  append([]string(nil), ph.artifacts...),
+	}
+	if ph.service != nil {
+		info.ServiceID = uuid.MustParse(ph.service.id)
+		info.Incarnation = ph.incarnation
+	}
+	if len(ph.stages) > 1 {
+		info.Stages = ph.stagesInfoLocked()
+	}
+	if len(ph.probeStatus) > 0 {
+		info.Health = ph.healthLocked()
+		info.Probes = append([]rex.ProbeStatus(nil), ph.probeStatus...)
+	}
+	if ph.state != rex.ProcessQueued && ph.state != rex.ProcessRunning {
 		info.Exit = ph.exit
 		info.ExitCode = ph.exitcode
 	}
@@ -234,8 +773,15 @@ func (ph *processHandle) getProcessInfo() rex.ProcessInfo {
 
 // NewServer creates a ProcessServer which is a concrete implementation of
 // rex.Server.
-func NewServer(dataDir string) *ProcessServer {
-	return &ProcessServer{
+func NewServer(dataDir string, opts ...Option) *ProcessServer {
+	ps := &ProcessServer{
 		dataDir: dataDir,
+		events:  newEventLog(),
+		queue:   newJobQueue(0, 0),
+		quotas:  newQuotaTracker(),
+	}
+	for _, opt := range opts {
+		opt(ps)
 	}
+	return ps
 }
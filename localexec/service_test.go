@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -203,3 +205,115 @@ func TestListProcessInfo_Sort(t *testing.T) {
 		}
 	}
 }
+
+func TestListProcessInfo_OtherOwners(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ownerCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.Exec(ownerCtx, "true")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	ls, err := s.ListProcessInfo(otherCtx)
+	if err != nil {
+		t.Fatalf("While calling ListProcessInfo: %v", err)
+	}
+	if len(ls) != 0 {
+		t.Errorf("Expected the process of another user to be hidden, got %+v", ls)
+	}
+	ls, err = s.ListProcessInfo(ownerCtx)
+	if err != nil {
+		t.Fatalf("While calling ListProcessInfo: %v", err)
+	}
+	if len(ls) != 1 || ls[0].ID != procID {
+		t.Errorf("Expected the owner to see process %v, got %+v", procID, ls)
+	}
+}
+
+func TestExec_DirAndEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rex-dir")
+	if err != nil {
+		t.Fatalf("While creating the working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path: "sh",
+		Args: []string{"-c", `echo "$(pwd) $GREETING $REX_WORKSPACE"`},
+		Dir:  dir,
+		Env:  map[string]string{"GREETING": "hello", "REX_WORKSPACE": "overridden"},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	waitForState(t, s, ctx, procID, rex.ProcessExited)
+	content, err := s.Read(ctx, procID, rex.StdoutStream)
+	if err != nil {
+		t.Fatalf("While calling Read: %v", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	fields := strings.Fields(string(content))
+	if len(fields) != 3 || fields[0] != realDir || fields[1] != "hello" || fields[2] == "overridden" {
+		t.Errorf("Expected the process to run in %s with its environment, got %q", realDir, content)
+	}
+
+	for _, command := range []rex.Command{
+		{Path: "true", Dir: "relative"},
+		{Path: "true", Dir: dir, PrivateWorkDir: true},
+		{Path: "true", Env: map[string]string{"A=B": "C"}},
+	} {
+		if _, err := s.ExecCommand(ctx, command); err == nil {
+			t.Errorf("Expected command %+v to be rejected", command)
+		}
+	}
+}
+
+func TestExec_FailureLeavesNoFiles(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "rex-exec")
+	if err != nil {
+		t.Fatalf("While creating the data directory: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	s := localexec.NewServer(dataDir, localexec.WithConcurrencyLimits(1, 0))
+	ownerCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	// Fails to start
+	for _, command := range []rex.Command{
+		{Path: "/nonexistent"},
+		{Path: "/nonexistent", PrivateWorkDir: true},
+		{Path: "true", Pipeline: []rex.PipelineStage{{Path: "/nonexistent"}}},
+	} {
+		if _, err := s.ExecCommand(ownerCtx, command); err == nil {
+			t.Fatalf("Expected %+v to fail", command)
+		}
+	}
+	blocker, err := s.Exec(ownerCtx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ownerCtx, blocker, int(syscall.SIGKILL))
+	// Fails to be queued
+	if _, err := s.ExecCommand(ownerCtx, rex.Command{Path: "/nonexistent", PrivateWorkDir: true}); err == nil {
+		t.Fatalf("Expected a missing executable to be rejected")
+	}
+	// Fails to join the group of another owner
+	groupID := uuid.New()
+	if _, err := s.ExecCommand(ownerCtx, rex.Command{Path: "true", GroupID: groupID}); err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if _, err := s.ExecCommand(otherCtx, rex.Command{Path: "true", GroupID: groupID}); err == nil {
+		t.Fatalf("Expected joining the group of another owner to fail")
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(dataDir, "proc"))
+	if err != nil {
+		t.Fatalf("While listing the process directories: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected only the directories of the 2 created processes, got %d", len(entries))
+	}
+}
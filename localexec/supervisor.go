@@ -0,0 +1,230 @@
+package localexec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultMaxRestartBackoff = time.Minute
+)
+
+// supervisor keeps a service alive by creating a new incarnation of it
+// whenever the previous one exits, as allowed by its restart policy.
+type supervisor struct {
+	id      string
+	ownerID string
+	command rex.Command
+	policy  rex.RestartPolicy
+	ps      *ProcessServer
+
+	m            sync.Mutex
+	incarnations []*processHandle
+	state        rex.ServiceState
+	restarts     int
+	nextRestart  time.Time
+	timer        *time.Timer
+}
+
+// startService creates a service along with its first incarnation. Fails if
+// the first incarnation cannot be created.
+func (ps *ProcessServer) startService(ownerID string, command rex.Command) (uuid.UUID, error) {
+	policy := *command.Restart
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultRestartBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxRestartBackoff
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	command.Restart = nil
+
+	service := &supervisor{
+		id:      uuid.New().String(),
+		ownerID: ownerID,
+		command: command,
+		policy:  policy,
+		ps:      ps,
+		state:   rex.ServiceRunning,
+	}
+
+	// Holding the lock keeps the first incarnation from being handled before
+	// the service is completely set up.
+	service.m.Lock()
+	defer service.m.Unlock()
+	if err := service.startIncarnationLocked(); err != nil {
+		return uuid.Nil, err
+	}
+	ps.services.Store(service.id, service)
+	return uuid.MustParse(service.id), nil
+}
+
+// GetServiceInfo returns the state of a service along with its incarnations
+func (ps *ProcessServer) GetServiceInfo(ctx context.Context, serviceID uuid.UUID) (rex.ServiceInfo, error) {
+	mustBeService, ok := ps.services.Load(serviceID.String())
+	if !ok {
+		return rex.ServiceInfo{}, rex.ErrNotFound
+	}
+	service := mustBeService.(*supervisor)
+	if err := ps.authorize(ctx, service.resource()); err != nil {
+		return rex.ServiceInfo{}, err
+	}
+	return service.info(), nil
+}
+
+func (s *supervisor) info() rex.ServiceInfo {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	policy := s.policy
+	command := s.command
+	command.Restart = &policy
+	info := rex.ServiceInfo{
+		ID:       uuid.MustParse(s.id),
+		OwnerID:  uuid.MustParse(s.ownerID),
+		Command:  command,
+		State:    s.state,
+		Restarts: s.restarts,
+	}
+	if s.state == rex.ServiceRestarting {
+		info.NextRestart = s.nextRestart
+	}
+	for _, handle := range s.incarnations {
+		info.Incarnations = append(info.Incarnations, s.ps.getProcessInfo(handle))
+	}
+	return info
+}
+
+// current returns the latest incarnation
+func (s *supervisor) current() *processHandle {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.incarnations[len(s.incarnations)-1]
+}
+
+// startIncarnationLocked creates the next incarnation of the service.
+// Requires the caller to hold s.m
+func (s *supervisor) startIncarnationLocked() error {
+	handle, err := s.ps.execCommand(s.ownerID, s.command, s, len(s.incarnations)+1)
+	if err != nil {
+		return err
+	}
+	s.incarnations = append(s.incarnations, handle)
+	return nil
+}
+
+// exited decides what to do after an incarnation reaches its final state
+func (s *supervisor) exited(handle *processHandle, info rex.ProcessInfo) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.state != rex.ServiceRunning || handle != s.incarnations[len(s.incarnations)-1] {
+		return
+	}
+
+	failed := info.State != rex.ProcessExited || info.ExitCode != 0
+	restart := s.policy.Mode == rex.RestartAlways ||
+		(s.policy.Mode == rex.RestartOnFailure && failed)
+	if !restart {
+		s.state = rex.ServiceFinished
+		return
+	}
+	s.scheduleRestartLocked()
+}
+
+// scheduleRestartLocked waits for the backoff before the next restart, or
+// finishes the service if it may not be restarted anymore. Requires the
+// caller to hold s.m
+func (s *supervisor) scheduleRestartLocked() {
+	if s.policy.MaxRestarts > 0 && s.restarts >= s.policy.MaxRestarts {
+		log.Infof("Service %s has reached its limit of %d restarts", s.id, s.policy.MaxRestarts)
+		s.state = rex.ServiceFinished
+		return
+	}
+
+	backoff := s.policy.Backoff
+	for i := 0; i < s.restarts && backoff < s.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.policy.MaxBackoff {
+		backoff = s.policy.MaxBackoff
+	}
+
+	s.state = rex.ServiceRestarting
+	s.nextRestart = time.Now().UTC().Add(backoff)
+	s.timer = time.AfterFunc(backoff, s.restart)
+}
+
+func (s *supervisor) restart() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.state != rex.ServiceRestarting {
+		// Stopped in the meantime
+		return
+	}
+	s.restarts++
+	s.state = rex.ServiceRunning
+	if err := s.startIncarnationLocked(); err != nil {
+		log.Infof("Failed to restart service %s: %v", s.id, err)
+		s.scheduleRestartLocked()
+	}
+}
+
+// stop keeps the service from being restarted and sends the signal to its
+// current incarnation if it is running or queued. Returns rex.ErrNotRunning
+// if the service was not running to begin with.
+func (s *supervisor) stop(signal int) error {
+	s.m.Lock()
+	wasRunning := s.state == rex.ServiceRunning || s.state == rex.ServiceRestarting
+	if s.state == rex.ServiceRestarting {
+		s.timer.Stop()
+	}
+	if wasRunning {
+		s.state = rex.ServiceStopped
+	}
+	current := s.incarnations[len(s.incarnations)-1]
+	s.m.Unlock()
+
+	if info := current.getProcessInfo(); info.State == rex.ProcessQueued || info.State == rex.ProcessRunning {
+		return s.ps.signal(current, signal)
+	}
+	if !wasRunning {
+		return rex.ErrNotRunning
+	}
+	return nil
+}
+
+// delete removes a service that is not running along with its incarnations
+func (s *supervisor) delete() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.state == rex.ServiceRunning || s.state == rex.ServiceRestarting {
+		return rex.ErrProcessRunning
+	}
+	for _, handle := range s.incarnations {
+		if handle.getProcessInfo().State == rex.ProcessRunning {
+			return rex.ErrProcessRunning
+		}
+	}
+
+	s.ps.services.Delete(s.id)
+	for _, handle := range s.incarnations {
+		// Some of the incarnations might have already been deleted
+		if err := s.ps.delete(handle); err != nil && err != rex.ErrNotFound {
+			log.Errorf("Failed to delete incarnation %s of service %s: %v", handle.id, s.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func waitForServiceState(t *testing.T, s *localexec.ProcessServer, ctx context.Context,
+	serviceID uuid.UUID, state rex.ServiceState) rex.ServiceInfo {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, err := s.GetServiceInfo(ctx, serviceID)
+		if err != nil {
+			t.Fatalf("While calling GetServiceInfo: %v", err)
+		}
+		if info.State == state {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected service to reach state %v, it is %v", state, info.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestService_RestartOnFailure(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	serviceID, err := s.ExecCommand(ctx, rex.Command{
+		Path: "sh",
+		Args: []string{"-c", "echo $$; exit 3"},
+		Restart: &rex.RestartPolicy{
+			Mode:        rex.RestartOnFailure,
+			MaxRestarts: 2,
+			Backoff:     10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	info := waitForServiceState(t, s, ctx, serviceID, rex.ServiceFinished)
+	if info.Restarts != 2 || len(info.Incarnations) != 3 {
+		t.Fatalf("Expected 2 restarts and 3 incarnations, got %d and %d", info.Restarts, len(info.Incarnations))
+	}
+	outputs := make(map[string]bool)
+	for i, incarnation := range info.Incarnations {
+		if incarnation.ServiceID != serviceID || incarnation.Incarnation != i+1 {
+			t.Errorf("Expected incarnation %d of %v, got %d of %v",
+				i+1, serviceID, incarnation.Incarnation, incarnation.ServiceID)
+		}
+		if incarnation.ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", incarnation.ExitCode)
+		}
+		output, err := s.Read(ctx, incarnation.ID, rex.StdoutStream)
+		if err != nil {
+			t.Fatalf("While calling Read: %v", err)
+		}
+		outputs[string(output)] = true
+	}
+	if len(outputs) != 3 {
+		t.Errorf("Expected each incarnation to have its own output, got %v", outputs)
+	}
+
+	current, err := s.GetProcessInfo(ctx, serviceID)
+	if err != nil {
+		t.Fatalf("While calling GetProcessInfo: %v", err)
+	}
+	if current.ID != info.Incarnations[2].ID {
+		t.Errorf("Expected the service ID to resolve to the last incarnation")
+	}
+
+	if err := s.Delete(ctx, serviceID); err != nil {
+		t.Fatalf("While calling Delete: %v", err)
+	}
+	if _, err := s.GetProcessInfo(ctx, info.Incarnations[0].ID); err != rex.ErrNotFound {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotFound, err)
+	}
+}
+
+func TestService_KillStops(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	serviceID, err := s.ExecCommand(ctx, rex.Command{
+		Path:    "sleep",
+		Args:    []string{"10"},
+		Restart: &rex.RestartPolicy{Mode: rex.RestartAlways, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+	if err := s.Delete(ctx, serviceID); err != rex.ErrProcessRunning {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrProcessRunning, err)
+	}
+
+	if err := s.Kill(ctx, serviceID, int(syscall.SIGTERM)); err != nil {
+		t.Fatalf("While calling Kill: %v", err)
+	}
+	info := waitForServiceState(t, s, ctx, serviceID, rex.ServiceStopped)
+	waitForState(t, s, ctx, info.Incarnations[0].ID, rex.ProcessExited)
+
+	time.Sleep(50 * time.Millisecond)
+	if info, _ := s.GetServiceInfo(ctx, serviceID); len(info.Incarnations) != 1 || info.State != rex.ServiceStopped {
+		t.Errorf("Expected the stopped service to not be restarted, got %d incarnations in state %v",
+			len(info.Incarnations), info.State)
+	}
+	if err := s.Kill(ctx, serviceID, int(syscall.SIGTERM)); err != rex.ErrNotRunning {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrNotRunning, err)
+	}
+}
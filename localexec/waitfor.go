@@ -0,0 +1,102 @@
+package localexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+)
+
+// outputPollInterval is the wait between the reads of an output file that
+// has no new lines
+const outputPollInterval = 50 * time.Millisecond
+
+// WaitFor follows the stored stdout or stderr of a process until a line
+// matches pattern, the process reaches its final state, or ctx is done.
+func (ps *ProcessServer) WaitFor(ctx context.Context, processID uuid.UUID,
+	target rex.OutputStream, pattern string) (rex.OutputMatch, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return rex.OutputMatch{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	handle, ok := ps.loadHandle(processID)
+	if !ok {
+		return rex.OutputMatch{}, rex.ErrNotFound
+	}
+	if err := ps.authorize(ctx, handle.resource()); err != nil {
+		return rex.OutputMatch{}, err
+	}
+
+	var targetFile string
+	if target == rex.StderrStream {
+		targetFile = ps.getStderrFilename(handle.id)
+	} else if target == rex.StdoutStream {
+		targetFile = ps.getStdoutFilename(handle.id)
+	}
+	if targetFile == "" {
+		return rex.OutputMatch{}, rex.ErrInvalidArgument
+	}
+	file, err := os.Open(targetFile)
+	if err != nil {
+		return rex.OutputMatch{}, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	ticker := time.NewTicker(outputPollInterval)
+	defer ticker.Stop()
+	// pending holds the line that is being read, which starts at offset
+	var pending []byte
+	var offset int64
+	for {
+		// Checked before reading, as the output is complete once the process
+		// has exited.
+		state := handle.getProcessInfo().State
+		final := state != rex.ProcessQueued && state != rex.ProcessRunning
+
+		for {
+			chunk, err := reader.ReadBytes('\n')
+			pending = append(pending, chunk...)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return rex.OutputMatch{}, err
+			}
+			line := bytes.TrimRight(pending, "\r\n")
+			if re.Match(line) {
+				return ps.outputMatch(handle, line, offset), nil
+			}
+			offset += int64(len(pending))
+			pending = pending[:0]
+		}
+		if final {
+			if len(pending) > 0 && re.Match(pending) {
+				return ps.outputMatch(handle, pending, offset), nil
+			}
+			return rex.OutputMatch{Process: ps.getProcessInfo(handle)}, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return rex.OutputMatch{}, ctx.Err()
+		}
+	}
+}
+
+func (ps *ProcessServer) outputMatch(handle *processHandle, line []byte, offset int64) rex.OutputMatch {
+	return rex.OutputMatch{
+		Matched: true,
+		Line:    string(line),
+		Offset:  offset,
+		Process: ps.getProcessInfo(handle),
+	}
+}
@@ -0,0 +1,68 @@
+package localexec_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func TestWaitFor_Match(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.Exec(ctx, "sh", "-c", "echo starting; sleep 0.2; echo 'listening on :8080'; sleep 10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctx, procID, int(syscall.SIGKILL))
+
+	match, err := s.WaitFor(ctx, procID, rex.StdoutStream, "listening on :[0-9]+")
+	if err != nil {
+		t.Fatalf("While calling WaitFor: %v", err)
+	}
+	if !match.Matched || match.Line != "listening on :8080" || match.Offset != 9 {
+		t.Errorf("Expected the second line at offset 9 to match, got %+v", match)
+	}
+	if match.Process.State != rex.ProcessRunning {
+		t.Errorf("Expected the process to be running, it is %v", match.Process.State)
+	}
+}
+
+func TestWaitFor_ExitAndTimeout(t *testing.T) {
+	s := localexec.NewServer(os.TempDir())
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	procID, err := s.Exec(ctx, "sh", "-c", "echo -n partial")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	match, err := s.WaitFor(ctx, procID, rex.StdoutStream, "ready")
+	if err != nil {
+		t.Fatalf("While calling WaitFor: %v", err)
+	}
+	if match.Matched || match.Process.State != rex.ProcessExited {
+		t.Errorf("Expected the exited process not to match, got %+v", match)
+	}
+	match, err = s.WaitFor(ctx, procID, rex.StdoutStream, "^part")
+	if err != nil || !match.Matched || match.Line != "partial" {
+		t.Errorf("Expected the unterminated last line to match, got %+v, %v", match, err)
+	}
+
+	procID, err = s.Exec(ctx, "sleep", "10")
+	if err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	defer s.Kill(ctx, procID, int(syscall.SIGKILL))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.WaitFor(timeoutCtx, procID, rex.StderrStream, "."); err != context.DeadlineExceeded {
+		t.Errorf("Expected error %v, actual: %v", context.DeadlineExceeded, err)
+	}
+}
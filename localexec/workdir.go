@@ -0,0 +1,118 @@
+package localexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// validateArtifacts checks the artifact patterns of command
+func validateArtifacts(command rex.Command) error {
+	if len(command.Artifacts) > 0 && !command.PrivateWorkDir {
+		return errors.New("artifacts require a private working directory")
+	}
+	for _, pattern := range command.Artifacts {
+		if _, ok := relativePath(pattern); !ok {
+			return fmt.Errorf("artifact pattern %q must be relative to the working directory", pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("artifact pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateEnvironment checks the working directory and the environment
+// variables of command
+func validateEnvironment(command rex.Command) error {
+	if command.Dir != "" {
+		if command.PrivateWorkDir {
+			return errors.New("a working directory cannot be combined with a private one")
+		}
+		if !filepath.IsAbs(command.Dir) {
+			return fmt.Errorf("working directory %q must be an absolute path", command.Dir)
+		}
+		stat, err := os.Stat(command.Dir)
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return fmt.Errorf("%s is not a directory", command.Dir)
+		}
+	}
+	for name := range command.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("bad environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// collectArtifacts moves the files of the private working directory of a
+// process that match its artifact patterns to its workspace, and removes the
+// rest. Returns the paths of the collected files relative to the workspace.
+func (ps *ProcessServer) collectArtifacts(handle *processHandle) []string {
+	workDir := ps.getWorkDir(handle.id)
+	workspace := ps.getProcessWorkspace(handle.id)
+	var artifacts []string
+	err := filepath.Walk(workDir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Symbolic links are left behind
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(workDir, name)
+		if err != nil {
+			return err
+		}
+		if !matchArtifact(handle.artifactPatterns, rel) {
+			return nil
+		}
+		target := filepath.Join(workspace, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(name, target); err != nil {
+			return err
+		}
+		artifacts = append(artifacts, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to collect the artifacts of process %s: %v", handle.id, err)
+	}
+	ps.removeWorkDir(handle)
+	return artifacts
+}
+
+func (ps *ProcessServer) removeWorkDir(handle *processHandle) {
+	if err := os.RemoveAll(ps.getWorkDir(handle.id)); err != nil {
+		log.Errorf("Failed to remove the working directory of process %s: %v", handle.id, err)
+	}
+}
+
+func (ps *ProcessServer) getWorkDir(processID string) string {
+	return path.Join(ps.getProcessDir(processID), "work")
+}
+
+// matchArtifact reports whether a file, or any of the directories that it is
+// in, matches any of the patterns
+func matchArtifact(patterns []string, name string) bool {
+	for ; name != "."; name = filepath.Dir(name) {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(filepath.Clean(pattern), name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -29,6 +29,69 @@ type RexClient interface {
 	Kill(ctx context.Context, in *KillRequest, opts ...grpc.CallOption) (*KillResponse, error)
 	// Read returns the stdout or the stderr of a process
 	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error)
+	// Delete removes a process that is not running, along with its output.
+	// Queued processes are canceled.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Watch streams the lifecycle events of the processes as they happen.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Rex_WatchClient, error)
+	// GetQuota returns the quota of the caller along with its current usage.
+	GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error)
+	// CreateSchedule creates a schedule owned by the caller which executes a
+	// command whenever its cron expression matches.
+	CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*Schedule, error)
+	// ListSchedules returns the schedules of the caller.
+	ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ScheduleList, error)
+	// DeleteSchedule removes a schedule. Its processes are left untouched.
+	DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error)
+	// PauseSchedule pauses or resumes a schedule.
+	PauseSchedule(ctx context.Context, in *PauseScheduleRequest, opts ...grpc.CallOption) (*Schedule, error)
+	// GetServiceInfo returns the state of a supervised service along with its
+	// incarnations.
+	GetServiceInfo(ctx context.Context, in *GetServiceInfoRequest, opts ...grpc.CallOption) (*ServiceInfo, error)
+	// WaitFor blocks until a line of the output of a process matches a
+	// pattern, the process exits, or the deadline of the call is exceeded.
+	WaitFor(ctx context.Context, in *WaitForRequest, opts ...grpc.CallOption) (*WaitForResponse, error)
+	// SubmitWorkflow starts a workflow of processes with dependencies between
+	// them on behalf of the caller.
+	SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error)
+	// GetWorkflow returns the state of a workflow along with its steps.
+	GetWorkflow(ctx context.Context, in *GetWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error)
+	// ListWorkflows returns the workflows of the caller.
+	ListWorkflows(ctx context.Context, in *ListWorkflowsRequest, opts ...grpc.CallOption) (*WorkflowList, error)
+	// CancelWorkflow kills the running steps of a workflow and cancels the
+	// rest of them.
+	CancelWorkflow(ctx context.Context, in *CancelWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error)
+	// GetGroupInfo returns the aggregate status of a group of processes along
+	// with its processes.
+	GetGroupInfo(ctx context.Context, in *GetGroupInfoRequest, opts ...grpc.CallOption) (*GroupInfo, error)
+	// ListGroups returns the groups of the caller.
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*GroupList, error)
+	// KillGroup sends a signal to all of the processes of a group.
+	KillGroup(ctx context.Context, in *KillGroupRequest, opts ...grpc.CallOption) (*KillGroupResponse, error)
+	// WaitGroup blocks until all or any of the processes of a group exit, or
+	// the deadline of the call is exceeded.
+	WaitGroup(ctx context.Context, in *WaitGroupRequest, opts ...grpc.CallOption) (*GroupInfo, error)
+	// DeleteGroup removes all of the processes of a group, none of which may
+	// be running.
+	DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error)
+	// GetCapacity returns the number of the running and queued processes of
+	// the server along with its concurrency limit.
+	GetCapacity(ctx context.Context, in *GetCapacityRequest, opts ...grpc.CallOption) (*Capacity, error)
+	// Upload writes a file to the workspace of a process or of the caller.
+	// The first message carries the header, the rest carry the content.
+	Upload(ctx context.Context, opts ...grpc.CallOption) (Rex_UploadClient, error)
+	// Download reads a file from the workspace of a process or of the caller.
+	// The first message carries the info of the file, the rest carry the
+	// content.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Rex_DownloadClient, error)
+	// StatFile returns the info of a file in the workspace of a process or of
+	// the caller.
+	StatFile(ctx context.Context, in *StatFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	// WhoAmI returns the identity of the caller as seen by the server.
+	WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*Identity, error)
+	// CheckAccess evaluates the policies of the server for a hypothetical
+	// call, without making it, and explains the decision.
+	CheckAccess(ctx context.Context, in *CheckAccessRequest, opts ...grpc.CallOption) (*AccessDecision, error)
 }
 
 type rexClient struct {
@@ -84,6 +147,293 @@ func (c *rexClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *rexClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/Rex/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Rex_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Rex_serviceDesc.Streams[0], "/Rex/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rexWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Rex_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type rexWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *rexWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rexClient) GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error) {
+	out := new(GetQuotaResponse)
+	err := c.cc.Invoke(ctx, "/Rex/GetQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*Schedule, error) {
+	out := new(Schedule)
+	err := c.cc.Invoke(ctx, "/Rex/CreateSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ScheduleList, error) {
+	out := new(ScheduleList)
+	err := c.cc.Invoke(ctx, "/Rex/ListSchedules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error) {
+	out := new(DeleteScheduleResponse)
+	err := c.cc.Invoke(ctx, "/Rex/DeleteSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) PauseSchedule(ctx context.Context, in *PauseScheduleRequest, opts ...grpc.CallOption) (*Schedule, error) {
+	out := new(Schedule)
+	err := c.cc.Invoke(ctx, "/Rex/PauseSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) GetServiceInfo(ctx context.Context, in *GetServiceInfoRequest, opts ...grpc.CallOption) (*ServiceInfo, error) {
+	out := new(ServiceInfo)
+	err := c.cc.Invoke(ctx, "/Rex/GetServiceInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) WaitFor(ctx context.Context, in *WaitForRequest, opts ...grpc.CallOption) (*WaitForResponse, error) {
+	out := new(WaitForResponse)
+	err := c.cc.Invoke(ctx, "/Rex/WaitFor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error) {
+	out := new(Workflow)
+	err := c.cc.Invoke(ctx, "/Rex/SubmitWorkflow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) GetWorkflow(ctx context.Context, in *GetWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error) {
+	out := new(Workflow)
+	err := c.cc.Invoke(ctx, "/Rex/GetWorkflow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) ListWorkflows(ctx context.Context, in *ListWorkflowsRequest, opts ...grpc.CallOption) (*WorkflowList, error) {
+	out := new(WorkflowList)
+	err := c.cc.Invoke(ctx, "/Rex/ListWorkflows", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) CancelWorkflow(ctx context.Context, in *CancelWorkflowRequest, opts ...grpc.CallOption) (*Workflow, error) {
+	out := new(Workflow)
+	err := c.cc.Invoke(ctx, "/Rex/CancelWorkflow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) GetGroupInfo(ctx context.Context, in *GetGroupInfoRequest, opts ...grpc.CallOption) (*GroupInfo, error) {
+	out := new(GroupInfo)
+	err := c.cc.Invoke(ctx, "/Rex/GetGroupInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*GroupList, error) {
+	out := new(GroupList)
+	err := c.cc.Invoke(ctx, "/Rex/ListGroups", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) KillGroup(ctx context.Context, in *KillGroupRequest, opts ...grpc.CallOption) (*KillGroupResponse, error) {
+	out := new(KillGroupResponse)
+	err := c.cc.Invoke(ctx, "/Rex/KillGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) WaitGroup(ctx context.Context, in *WaitGroupRequest, opts ...grpc.CallOption) (*GroupInfo, error) {
+	out := new(GroupInfo)
+	err := c.cc.Invoke(ctx, "/Rex/WaitGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error) {
+	out := new(DeleteGroupResponse)
+	err := c.cc.Invoke(ctx, "/Rex/DeleteGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) GetCapacity(ctx context.Context, in *GetCapacityRequest, opts ...grpc.CallOption) (*Capacity, error) {
+	out := new(Capacity)
+	err := c.cc.Invoke(ctx, "/Rex/GetCapacity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) Upload(ctx context.Context, opts ...grpc.CallOption) (Rex_UploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Rex_serviceDesc.Streams[1], "/Rex/Upload", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rexUploadClient{stream}
+	return x, nil
+}
+
+type Rex_UploadClient interface {
+	Send(*UploadRequest) error
+	CloseAndRecv() (*FileInfo, error)
+	grpc.ClientStream
+}
+
+type rexUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *rexUploadClient) Send(m *UploadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rexUploadClient) CloseAndRecv() (*FileInfo, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(FileInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rexClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Rex_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Rex_serviceDesc.Streams[2], "/Rex/Download", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rexDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Rex_DownloadClient interface {
+	Recv() (*DownloadResponse, error)
+	grpc.ClientStream
+}
+
+type rexDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *rexDownloadClient) Recv() (*DownloadResponse, error) {
+	m := new(DownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rexClient) StatFile(ctx context.Context, in *StatFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, "/Rex/StatFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*Identity, error) {
+	out := new(Identity)
+	err := c.cc.Invoke(ctx, "/Rex/WhoAmI", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rexClient) CheckAccess(ctx context.Context, in *CheckAccessRequest, opts ...grpc.CallOption) (*AccessDecision, error) {
+	out := new(AccessDecision)
+	err := c.cc.Invoke(ctx, "/Rex/CheckAccess", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RexServer is the server API for Rex service.
 // All implementations must embed UnimplementedRexServer
 // for forward compatibility
@@ -100,6 +450,69 @@ type RexServer interface {
 	Kill(context.Context, *KillRequest) (*KillResponse, error)
 	// Read returns the stdout or the stderr of a process
 	Read(context.Context, *ReadRequest) (*ReadResponse, error)
+	// Delete removes a process that is not running, along with its output.
+	// Queued processes are canceled.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Watch streams the lifecycle events of the processes as they happen.
+	Watch(*WatchRequest, Rex_WatchServer) error
+	// GetQuota returns the quota of the caller along with its current usage.
+	GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error)
+	// CreateSchedule creates a schedule owned by the caller which executes a
+	// command whenever its cron expression matches.
+	CreateSchedule(context.Context, *CreateScheduleRequest) (*Schedule, error)
+	// ListSchedules returns the schedules of the caller.
+	ListSchedules(context.Context, *ListSchedulesRequest) (*ScheduleList, error)
+	// DeleteSchedule removes a schedule. Its processes are left untouched.
+	DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error)
+	// PauseSchedule pauses or resumes a schedule.
+	PauseSchedule(context.Context, *PauseScheduleRequest) (*Schedule, error)
+	// GetServiceInfo returns the state of a supervised service along with its
+	// incarnations.
+	GetServiceInfo(context.Context, *GetServiceInfoRequest) (*ServiceInfo, error)
+	// WaitFor blocks until a line of the output of a process matches a
+	// pattern, the process exits, or the deadline of the call is exceeded.
+	WaitFor(context.Context, *WaitForRequest) (*WaitForResponse, error)
+	// SubmitWorkflow starts a workflow of processes with dependencies between
+	// them on behalf of the caller.
+	SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*Workflow, error)
+	// GetWorkflow returns the state of a workflow along with its steps.
+	GetWorkflow(context.Context, *GetWorkflowRequest) (*Workflow, error)
+	// ListWorkflows returns the workflows of the caller.
+	ListWorkflows(context.Context, *ListWorkflowsRequest) (*WorkflowList, error)
+	// CancelWorkflow kills the running steps of a workflow and cancels the
+	// rest of them.
+	CancelWorkflow(context.Context, *CancelWorkflowRequest) (*Workflow, error)
+	// GetGroupInfo returns the aggregate status of a group of processes along
+	// with its processes.
+	GetGroupInfo(context.Context, *GetGroupInfoRequest) (*GroupInfo, error)
+	// ListGroups returns the groups of the caller.
+	ListGroups(context.Context, *ListGroupsRequest) (*GroupList, error)
+	// KillGroup sends a signal to all of the processes of a group.
+	KillGroup(context.Context, *KillGroupRequest) (*KillGroupResponse, error)
+	// WaitGroup blocks until all or any of the processes of a group exit, or
+	// the deadline of the call is exceeded.
+	WaitGroup(context.Context, *WaitGroupRequest) (*GroupInfo, error)
+	// DeleteGroup removes all of the processes of a group, none of which may
+	// be running.
+	DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error)
+	// GetCapacity returns the number of the running and queued processes of
+	// the server along with its concurrency limit.
+	GetCapacity(context.Context, *GetCapacityRequest) (*Capacity, error)
+	// Upload writes a file to the workspace of a process or of the caller.
+	// The first message carries the header, the rest carry the content.
+	Upload(Rex_UploadServer) error
+	// Download reads a file from the workspace of a process or of the caller.
+	// The first message carries the info of the file, the rest carry the
+	// content.
+	Download(*DownloadRequest, Rex_DownloadServer) error
+	// StatFile returns the info of a file in the workspace of a process or of
+	// the caller.
+	StatFile(context.Context, *StatFileRequest) (*FileInfo, error)
+	// WhoAmI returns the identity of the caller as seen by the server.
+	WhoAmI(context.Context, *WhoAmIRequest) (*Identity, error)
+	// CheckAccess evaluates the policies of the server for a hypothetical
+	// call, without making it, and explains the decision.
+	CheckAccess(context.Context, *CheckAccessRequest) (*AccessDecision, error)
 	mustEmbedUnimplementedRexServer()
 }
 
@@ -122,6 +535,78 @@ func (*UnimplementedRexServer) Kill(context.Context, *KillRequest) (*KillRespons
 func (*UnimplementedRexServer) Read(context.Context, *ReadRequest) (*ReadResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Read not implemented")
 }
+func (*UnimplementedRexServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedRexServer) Watch(*WatchRequest, Rex_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (*UnimplementedRexServer) GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuota not implemented")
+}
+func (*UnimplementedRexServer) CreateSchedule(context.Context, *CreateScheduleRequest) (*Schedule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSchedule not implemented")
+}
+func (*UnimplementedRexServer) ListSchedules(context.Context, *ListSchedulesRequest) (*ScheduleList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchedules not implemented")
+}
+func (*UnimplementedRexServer) DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSchedule not implemented")
+}
+func (*UnimplementedRexServer) PauseSchedule(context.Context, *PauseScheduleRequest) (*Schedule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseSchedule not implemented")
+}
+func (*UnimplementedRexServer) GetServiceInfo(context.Context, *GetServiceInfoRequest) (*ServiceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServiceInfo not implemented")
+}
+func (*UnimplementedRexServer) WaitFor(context.Context, *WaitForRequest) (*WaitForResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WaitFor not implemented")
+}
+func (*UnimplementedRexServer) SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*Workflow, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitWorkflow not implemented")
+}
+func (*UnimplementedRexServer) GetWorkflow(context.Context, *GetWorkflowRequest) (*Workflow, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkflow not implemented")
+}
+func (*UnimplementedRexServer) ListWorkflows(context.Context, *ListWorkflowsRequest) (*WorkflowList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWorkflows not implemented")
+}
+func (*UnimplementedRexServer) CancelWorkflow(context.Context, *CancelWorkflowRequest) (*Workflow, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelWorkflow not implemented")
+}
+func (*UnimplementedRexServer) GetGroupInfo(context.Context, *GetGroupInfoRequest) (*GroupInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroupInfo not implemented")
+}
+func (*UnimplementedRexServer) ListGroups(context.Context, *ListGroupsRequest) (*GroupList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (*UnimplementedRexServer) KillGroup(context.Context, *KillGroupRequest) (*KillGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KillGroup not implemented")
+}
+func (*UnimplementedRexServer) WaitGroup(context.Context, *WaitGroupRequest) (*GroupInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WaitGroup not implemented")
+}
+func (*UnimplementedRexServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (*UnimplementedRexServer) GetCapacity(context.Context, *GetCapacityRequest) (*Capacity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCapacity not implemented")
+}
+func (*UnimplementedRexServer) Upload(Rex_UploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (*UnimplementedRexServer) Download(*DownloadRequest, Rex_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (*UnimplementedRexServer) StatFile(context.Context, *StatFileRequest) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StatFile not implemented")
+}
+func (*UnimplementedRexServer) WhoAmI(context.Context, *WhoAmIRequest) (*Identity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WhoAmI not implemented")
+}
+func (*UnimplementedRexServer) CheckAccess(context.Context, *CheckAccessRequest) (*AccessDecision, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckAccess not implemented")
+}
 func (*UnimplementedRexServer) mustEmbedUnimplementedRexServer() {}
 
 func RegisterRexServer(s *grpc.Server, srv RexServer) {
@@ -218,31 +703,577 @@ func _Rex_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{
 	return interceptor(ctx, in, info, handler)
 }
 
-var _Rex_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "Rex",
-	HandlerType: (*RexServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Exec",
-			Handler:    _Rex_Exec_Handler,
-		},
-		{
-			MethodName: "ListProcessInfo",
-			Handler:    _Rex_ListProcessInfo_Handler,
-		},
-		{
-			MethodName: "GetProcessInfo",
-			Handler:    _Rex_GetProcessInfo_Handler,
-		},
-		{
-			MethodName: "Kill",
-			Handler:    _Rex_Kill_Handler,
-		},
-		{
-			MethodName: "Read",
-			Handler:    _Rex_Read_Handler,
+func _Rex_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RexServer).Watch(m, &rexWatchServer{stream})
+}
+
+type Rex_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type rexWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *rexWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Rex_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/GetQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).GetQuota(ctx, req.(*GetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_CreateSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).CreateSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/CreateSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).CreateSchedule(ctx, req.(*CreateScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_ListSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).ListSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/ListSchedules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).ListSchedules(ctx, req.(*ListSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_DeleteSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).DeleteSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/DeleteSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).DeleteSchedule(ctx, req.(*DeleteScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_PauseSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).PauseSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/PauseSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).PauseSchedule(ctx, req.(*PauseScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_GetServiceInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServiceInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).GetServiceInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/GetServiceInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).GetServiceInfo(ctx, req.(*GetServiceInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_WaitFor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitForRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).WaitFor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/WaitFor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).WaitFor(ctx, req.(*WaitForRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_SubmitWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).SubmitWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/SubmitWorkflow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).SubmitWorkflow(ctx, req.(*SubmitWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_GetWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).GetWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/GetWorkflow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).GetWorkflow(ctx, req.(*GetWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_ListWorkflows_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWorkflowsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).ListWorkflows(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/ListWorkflows",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).ListWorkflows(ctx, req.(*ListWorkflowsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_CancelWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).CancelWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/CancelWorkflow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).CancelWorkflow(ctx, req.(*CancelWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_GetGroupInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGroupInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).GetGroupInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/GetGroupInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).GetGroupInfo(ctx, req.(*GetGroupInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/ListGroups",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_KillGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KillGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).KillGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/KillGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).KillGroup(ctx, req.(*KillGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_WaitGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).WaitGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/WaitGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).WaitGroup(ctx, req.(*WaitGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/DeleteGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_GetCapacity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCapacityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).GetCapacity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/GetCapacity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).GetCapacity(ctx, req.(*GetCapacityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RexServer).Upload(&rexUploadServer{stream})
+}
+
+type Rex_UploadServer interface {
+	SendAndClose(*FileInfo) error
+	Recv() (*UploadRequest, error)
+	grpc.ServerStream
+}
+
+type rexUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *rexUploadServer) SendAndClose(m *FileInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rexUploadServer) Recv() (*UploadRequest, error) {
+	m := new(UploadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Rex_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RexServer).Download(m, &rexDownloadServer{stream})
+}
+
+type Rex_DownloadServer interface {
+	Send(*DownloadResponse) error
+	grpc.ServerStream
+}
+
+type rexDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *rexDownloadServer) Send(m *DownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Rex_StatFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).StatFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/StatFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).StatFile(ctx, req.(*StatFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_WhoAmI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhoAmIRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).WhoAmI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/WhoAmI",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).WhoAmI(ctx, req.(*WhoAmIRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rex_CheckAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RexServer).CheckAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Rex/CheckAccess",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RexServer).CheckAccess(ctx, req.(*CheckAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Rex_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Rex",
+	HandlerType: (*RexServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Exec",
+			Handler:    _Rex_Exec_Handler,
+		},
+		{
+			MethodName: "ListProcessInfo",
+			Handler:    _Rex_ListProcessInfo_Handler,
+		},
+		{
+			MethodName: "GetProcessInfo",
+			Handler:    _Rex_GetProcessInfo_Handler,
+		},
+		{
+			MethodName: "Kill",
+			Handler:    _Rex_Kill_Handler,
+		},
+		{
+			MethodName: "Read",
+			Handler:    _Rex_Read_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Rex_Delete_Handler,
+		},
+		{
+			MethodName: "GetQuota",
+			Handler:    _Rex_GetQuota_Handler,
+		},
+		{
+			MethodName: "CreateSchedule",
+			Handler:    _Rex_CreateSchedule_Handler,
+		},
+		{
+			MethodName: "ListSchedules",
+			Handler:    _Rex_ListSchedules_Handler,
+		},
+		{
+			MethodName: "DeleteSchedule",
+			Handler:    _Rex_DeleteSchedule_Handler,
+		},
+		{
+			MethodName: "PauseSchedule",
+			Handler:    _Rex_PauseSchedule_Handler,
+		},
+		{
+			MethodName: "GetServiceInfo",
+			Handler:    _Rex_GetServiceInfo_Handler,
+		},
+		{
+			MethodName: "WaitFor",
+			Handler:    _Rex_WaitFor_Handler,
+		},
+		{
+			MethodName: "SubmitWorkflow",
+			Handler:    _Rex_SubmitWorkflow_Handler,
+		},
+		{
+			MethodName: "GetWorkflow",
+			Handler:    _Rex_GetWorkflow_Handler,
+		},
+		{
+			MethodName: "ListWorkflows",
+			Handler:    _Rex_ListWorkflows_Handler,
+		},
+		{
+			MethodName: "CancelWorkflow",
+			Handler:    _Rex_CancelWorkflow_Handler,
+		},
+		{
+			MethodName: "GetGroupInfo",
+			Handler:    _Rex_GetGroupInfo_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _Rex_ListGroups_Handler,
+		},
+		{
+			MethodName: "KillGroup",
+			Handler:    _Rex_KillGroup_Handler,
+		},
+		{
+			MethodName: "WaitGroup",
+			Handler:    _Rex_WaitGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _Rex_DeleteGroup_Handler,
+		},
+		{
+			MethodName: "GetCapacity",
+			Handler:    _Rex_GetCapacity_Handler,
+		},
+		{
+			MethodName: "StatFile",
+			Handler:    _Rex_StatFile_Handler,
+		},
+		{
+			MethodName: "WhoAmI",
+			Handler:    _Rex_WhoAmI_Handler,
+		},
+		{
+			MethodName: "CheckAccess",
+			Handler:    _Rex_CheckAccess_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Rex_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Upload",
+			Handler:       _Rex_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _Rex_Download_Handler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "rex.proto",
 }
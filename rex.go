@@ -3,6 +3,8 @@ package rex
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,7 +23,11 @@ const (
 type rexContextKey string
 
 const (
-	userIDContextKey rexContextKey = "Rex-Context-UserID"
+	userIDContextKey    rexContextKey = "Rex-Context-UserID"
+	resourceContextKey  rexContextKey = "Rex-Context-Resource"
+	groupsContextKey    rexContextKey = "Rex-Context-Groups"
+	rolesContextKey     rexContextKey = "Rex-Context-Roles"
+	allEventsContextKey rexContextKey = "Rex-Context-AllEvents"
 )
 
 // Service defines the Rex interface within Go.
@@ -29,6 +35,10 @@ type Service interface {
 	// Exec executes a given executable with the supplied args.
 	Exec(ctx context.Context, path string, args ...string) (uuid.UUID, error)
 
+	// ExecCommand executes the process described by cmd. Exec is a shorthand
+	// for ExecCommand with only the path and args fields set.
+	ExecCommand(ctx context.Context, cmd Command) (uuid.UUID, error)
+
 	// ListProcessInfo returns a list containing ProcessInfo objects, one
 	// for each process previously Exec'd on this server.
 	ListProcessInfo(ctx context.Context) ([]ProcessInfo, error)
@@ -42,6 +52,986 @@ type Service interface {
 
 	// Read returns the content of the stdout or the stderr of a process
 	Read(ctx context.Context, processID uuid.UUID, target OutputStream) ([]byte, error)
+
+	// Delete removes a process that is not running from the system, along
+	// with its stored output. Queued processes are canceled.
+	Delete(ctx context.Context, processID uuid.UUID) error
+}
+
+// Watcher is implemented by services that are able to stream process
+// lifecycle events.
+type Watcher interface {
+	// Watch returns a channel on which the events matching the filter are
+	// delivered in the order in which they have happened, leaving out the
+	// ones of the processes that the caller may not act on, unless ctx is
+	// made by WithAllEvents. The channel is
+	// closed when ctx is done or when the watcher falls too far behind, in
+	// which case the caller can resume using the Seq of the last event it has
+	// received.
+	Watch(ctx context.Context, filter EventFilter) (<-chan Event, error)
+}
+
+// QuotaReporter is implemented by services that limit the resources that
+// each principal can consume.
+type QuotaReporter interface {
+	// GetQuota returns the limits that apply to the calling principal along
+	// with its current usage.
+	GetQuota(ctx context.Context) (Quota, ResourceUsage, error)
+}
+
+// CapacityReporter is implemented by services that are able to report how
+// busy they are, allowing the clients to spread their processes over
+// several equivalent services.
+type CapacityReporter interface {
+	// GetCapacity returns the current load of the service along with its
+	// limits.
+	GetCapacity(ctx context.Context) (Capacity, error)
+}
+
+// IdentityReporter is implemented by services that are able to tell who they
+// take the caller for.
+type IdentityReporter interface {
+	// WhoAmI returns the identity of the calling principal.
+	WhoAmI(ctx context.Context) (Identity, error)
+}
+
+// AccessChecker is implemented by services that are able to tell whether a
+// call would be allowed, and why.
+type AccessChecker interface {
+	// CheckAccess evaluates the access policies for a hypothetical call,
+	// without making it.
+	CheckAccess(ctx context.Context, check AccessCheck) (AccessDecision, error)
+}
+
+// Scheduler is implemented by services that are able to create processes
+// on a recurring schedule.
+type Scheduler interface {
+	// CreateSchedule creates a schedule owned by the caller from the Cron,
+	// TimeZone, Command and Overlap fields of spec.
+	CreateSchedule(ctx context.Context, spec Schedule) (Schedule, error)
+
+	// ListSchedules returns the schedules of the caller.
+	ListSchedules(ctx context.Context) ([]Schedule, error)
+
+	// DeleteSchedule removes a schedule. The processes that it has already
+	// created are left untouched.
+	DeleteSchedule(ctx context.Context, scheduleID uuid.UUID) error
+
+	// PauseSchedule pauses or resumes a schedule.
+	PauseSchedule(ctx context.Context, scheduleID uuid.UUID, paused bool) (Schedule, error)
+}
+
+// Supervisor is implemented by services that are able to keep processes
+// alive by restarting them.
+type Supervisor interface {
+	// GetServiceInfo returns the state of a supervised service along with
+	// its incarnations.
+	GetServiceInfo(ctx context.Context, serviceID uuid.UUID) (ServiceInfo, error)
+}
+
+// WorkflowRunner is implemented by services that are able to run
+// workflows, i.e. processes with dependencies between them.
+type WorkflowRunner interface {
+	// SubmitWorkflow validates the steps of spec and starts running them on
+	// behalf of the caller. The returned workflow carries the assigned ID.
+	SubmitWorkflow(ctx context.Context, spec Workflow) (Workflow, error)
+
+	// GetWorkflow returns the state of a workflow along with its steps.
+	GetWorkflow(ctx context.Context, workflowID uuid.UUID) (Workflow, error)
+
+	// ListWorkflows returns the workflows of the caller.
+	ListWorkflows(ctx context.Context) ([]Workflow, error)
+
+	// CancelWorkflow stops a workflow, killing its running steps and
+	// canceling those that have not started yet.
+	CancelWorkflow(ctx context.Context, workflowID uuid.UUID) (Workflow, error)
+}
+
+// GroupManager is implemented by services that are able to treat the
+// processes of a group, i.e. those created with the same Command.GroupID, as
+// a single unit. A group belongs to the principal that has created its first
+// process, and only that principal can add processes to it.
+type GroupManager interface {
+	// GetGroupInfo returns the aggregate status of a group along with its
+	// processes.
+	GetGroupInfo(ctx context.Context, groupID uuid.UUID) (GroupInfo, error)
+
+	// ListGroups returns the groups of the caller.
+	ListGroups(ctx context.Context) ([]GroupInfo, error)
+
+	// KillGroup sends a signal to all of the processes of a group, canceling
+	// the queued ones.
+	KillGroup(ctx context.Context, groupID uuid.UUID, signal int) error
+
+	// WaitGroup blocks until all or any (as specified by mode) of the
+	// processes of a group reach their final state, or ctx is done, in
+	// which case ctx.Err() is returned.
+	WaitGroup(ctx context.Context, groupID uuid.UUID, mode GroupWaitMode) (GroupInfo, error)
+
+	// DeleteGroup removes all of the processes of a group along with their
+	// output. Fails without removing anything if any of them is running.
+	DeleteGroup(ctx context.Context, groupID uuid.UUID) error
+}
+
+// OutputWaiter is implemented by services that are able to wait for a
+// process to print something.
+type OutputWaiter interface {
+	// WaitFor blocks until a line of the target output of a process
+	// matches the regular expression pattern, the process reaches its final
+	// state without printing such a line, or ctx is done, in which case
+	// ctx.Err() is returned.
+	WaitFor(ctx context.Context, processID uuid.UUID, target OutputStream, pattern string) (OutputMatch, error)
+}
+
+// FileStore is implemented by services that are able to keep files in
+// workspaces, either of a process or of a principal, allowing the clients to
+// provide the inputs of the processes and to retrieve what they produce.
+type FileStore interface {
+	// Upload writes the content read from r to a file, starting at offset,
+	// which must be either zero, replacing the file, or its current size,
+	// resuming an interrupted upload. If checksum is not empty, it must be
+	// the hex encoded SHA-256 of the whole file after the upload, otherwise
+	// the file is removed.
+	Upload(ctx context.Context, file FileRef, offset int64, checksum string, r io.Reader) (FileInfo, error)
+
+	// Download returns the info of a file along with its content starting
+	// at offset. The caller must close the returned reader.
+	Download(ctx context.Context, file FileRef, offset int64) (FileInfo, io.ReadCloser, error)
+
+	// StatFile returns the info of a file.
+	StatFile(ctx context.Context, file FileRef) (FileInfo, error)
+}
+
+// FileRef identifies a file in a workspace.
+type FileRef struct {
+	// ProcessID selects the workspace of a process. uuid.Nil selects the
+	// workspace of the caller.
+	ProcessID uuid.UUID
+	// Path is relative to the workspace.
+	Path string
+}
+
+// FileInfo describes a file in a workspace.
+type FileInfo struct {
+	Path string
+	Size int64
+	// SHA256 is the hex encoded SHA-256 checksum of the content.
+	SHA256   string
+	Modified time.Time
+}
+
+// OutputMatch is the outcome of OutputWaiter.WaitFor.
+type OutputMatch struct {
+	// Matched is false if the process has reached its final state without
+	// printing a matching line.
+	Matched bool
+	// Line is the matching line without its line terminator.
+	Line string
+	// Offset is the position of the first byte of Line in the output.
+	Offset int64
+	// Process is the state of the process when the wait ended.
+	Process ProcessInfo
+}
+
+// RestartPolicy configures the supervision of a process. The process is
+// restarted when it exits, as specified by Mode, waiting Backoff before the
+// first restart and doubling the wait after each one up to MaxBackoff.
+type RestartPolicy struct {
+	// Mode specifies when the process is restarted.
+	Mode RestartMode
+	// MaxRestarts limits the number of restarts. Zero means no limit.
+	MaxRestarts int
+	// Backoff is the wait before the first restart.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between restarts.
+	MaxBackoff time.Duration
+}
+
+// RestartMode specifies when a supervised process is restarted.
+type RestartMode int
+
+const (
+	// RestartNever never restarts the process.
+	RestartNever RestartMode = iota
+	// RestartOnFailure restarts the process if it exits with a non-zero
+	// exit code, is killed by a signal, or fails to start.
+	RestartOnFailure
+	// RestartAlways restarts the process whenever it exits.
+	RestartAlways
+)
+
+var restartModeNames = map[RestartMode]string{
+	RestartNever:     "never",
+	RestartOnFailure: "on-failure",
+	RestartAlways:    "always",
+}
+
+func (m RestartMode) String() string {
+	if name, ok := restartModeNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (m RestartMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *RestartMode) UnmarshalText(text []byte) error {
+	parsed, err := ParseRestartMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ParseRestartMode returns the RestartMode with the given name.
+func ParseRestartMode(name string) (RestartMode, error) {
+	for mode, modeName := range restartModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return RestartNever, fmt.Errorf("unknown restart mode %q", name)
+}
+
+// ServiceInfo describes a supervised service.
+type ServiceInfo struct {
+	// ID is the stable identifier of the service, which is returned by
+	// ExecCommand and stays the same across restarts.
+	ID uuid.UUID
+	// OwnerID is the unique identifier of the owner of the service.
+	OwnerID uuid.UUID
+	// Command is the command that every incarnation is created from.
+	Command Command
+	// State specifies what the service is doing.
+	State ServiceState
+	// Restarts is the number of times the service has been restarted.
+	Restarts int
+	// NextRestart is the point in time at which the service will be
+	// restarted. It is only defined if State=ServiceRestarting.
+	NextRestart time.Time
+	// Incarnations are the processes that the service has created, oldest
+	// first.
+	Incarnations []ProcessInfo
+}
+
+// ServiceState specifies what a supervised service is doing.
+type ServiceState int
+
+const (
+	// ServiceRunning means that the current incarnation is either running
+	// or queued.
+	ServiceRunning ServiceState = iota + 1
+	// ServiceRestarting means that the service is waiting to be restarted.
+	ServiceRestarting
+	// ServiceStopped means that the service has been stopped through Kill.
+	ServiceStopped
+	// ServiceFinished means that the last incarnation has exited and its
+	// restart policy does not allow another one.
+	ServiceFinished
+)
+
+var serviceStateNames = map[ServiceState]string{
+	ServiceRunning:    "running",
+	ServiceRestarting: "restarting",
+	ServiceStopped:    "stopped",
+	ServiceFinished:   "finished",
+}
+
+func (s ServiceState) String() string {
+	if name, ok := serviceStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s ServiceState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Probe periodically checks the health of a running process. The process is
+// healthy once a check succeeds, and unhealthy after FailureThreshold
+// consecutive failures. Until the first check its health is unknown, which
+// makes a probe usable as a readiness check too.
+type Probe struct {
+	// Name identifies the probe among the probes of a process. Defaults to
+	// the name of its type.
+	Name string
+	// Type specifies how the check is done.
+	Type ProbeType
+	// Command is the executable and the args that are executed by
+	// ProbeExec, in the same environment as the process. The check succeeds
+	// if it exits with code 0.
+	Command []string
+	// Port is the local port that ProbeTCP connects to or ProbeHTTP sends
+	// its request to.
+	Port int
+	// Path is the path requested by ProbeHTTP. Defaults to "/".
+	Path string
+	// Interval is the wait between consecutive checks, which is also the
+	// wait before the first check. Defaults to 10 seconds.
+	Interval time.Duration
+	// Timeout limits the duration of each check. Defaults to 1 second.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failures after which
+	// the process is unhealthy. Defaults to 1.
+	FailureThreshold int
+	// RestartAfter, if positive, kills the process after that many
+	// consecutive failures so that it is restarted as allowed by the restart
+	// policy of its command. Only allowed for supervised services.
+	RestartAfter int
+}
+
+// ProbeType specifies how a Probe checks a process.
+type ProbeType int
+
+const (
+	// ProbeExec executes a command.
+	ProbeExec ProbeType = iota + 1
+	// ProbeTCP opens a TCP connection to a local port.
+	ProbeTCP
+	// ProbeHTTP sends a GET request to a local port and expects a 2xx or
+	// 3xx status.
+	ProbeHTTP
+)
+
+var probeTypeNames = map[ProbeType]string{
+	ProbeExec: "exec",
+	ProbeTCP:  "tcp",
+	ProbeHTTP: "http",
+}
+
+func (t ProbeType) String() string {
+	if name, ok := probeTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t ProbeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (t *ProbeType) UnmarshalText(text []byte) error {
+	parsed, err := ParseProbeType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseProbeType returns the ProbeType with the given name.
+func ParseProbeType(name string) (ProbeType, error) {
+	for probeType, typeName := range probeTypeNames {
+		if typeName == name {
+			return probeType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown probe type %q", name)
+}
+
+// HealthState is the health of a process as determined by its probes.
+type HealthState int
+
+const (
+	// HealthUnknown means that the process has no probes or has not been
+	// checked yet.
+	HealthUnknown HealthState = iota
+	// HealthHealthy means that the last checks have succeeded.
+	HealthHealthy
+	// HealthUnhealthy means that a probe has reached its failure threshold.
+	HealthUnhealthy
+)
+
+var healthStateNames = map[HealthState]string{
+	HealthUnknown:   "unknown",
+	HealthHealthy:   "healthy",
+	HealthUnhealthy: "unhealthy",
+}
+
+func (h HealthState) String() string {
+	if name, ok := healthStateNames[h]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (h HealthState) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// ProbeStatus is the outcome of the checks of a Probe.
+type ProbeStatus struct {
+	// Name is the name of the probe.
+	Name string
+	// Health is the health of the process according to this probe.
+	Health HealthState
+	// ConsecutiveFailures is the number of failed checks since the last
+	// successful one.
+	ConsecutiveFailures int
+	// LastCheck is the point in time (UTC) at which the last check was done.
+	LastCheck time.Time
+	// LastMessage describes the outcome of the last check, e.g. the output
+	// of the command or the status of the HTTP response.
+	LastMessage string
+}
+
+// GroupInfo is the aggregate status of the processes of a group.
+type GroupInfo struct {
+	// ID is the unique identifier of the group.
+	ID uuid.UUID
+	// OwnerID is the unique identifier of the owner of the group.
+	OwnerID uuid.UUID
+	// State is the aggregate state of the processes.
+	State GroupState
+	// Queued is the number of the processes waiting in the queue.
+	Queued int
+	// Running is the number of the running processes.
+	Running int
+	// Succeeded is the number of the processes that have exited with a zero
+	// exit code.
+	Succeeded int
+	// Failed is the number of the processes that have reached their final
+	// state otherwise.
+	Failed int
+	// Processes are the processes of the group sorted by their creation
+	// time (oldest first).
+	Processes []ProcessInfo
+}
+
+// GroupState is the aggregate state of the processes of a group.
+type GroupState int
+
+const (
+	// GroupRunning means that some of the processes are queued or running.
+	GroupRunning GroupState = iota + 1
+	// GroupSucceeded means that all of the processes have exited with a zero
+	// exit code.
+	GroupSucceeded
+	// GroupFailed means that all of the processes have reached their final
+	// state and some of them have not succeeded.
+	GroupFailed
+)
+
+var groupStateNames = map[GroupState]string{
+	GroupRunning:   "running",
+	GroupSucceeded: "succeeded",
+	GroupFailed:    "failed",
+}
+
+func (s GroupState) String() string {
+	if name, ok := groupStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s GroupState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// GroupWaitMode specifies when GroupManager.WaitGroup returns.
+type GroupWaitMode int
+
+const (
+	// GroupWaitAll waits for all of the processes of the group.
+	GroupWaitAll GroupWaitMode = iota
+	// GroupWaitAny waits for any of the processes of the group.
+	GroupWaitAny
+)
+
+var groupWaitModeNames = map[GroupWaitMode]string{
+	GroupWaitAll: "all",
+	GroupWaitAny: "any",
+}
+
+func (m GroupWaitMode) String() string {
+	if name, ok := groupWaitModeNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseGroupWaitMode returns the GroupWaitMode with the given name.
+func ParseGroupWaitMode(name string) (GroupWaitMode, error) {
+	for mode, modeName := range groupWaitModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return GroupWaitAll, fmt.Errorf("unknown wait mode %q", name)
+}
+
+// Workflow is a set of steps, each of which is a process that is created
+// once the steps that it depends on have finished.
+type Workflow struct {
+	// ID is the unique identifier of the workflow.
+	ID uuid.UUID
+	// OwnerID is the unique identifier of the owner of the workflow, on
+	// behalf of whom the processes are created.
+	OwnerID uuid.UUID
+	// Steps are the steps of the workflow in the order in which they were
+	// submitted.
+	Steps []WorkflowStep
+	// State is the aggregate status of the steps.
+	State WorkflowState
+	// Create is the point in time (UTC) at which the workflow was submitted.
+	Create time.Time
+	// Finish is the point in time (UTC) at which the last step finished. It
+	// is undefined while State=WorkflowRunning.
+	Finish time.Time
+}
+
+// WorkflowStep is a single process of a workflow.
+type WorkflowStep struct {
+	// Name identifies the step within its workflow.
+	Name string
+	// Command is the process that is created for the step. Supervised
+	// services are not allowed.
+	Command Command
+	// DependsOn lists the names of the steps that have to finish before this
+	// one is considered.
+	DependsOn []string
+	// Condition decides whether the step runs once its dependencies have
+	// finished. Steps that do not run are skipped.
+	Condition StepCondition
+
+	// State specifies what the step is doing.
+	State StepState
+	// ProcessID is the process created for the step, or uuid.Nil.
+	ProcessID uuid.UUID
+	// ExitCode is the exit code of the process of the step. It is only
+	// defined if the process has exited.
+	ExitCode int
+	// Error is the reason why the process of the step could not be created
+	// or followed, if any.
+	Error string
+}
+
+// StepCondition decides whether a workflow step runs, based on the outcome
+// of the steps that it depends on.
+type StepCondition int
+
+const (
+	// StepOnSuccess runs the step if all of its dependencies have either
+	// succeeded or been skipped.
+	StepOnSuccess StepCondition = iota
+	// StepOnFailure runs the step if any of its dependencies has failed.
+	StepOnFailure
+	// StepAlways runs the step regardless of its dependencies.
+	StepAlways
+)
+
+var stepConditionNames = map[StepCondition]string{
+	StepOnSuccess: "on-success",
+	StepOnFailure: "on-failure",
+	StepAlways:    "always",
+}
+
+func (c StepCondition) String() string {
+	if name, ok := stepConditionNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (c StepCondition) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (c *StepCondition) UnmarshalText(text []byte) error {
+	parsed, err := ParseStepCondition(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// ParseStepCondition returns the StepCondition with the given name.
+func ParseStepCondition(name string) (StepCondition, error) {
+	for condition, conditionName := range stepConditionNames {
+		if conditionName == name {
+			return condition, nil
+		}
+	}
+	return StepOnSuccess, fmt.Errorf("unknown step condition %q", name)
+}
+
+// StepState specifies what a workflow step is doing.
+type StepState int
+
+const (
+	// StepPending means that the step is waiting for its dependencies.
+	StepPending StepState = iota + 1
+	// StepRunning means that the process of the step is either running or
+	// queued.
+	StepRunning
+	// StepSucceeded means that the process of the step has exited with code
+	// 0.
+	StepSucceeded
+	// StepFailed means that the process of the step has not exited with code
+	// 0, or could not be created.
+	StepFailed
+	// StepSkipped means that the condition of the step did not hold.
+	StepSkipped
+	// StepCanceled means that the workflow was canceled before the step
+	// finished.
+	StepCanceled
+)
+
+var stepStateNames = map[StepState]string{
+	StepPending:   "pending",
+	StepRunning:   "running",
+	StepSucceeded: "succeeded",
+	StepFailed:    "failed",
+	StepSkipped:   "skipped",
+	StepCanceled:  "canceled",
+}
+
+func (s StepState) String() string {
+	if name, ok := stepStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s StepState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// WorkflowState is the aggregate status of the steps of a workflow.
+type WorkflowState int
+
+const (
+	// WorkflowRunning means that some of the steps have not finished yet.
+	WorkflowRunning WorkflowState = iota + 1
+	// WorkflowSucceeded means that all of the steps have either succeeded or
+	// been skipped.
+	WorkflowSucceeded
+	// WorkflowFailed means that all of the steps have finished and some of
+	// them have failed.
+	WorkflowFailed
+	// WorkflowCanceled means that the workflow was canceled.
+	WorkflowCanceled
+)
+
+var workflowStateNames = map[WorkflowState]string{
+	WorkflowRunning:   "running",
+	WorkflowSucceeded: "succeeded",
+	WorkflowFailed:    "failed",
+	WorkflowCanceled:  "canceled",
+}
+
+func (s WorkflowState) String() string {
+	if name, ok := workflowStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s WorkflowState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Schedule describes a command that is executed whenever its cron
+// expression matches.
+type Schedule struct {
+	// ID is the unique identifier of the schedule.
+	ID uuid.UUID
+	// OwnerID is the unique identifier of the owner of the schedule, on
+	// behalf of whom the processes are created.
+	OwnerID uuid.UUID
+	// Cron is a standard 5-field cron expression (minute, hour, day of
+	// month, month, day of week), or one of @yearly, @monthly, @weekly,
+	// @daily and @hourly.
+	Cron string
+	// TimeZone is the IANA name of the time zone in which Cron is
+	// interpreted. Defaults to UTC.
+	TimeZone string
+	// Command is the process that is created on each firing.
+	Command Command
+	// Overlap specifies what happens if the previous process of the
+	// schedule is still running when the schedule fires.
+	Overlap OverlapPolicy
+	// Paused schedules do not fire.
+	Paused bool
+	// Create is the point in time (UTC) at which the schedule was created.
+	Create time.Time
+	// LastRun is the point in time at which the schedule last fired.
+	LastRun time.Time
+	// NextRun is the point in time at which the schedule fires next.
+	NextRun time.Time
+	// LastProcessID is the process that the schedule has created last.
+	LastProcessID uuid.UUID
+	// LastError is the reason why the last firing did not create a process,
+	// if any.
+	LastError string
+}
+
+// OverlapPolicy specifies what a schedule does if its previous process is
+// still running when it fires.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow creates a new process regardless of the previous one.
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip skips the firing.
+	OverlapSkip
+	// OverlapReplace kills the previous process and creates a new one.
+	OverlapReplace
+)
+
+var overlapPolicyNames = map[OverlapPolicy]string{
+	OverlapAllow:   "allow",
+	OverlapSkip:    "skip",
+	OverlapReplace: "replace",
+}
+
+func (p OverlapPolicy) String() string {
+	if name, ok := overlapPolicyNames[p]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p OverlapPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *OverlapPolicy) UnmarshalText(text []byte) error {
+	parsed, err := ParseOverlapPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// ParseOverlapPolicy returns the OverlapPolicy with the given name.
+func ParseOverlapPolicy(name string) (OverlapPolicy, error) {
+	for policy, policyName := range overlapPolicyNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+	return OverlapAllow, fmt.Errorf("unknown overlap policy %q", name)
+}
+
+// Quota limits the resources that a principal can consume. Zero values mean
+// no limit.
+type Quota struct {
+	// MaxRunning limits the number of processes that are either running or
+	// waiting in the queue.
+	MaxRunning int `validate:"gte=0"`
+	// MaxPerHour limits the number of processes created in the last hour.
+	MaxPerHour int `validate:"gte=0"`
+	// MaxOutputBytes limits the total size of the stored stdout and stderr
+	// of all of the processes. Output beyond the limit is discarded.
+	MaxOutputBytes int64 `validate:"gte=0"`
+	// MaxCPUSecondsPerDay limits the total CPU time (user and system) of the
+	// processes that have exited in the last 24 hours.
+	MaxCPUSecondsPerDay float64 `validate:"gte=0"`
+}
+
+// ResourceUsage describes the resources that a principal is consuming, in
+// the same terms as Quota.
+type ResourceUsage struct {
+	// Running is the number of the running processes.
+	Running int
+	// Queued is the number of processes waiting in the queue, which count
+	// towards MaxRunning along with the running ones.
+	Queued int
+	// CreatedLastHour is the number of processes created in the last hour.
+	CreatedLastHour int
+	// OutputBytes is the total size of the stored stdout and stderr.
+	OutputBytes int64
+	// CPUSecondsLastDay is the total CPU time of the processes that have
+	// exited in the last 24 hours.
+	CPUSecondsLastDay float64
+}
+
+// GroupPrincipalPrefix marks the principals of the policies, the role
+// bindings and the quotas that stand for the members of a group, e.g.
+// group:ops
+const GroupPrincipalPrefix = "group:"
+
+// Identity is a principal along with the groups and the roles that it has.
+type Identity struct {
+	UserID string
+	Groups []string
+	Roles  []string
+}
+
+// AccessCheck describes a hypothetical call.
+type AccessCheck struct {
+	// Method is the full name of the method, e.g. /Rex/Exec.
+	Method string
+	// Command is the command of the call, e.g. for /Rex/Exec.
+	Command *Command
+	// ProcessID is the process that the call acts on, if any.
+	ProcessID uuid.UUID
+	// Signal is the signal of the call, e.g. for /Rex/Kill.
+	Signal int
+	// Principal is who the call is checked for, if not the caller. Groups
+	// and Roles are added to the ones that the service finds for it.
+	Principal string
+	Groups    []string
+	Roles     []string
+}
+
+// AccessDecision is how the access policies decide on a call.
+type AccessDecision struct {
+	Allowed bool
+	// Rules lists the rules that have decided, from the outermost policy
+	// set inwards.
+	Rules  []string
+	Reason string
+	// Identity is who the call has been checked for.
+	Identity Identity
+}
+
+// Capacity describes the load of a service.
+type Capacity struct {
+	// Running is the number of the running processes.
+	Running int
+	// Queued is the number of the processes waiting in the queue.
+	Queued int
+	// MaxConcurrent limits the number of running processes. Zero means no
+	// limit.
+	MaxConcurrent int
+}
+
+// Load returns the number of the processes that are either running or
+// waiting in the queue.
+func (c Capacity) Load() int {
+	return c.Running + c.Queued
+}
+
+// LoadOf returns the number of the processes of svc that are either running
+// or waiting in the queue. It relies on GetCapacity if svc implements
+// CapacityReporter, and falls back to counting the processes otherwise.
+func LoadOf(ctx context.Context, svc Service) (int, error) {
+	if reporter, ok := svc.(CapacityReporter); ok {
+		// Servers predating GetCapacity fail the call
+		if capacity, err := reporter.GetCapacity(ctx); err == nil {
+			return capacity.Load(), nil
+		}
+	}
+	infoList, err := svc.ListProcessInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	active := 0
+	for _, info := range infoList {
+		if info.State == ProcessQueued || info.State == ProcessRunning {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// Command describes a process that is to be created through
+// Service.ExecCommand.
+type Command struct {
+	// Path is the address to the executable.
+	Path string
+	// Args is the list of the command line arguments that will be passed to
+	// the process.
+	Args []string
+	// Labels are arbitrary key/value pairs attached to the process, which can
+	// later be used to filter processes and their events.
+	Labels map[string]string
+	// Callbacks is a list of URLs that will be notified when the process
+	// exits.
+	Callbacks []string
+	// Priority orders the process among the other queued processes. Higher
+	// priorities are started first.
+	Priority int
+	// ScheduleID links the process to the schedule that has created it, if
+	// any. Only set by Scheduler implementations.
+	ScheduleID uuid.UUID
+	// WorkflowID links the process to the workflow that has created it, if
+	// any. Only set by WorkflowRunner implementations.
+	WorkflowID uuid.UUID
+	// GroupID, if not uuid.Nil, adds the process to the group with the given
+	// ID, creating the group if it does not exist. Supervised services
+	// cannot be added to groups.
+	GroupID uuid.UUID
+	// Restart, if not nil, makes the command a supervised service which is
+	// restarted as specified by the policy. The ID returned by ExecCommand is
+	// then the ID of the service, which can be used in place of the ID of its
+	// current incarnation in the other calls. Kill stops the service.
+	Restart *RestartPolicy
+	// Probes check the health of the process while it is running.
+	Probes []Probe
+	// Pipeline, if not empty, lists further processes which are connected to
+	// Path in a pipeline: the stdout of each process is the stdin of the
+	// next one, and the stored stdout is that of the last process. The
+	// stderr of all of them is stored. No shell is involved.
+	Pipeline []PipelineStage
+	// Node, if not empty, is the name of the node to create the process on
+	// when the service fronts several nodes. Ignored otherwise.
+	Node string
+	// NodeSelector restricts the nodes that the process can be created on
+	// to those having all of the given labels when the service fronts
+	// several nodes. Ignored otherwise.
+	NodeSelector map[string]string
+	// PrivateWorkDir runs the process in a fresh working directory of its
+	// own, which is removed once the process exits.
+	PrivateWorkDir bool
+	// Artifacts lists the glob patterns, relative to the private working
+	// directory, of the files to be kept once the process exits. They are
+	// moved to the workspace of the process (see FileStore) and removed
+	// along with the process. A pattern matching a directory keeps all of
+	// its files. Requires PrivateWorkDir.
+	Artifacts []string
+	// Dir, if not empty, is the absolute path to the working directory of
+	// the process. Cannot be combined with PrivateWorkDir.
+	Dir string
+	// Env holds environment variables that are set for the process on top
+	// of those of the server.
+	Env map[string]string
+}
+
+// PipelineStage is a process of a pipeline other than the first one.
+type PipelineStage struct {
+	// Path is the address to the executable.
+	Path string
+	// Args is the list of the command line arguments that will be passed to
+	// the process.
+	Args []string
+}
+
+// StageInfo describes a process of a pipeline.
+type StageInfo struct {
+	// Path is the address to the executable.
+	Path string
+	// Args is the list of the command line arguments of the process.
+	Args []string
+	// PID is the pid of the process in the OS. Zero if it has not started.
+	PID int
+	// Exited specifies whether the process has exited.
+	Exited bool
+	// ExitCode is the exit code of the process. It is only defined if
+	// Exited=true.
+	ExitCode int
 }
 
 // ProcessInfo contains various informations about a process.
@@ -56,6 +1046,14 @@ type ProcessInfo struct {
 	ExitCode int
 	// Running specifies whether or not the process is currently running.
 	Running bool
+	// State specifies the stage of its lifecycle that the process is in.
+	State ProcessState
+	// QueuePosition is the 1-based position of the process in the queue of
+	// the processes waiting to be started. It is undefined unless
+	// State=ProcessQueued.
+	QueuePosition int
+	// Priority is the priority of the process in the queue.
+	Priority int
 	// Path is the address to the executable corresponding to the process.
 	Path string
 	// Args is the list of the command line arguments that were passed to the
@@ -65,9 +1063,169 @@ type ProcessInfo struct {
 	OwnerID uuid.UUID
 	// Create is the point in time (UTC) at which the process was created.
 	Create time.Time
+	// Start is the point in time (UTC) at which the process was started. It
+	// is undefined if the process has never left the queue.
+	Start time.Time
 	// Exit is the point in time (UTC) at which the process exited. It is
 	// undefined if Running=true.
 	Exit time.Time
+	// Labels are the key/value pairs that were attached to the process upon
+	// creation.
+	Labels map[string]string
+	// Callbacks is the list of URLs that will be notified when the process
+	// exits.
+	Callbacks []string
+	// ScheduleID is the schedule that has created the process, or uuid.Nil.
+	ScheduleID uuid.UUID
+	// WorkflowID is the workflow that has created the process, or uuid.Nil.
+	WorkflowID uuid.UUID
+	// GroupID is the group of the process, or uuid.Nil.
+	GroupID uuid.UUID
+	// ServiceID is the supervised service that the process is an
+	// incarnation of, or uuid.Nil.
+	ServiceID uuid.UUID
+	// Incarnation is the 1-based number of the incarnation of the service.
+	// It is only defined if ServiceID is not uuid.Nil.
+	Incarnation int
+	// Health is the overall health of the process: unhealthy if any of its
+	// probes says so, healthy if all of them do, and unknown otherwise.
+	Health HealthState
+	// Probes holds the outcome of the checks of each of the probes of the
+	// process.
+	Probes []ProbeStatus
+	// Stages describes each of the processes of a pipeline, similar to
+	// PIPESTATUS in bash. It is only set for pipelines, in which case PID is
+	// that of the first stage, and ExitCode is that of the last one.
+	Stages []StageInfo
+	// Node is the name of the node that the process runs on if the service
+	// fronts several nodes, and empty otherwise.
+	Node string
+	// Artifacts lists the paths of the files collected from the private
+	// working directory of the process into its workspace once it exits.
+	Artifacts []string
+}
+
+// ProcessState specifies the stage of its lifecycle that a process is in.
+type ProcessState int
+
+const (
+	// ProcessQueued means that the process is waiting to be started.
+	ProcessQueued ProcessState = iota + 1
+	// ProcessRunning means that the process has been started and has not yet
+	// exited.
+	ProcessRunning
+	// ProcessExited means that the process has been started and has exited.
+	ProcessExited
+	// ProcessCanceled means that the process was canceled before leaving the
+	// queue.
+	ProcessCanceled
+	// ProcessFailed means that the process could not be started after
+	// leaving the queue.
+	ProcessFailed
+)
+
+var processStateNames = map[ProcessState]string{
+	ProcessQueued:   "queued",
+	ProcessRunning:  "running",
+	ProcessExited:   "exited",
+	ProcessCanceled: "canceled",
+	ProcessFailed:   "failed",
+}
+
+func (s ProcessState) String() string {
+	if name, ok := processStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s ProcessState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// EventType specifies what has happened to a process in an Event.
+type EventType int
+
+const (
+	// EventCreated is emitted when a process is registered in the system.
+	EventCreated EventType = iota + 1
+	// EventStarted is emitted when the OS process has been started.
+	EventStarted
+	// EventExited is emitted when a process exits, either normally or as
+	// the result of a signal.
+	EventExited
+	// EventSignaled is emitted when a signal is delivered to a process
+	// through Kill.
+	EventSignaled
+	// EventDeleted is emitted when a process is removed from the system.
+	EventDeleted
+	// EventOutputTruncated is emitted when the stored stdout/stderr of a
+	// process stops growing because it has reached its size limit.
+	EventOutputTruncated
+	// EventHealthChanged is emitted when the health of a process changes
+	// as the result of its probes.
+	EventHealthChanged
+)
+
+var eventTypeNames = map[EventType]string{
+	EventCreated:         "created",
+	EventStarted:         "started",
+	EventExited:          "exited",
+	EventSignaled:        "signaled",
+	EventDeleted:         "deleted",
+	EventOutputTruncated: "output-truncated",
+	EventHealthChanged:   "health-changed",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Event describes a single change in the lifecycle of a process.
+type Event struct {
+	// Seq is the sequence number of the event. Sequence numbers are strictly
+	// increasing in the order in which the events have happened.
+	Seq uint64
+	// Type specifies what has happened to the process.
+	Type EventType
+	// Time is the point in time (UTC) at which the event has happened.
+	Time time.Time
+	// Process is the state of the affected process right after the event.
+	Process ProcessInfo
+	// Signal is the signal that was delivered to the process. It is only
+	// defined if Type=EventSignaled.
+	Signal int
+}
+
+// EventFilter restricts the events that are delivered by Watcher.Watch.
+type EventFilter struct {
+	// OwnerID, if not uuid.Nil, only matches the processes of the given owner.
+	OwnerID uuid.UUID
+	// Labels only matches the processes having all of the given labels.
+	Labels map[string]string
+	// AfterSeq only matches the events with a sequence number greater than
+	// AfterSeq. Used to resume watching after a reconnect.
+	AfterSeq uint64
+}
+
+// Match returns true if the event passes the filter.
+func (f *EventFilter) Match(e *Event) bool {
+	if e.Seq <= f.AfterSeq {
+		return false
+	}
+	if f.OwnerID != uuid.Nil && f.OwnerID != e.Process.OwnerID {
+		return false
+	}
+	for key, value := range f.Labels {
+		if actual, ok := e.Process.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
 }
 
 var (
@@ -92,6 +1250,21 @@ var (
 
 	// ErrInvalidArgument is when an invalid arugment is given to a function
 	ErrInvalidArgument = errors.New("not found")
+
+	// ErrProcessRunning is returned when an operation requires a process to
+	// not be running.
+	ErrProcessRunning = errors.New("process is running")
+
+	// ErrResourceExhausted is returned when a request would exceed a quota.
+	// It is wrapped with the description of the quota that is exceeded.
+	ErrResourceExhausted = errors.New("resource exhausted")
+
+	// ErrNotRunning is returned when a signal is sent to a process that has
+	// never been started.
+	ErrNotRunning = errors.New("process has not been started")
+
+	// ErrUnavailable is returned when a service cannot be reached.
+	ErrUnavailable = errors.New("unavailable")
 )
 
 // UserIDFromContext gets the unique identifier of the API user. Returns
@@ -106,3 +1279,71 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, userIDContextKey, userID)
 }
+
+// Resource holds the attributes of the process that a call acts on, which
+// authorization decisions can take into account.
+type Resource struct {
+	// ProcessID is the ID of the process, or of the service.
+	ProcessID uuid.UUID
+	// OwnerID is the ID of the user who has created the process.
+	OwnerID string
+	// Labels are the labels of the process.
+	Labels map[string]string
+	// GroupID is the ID of the group of the process, if any.
+	GroupID uuid.UUID
+}
+
+// ResourceFromContext gets the process that a call acts on. Returns false as
+// the second argument if no such key is found in the context.
+func ResourceFromContext(ctx context.Context) (Resource, bool) {
+	val, ok := ctx.Value(resourceContextKey).(Resource)
+	return val, ok
+}
+
+// WithResource adds the supplied resource to the given context and returns
+// the resulting context.
+func WithResource(ctx context.Context, resource Resource) context.Context {
+	return context.WithValue(ctx, resourceContextKey, resource)
+}
+
+// GroupsFromContext gets the groups that the API user is a member of.
+// Returns false as the second argument if no such key is found in the
+// context.
+func GroupsFromContext(ctx context.Context) ([]string, bool) {
+	val, ok := ctx.Value(groupsContextKey).([]string)
+	return val, ok
+}
+
+// WithGroups adds the supplied groups of the user to the given context and
+// returns the resulting context.
+func WithGroups(ctx context.Context, groups []string) context.Context {
+	return context.WithValue(ctx, groupsContextKey, groups)
+}
+
+// RolesFromContext gets the roles of the API user. Returns false as the
+// second argument if no such key is found in the context.
+func RolesFromContext(ctx context.Context) ([]string, bool) {
+	val, ok := ctx.Value(rolesContextKey).([]string)
+	return val, ok
+}
+
+// WithRoles adds the supplied roles of the user to the given context and
+// returns the resulting context.
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesContextKey, roles)
+}
+
+// AllEventsFromContext reports whether Watcher.Watch should deliver the
+// events of all of the processes.
+func AllEventsFromContext(ctx context.Context) bool {
+	val, _ := ctx.Value(allEventsContextKey).(bool)
+	return val
+}
+
+// WithAllEvents makes Watcher.Watch deliver the events of all of the
+// processes, regardless of whether the user may act on them. It is meant
+// for the components that run within the server, such as the webhook
+// notifier, and never set on the contexts of the API calls.
+func WithAllEvents(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allEventsContextKey, true)
+}
@@ -0,0 +1,205 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of values that a field of a cron expression matches
+type cronField uint64
+
+func (f cronField) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+type fieldBounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteBounds = fieldBounds{name: "minute", min: 0, max: 59}
+	hourBounds   = fieldBounds{name: "hour", min: 0, max: 23}
+	domBounds    = fieldBounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = fieldBounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is an alias for Sunday
+	dowBounds = fieldBounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSpec is a parsed cron expression
+type cronSpec struct {
+	minute, hour, dom, month, dow cronField
+	// Following cron, if both day fields are restricted a day matches if
+	// either of them matches.
+	domRestricted, dowRestricted bool
+	location                     *time.Location
+}
+
+// parseCron parses a 5-field cron expression which is interpreted in the
+// given time zone. Each field is a comma separated list of "*", values, or
+// ranges ("a-b"), optionally followed by a step ("*/15", "1-10/2"). Months
+// and days of week can also be given by their three-letter English names.
+func parseCron(expr, timeZone string) (*cronSpec, error) {
+	location := time.UTC
+	if timeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	if macro, ok := cronMacros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	spec := &cronSpec{location: location}
+	for i, target := range []struct {
+		field  *cronField
+		bounds fieldBounds
+	}{
+		{&spec.minute, minuteBounds},
+		{&spec.hour, hourBounds},
+		{&spec.dom, domBounds},
+		{&spec.month, monthBounds},
+		{&spec.dow, dowBounds},
+	} {
+		parsed, err := parseCronField(fields[i], target.bounds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		*target.field = parsed
+	}
+	if spec.dow.has(7) {
+		spec.dow |= 1
+	}
+	spec.domRestricted = !strings.HasPrefix(fields[2], "*")
+	spec.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return spec, nil
+}
+
+func parseCronField(field string, bounds fieldBounds) (cronField, error) {
+	var ret cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", bounds.name, part)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseCronValue(ends[0], bounds); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(ends) == 2 {
+				if high, err = parseCronValue(ends[1], bounds); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "a/step" means "a-max/step"
+				high = bounds.max
+			}
+		}
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s field %q out of range [%d, %d]", bounds.name, part, bounds.min, bounds.max)
+		}
+
+		for value := low; value <= high; value += step {
+			ret |= 1 << uint(value)
+		}
+	}
+	return ret, nil
+}
+
+func parseCronValue(value string, bounds fieldBounds) (int, error) {
+	if named, ok := bounds.names[strings.ToLower(value)]; ok {
+		return named, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", bounds.name, value)
+	}
+	return parsed, nil
+}
+
+// next returns the first point in time strictly after t that matches the
+// spec, or the zero time if there is none within the next few years (e.g.
+// "0 0 30 2 *").
+func (s *cronSpec) next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+	// Each loop advances the corresponding field until it matches, starting
+	// over from the month whenever a field wraps around.
+wrap:
+	for t.Year() <= yearLimit {
+		for !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			if t.Month() == time.January {
+				continue wrap
+			}
+		}
+		for !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			if t.Day() == 1 {
+				continue wrap
+			}
+		}
+		for !s.hour.has(t.Hour()) {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			if !next.After(t) {
+				// Hour repeated by a daylight saving time transition
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+			if t.Hour() == 0 {
+				continue wrap
+			}
+		}
+		for !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			if t.Minute() == 0 {
+				continue wrap
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSpec) dayMatches(t time.Time) bool {
+	domMatches := s.dom.has(t.Day())
+	dowMatches := s.dow.has(int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return domMatches || dowMatches
+	}
+	return domMatches && dowMatches
+}
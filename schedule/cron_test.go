@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	from := time.Date(2020, time.October, 30, 22, 17, 30, 0, time.UTC) // A Friday
+	for _, tc := range []struct {
+		expr     string
+		timeZone string
+		expected time.Time
+	}{
+		{"* * * * *", "", time.Date(2020, time.October, 30, 22, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", "", time.Date(2020, time.October, 30, 22, 30, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", "", time.Date(2020, time.November, 2, 9, 0, 0, 0, time.UTC)},
+		{"30 1 1,15 * *", "", time.Date(2020, time.November, 1, 1, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", "", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 13 * 5", "", time.Date(2020, time.November, 6, 0, 0, 0, 0, time.UTC)},
+		{"@daily", "", time.Date(2020, time.October, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", "Asia/Tehran", time.Date(2020, time.November, 1, 0, 0, 0, 0, mustLoadLocation(t, "Asia/Tehran"))},
+		// 2:30 does not exist on the day that daylight saving time starts
+		{"30 2 * * *", "America/New_York", time.Date(2021, time.March, 15, 2, 30, 0, 0, mustLoadLocation(t, "America/New_York"))},
+		{"0 0 30 2 *", "", time.Time{}},
+	} {
+		spec, err := parseCron(tc.expr, tc.timeZone)
+		if err != nil {
+			t.Errorf("Caught error while parsing %q: %v", tc.expr, err)
+			continue
+		}
+		start := from
+		if tc.timeZone == "America/New_York" {
+			start = time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC)
+		}
+		if actual := spec.next(start); !actual.Equal(tc.expected) {
+			t.Errorf("Expected %q to next match at %v, got %v", tc.expr, tc.expected, actual)
+		}
+	}
+}
+
+func TestCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := parseCron(expr, ""); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+	if _, err := parseCron("* * * * *", "Nowhere/Special"); err == nil {
+		t.Errorf("Expected an error for an unknown time zone")
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("Failed to load time zone %q: %v", name, err)
+	}
+	return location
+}
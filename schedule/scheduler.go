@@ -0,0 +1,306 @@
+// Package schedule creates processes on recurring schedules.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// Scheduler implements rex.Scheduler on top of a rex.Service. The schedules
+// are persisted to a file so that they survive restarts, however the
+// firings that are missed while the Scheduler is not running are skipped.
+type Scheduler struct {
+	svc       rex.Service
+	stateFile string
+	now       func() time.Time
+
+	m         sync.Mutex
+	schedules map[uuid.UUID]*entry
+}
+
+type entry struct {
+	schedule rex.Schedule
+	spec     *cronSpec
+}
+
+// NewScheduler creates a Scheduler which creates the processes through svc
+// and persists the schedules to stateFile, loading the existing ones if the
+// file exists.
+func NewScheduler(svc rex.Service, stateFile string) (*Scheduler, error) {
+	s := &Scheduler{
+		svc:       svc,
+		stateFile: stateFile,
+		now:       time.Now,
+		schedules: make(map[uuid.UUID]*entry),
+	}
+
+	content, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var schedules []rex.Schedule
+	if err := json.Unmarshal(content, &schedules); err != nil {
+		return nil, fmt.Errorf("malformed schedules file %q: %w", stateFile, err)
+	}
+	now := s.now()
+	for _, schedule := range schedules {
+		spec, err := parseCron(schedule.Cron, schedule.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %v: %w", schedule.ID, err)
+		}
+		schedule.NextRun = spec.next(now)
+		s.schedules[schedule.ID] = &entry{schedule: schedule, spec: spec}
+	}
+	return s, nil
+}
+
+// CreateSchedule creates a schedule owned by the caller
+func (s *Scheduler) CreateSchedule(ctx context.Context, spec rex.Schedule) (rex.Schedule, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return rex.Schedule{}, rex.ErrUnauthenticated
+	}
+	ownerID, err := uuid.Parse(userID)
+	if err != nil {
+		return rex.Schedule{}, err
+	}
+	if spec.Command.Path == "" {
+		return rex.Schedule{}, errors.New("missing executable path")
+	}
+	cronSpec, err := parseCron(spec.Cron, spec.TimeZone)
+	if err != nil {
+		return rex.Schedule{}, err
+	}
+
+	now := s.now()
+	schedule := rex.Schedule{
+		ID:       uuid.New(),
+		OwnerID:  ownerID,
+		Cron:     spec.Cron,
+		TimeZone: spec.TimeZone,
+		Command:  spec.Command,
+		Overlap:  spec.Overlap,
+		Paused:   spec.Paused,
+		Create:   now.UTC(),
+		NextRun:  cronSpec.next(now),
+	}
+	if schedule.NextRun.IsZero() {
+		return rex.Schedule{}, fmt.Errorf("cron expression %q never matches", spec.Cron)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.schedules[schedule.ID] = &entry{schedule: schedule, spec: cronSpec}
+	if err := s.persistLocked(); err != nil {
+		delete(s.schedules, schedule.ID)
+		return rex.Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// ListSchedules returns the schedules of the caller sorted by their creation
+// time (newest first)
+func (s *Scheduler) ListSchedules(ctx context.Context) ([]rex.Schedule, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var schedules []rex.Schedule
+	for _, entry := range s.schedules {
+		if entry.schedule.OwnerID.String() == userID {
+			schedules = append(schedules, entry.schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Create.After(schedules[j].Create)
+	})
+	return schedules, nil
+}
+
+// DeleteSchedule removes a schedule of the caller
+func (s *Scheduler) DeleteSchedule(ctx context.Context, scheduleID uuid.UUID) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entry, err := s.getLocked(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	delete(s.schedules, scheduleID)
+	if err := s.persistLocked(); err != nil {
+		s.schedules[scheduleID] = entry
+		return err
+	}
+	return nil
+}
+
+// PauseSchedule pauses or resumes a schedule of the caller
+func (s *Scheduler) PauseSchedule(ctx context.Context, scheduleID uuid.UUID, paused bool) (rex.Schedule, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entry, err := s.getLocked(ctx, scheduleID)
+	if err != nil {
+		return rex.Schedule{}, err
+	}
+	previous := entry.schedule
+	entry.schedule.Paused = paused
+	if !paused {
+		entry.schedule.NextRun = entry.spec.next(s.now())
+	}
+	if err := s.persistLocked(); err != nil {
+		entry.schedule = previous
+		return rex.Schedule{}, err
+	}
+	return entry.schedule, nil
+}
+
+// Run fires the schedules as they become due until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.fireDue(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fireDue fires the schedules that are due and waits for them to create
+// their processes.
+func (s *Scheduler) fireDue(ctx context.Context) {
+	now := s.now()
+	var due []rex.Schedule
+
+	s.m.Lock()
+	for _, entry := range s.schedules {
+		schedule := &entry.schedule
+		if schedule.Paused || schedule.NextRun.IsZero() || schedule.NextRun.After(now) {
+			continue
+		}
+		due = append(due, *schedule)
+		schedule.LastRun = now.UTC()
+		schedule.NextRun = entry.spec.next(now)
+	}
+	s.m.Unlock()
+
+	var wg sync.WaitGroup
+	for _, schedule := range due {
+		wg.Add(1)
+		go func(schedule rex.Schedule) {
+			defer wg.Done()
+			processID, err := s.fire(ctx, schedule)
+			s.recordFiring(schedule.ID, processID, err)
+		}(schedule)
+	}
+	wg.Wait()
+}
+
+// fire creates a process for schedule on behalf of its owner, subject to its
+// overlap policy.
+func (s *Scheduler) fire(ctx context.Context, schedule rex.Schedule) (uuid.UUID, error) {
+	ownerCtx := rex.WithUserID(ctx, schedule.OwnerID.String())
+
+	if schedule.Overlap != rex.OverlapAllow && schedule.LastProcessID != uuid.Nil {
+		info, err := s.svc.GetProcessInfo(ownerCtx, schedule.LastProcessID)
+		active := err == nil &&
+			(info.State == rex.ProcessQueued || info.State == rex.ProcessRunning)
+		if active && schedule.Overlap == rex.OverlapSkip {
+			return uuid.Nil, fmt.Errorf("skipped: process %v is still running", schedule.LastProcessID)
+		}
+		if active && schedule.Overlap == rex.OverlapReplace {
+			err := s.svc.Kill(ownerCtx, schedule.LastProcessID, int(syscall.SIGTERM))
+			if err != nil && !errors.Is(err, rex.ErrNotRunning) {
+				log.Warnf("Failed to replace process %v of schedule %v: %v",
+					schedule.LastProcessID, schedule.ID, err)
+			}
+		}
+	}
+
+	command := schedule.Command
+	command.ScheduleID = schedule.ID
+	return s.svc.ExecCommand(ownerCtx, command)
+}
+
+func (s *Scheduler) recordFiring(scheduleID, processID uuid.UUID, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entry, ok := s.schedules[scheduleID]
+	if !ok {
+		// Deleted in the meantime
+		return
+	}
+	if err != nil {
+		log.Infof("Schedule %v did not create a process: %v", scheduleID, err)
+		entry.schedule.LastError = err.Error()
+	} else {
+		entry.schedule.LastError = ""
+		entry.schedule.LastProcessID = processID
+	}
+	if err := s.persistLocked(); err != nil {
+		log.Errorf("Failed to persist the schedules: %v", err)
+	}
+}
+
+// getLocked returns a schedule of the caller. Requires the caller to hold s.m
+func (s *Scheduler) getLocked(ctx context.Context, scheduleID uuid.UUID) (*entry, error) {
+	entry, ok := s.schedules[scheduleID]
+	if !ok {
+		return nil, rex.ErrNotFound
+	}
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+	if entry.schedule.OwnerID.String() != userID {
+		return nil, rex.ErrAccessDenied
+	}
+	return entry, nil
+}
+
+// persistLocked atomically replaces the state file with the current
+// schedules. Requires the caller to hold s.m
+func (s *Scheduler) persistLocked() error {
+	schedules := make([]rex.Schedule, 0, len(s.schedules))
+	for _, entry := range s.schedules {
+		schedules = append(schedules, entry.schedule)
+	}
+	content, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.stateFile), 0755); err != nil {
+		return err
+	}
+	tmpFile := s.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.stateFile)
+}
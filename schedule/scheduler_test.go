@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func newTestScheduler(t *testing.T, stateFile string) *Scheduler {
+	s, err := NewScheduler(localexec.NewServer(os.TempDir()), stateFile)
+	if err != nil {
+		t.Fatalf("While creating the scheduler: %v", err)
+	}
+	return s
+}
+
+func tempStateFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "rex-schedule")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	return filepath.Join(dir, "schedules.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestScheduler_FireAndOverlap(t *testing.T) {
+	stateFile, cleanup := tempStateFile(t)
+	defer cleanup()
+	s := newTestScheduler(t, stateFile)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	schedule, err := s.CreateSchedule(ctx, rex.Schedule{
+		Cron:    "* * * * *",
+		Command: rex.Command{Path: "sleep", Args: []string{"10"}},
+		Overlap: rex.OverlapSkip,
+	})
+	if err != nil {
+		t.Fatalf("While calling CreateSchedule: %v", err)
+	}
+
+	now := schedule.NextRun
+	s.now = func() time.Time { return now }
+	s.fireDue(ctx)
+	schedules, err := s.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("While calling ListSchedules: %v", err)
+	}
+	first := schedules[0].LastProcessID
+	info, err := s.svc.GetProcessInfo(ctx, first)
+	if err != nil {
+		t.Fatalf("While calling GetProcessInfo: %v", err)
+	}
+	if info.ScheduleID != schedule.ID {
+		t.Errorf("Expected the process to be linked to schedule %v, got %v", schedule.ID, info.ScheduleID)
+	}
+	defer s.svc.Kill(ctx, first, int(syscall.SIGKILL))
+
+	now = now.Add(time.Minute)
+	s.fireDue(ctx)
+	schedules, _ = s.ListSchedules(ctx)
+	if schedules[0].LastProcessID != first || schedules[0].LastError == "" {
+		t.Errorf("Expected the firing to be skipped while %v is running, got %+v", first, schedules[0])
+	}
+}
+
+func TestScheduler_PersistAndPause(t *testing.T) {
+	stateFile, cleanup := tempStateFile(t)
+	defer cleanup()
+	s := newTestScheduler(t, stateFile)
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	schedule, err := s.CreateSchedule(ctx, rex.Schedule{
+		Cron:     "0 9 * * mon",
+		TimeZone: "Europe/Amsterdam",
+		Command:  rex.Command{Path: "true"},
+	})
+	if err != nil {
+		t.Fatalf("While calling CreateSchedule: %v", err)
+	}
+	if _, err := s.PauseSchedule(ctx, schedule.ID, true); err != nil {
+		t.Fatalf("While calling PauseSchedule: %v", err)
+	}
+
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	if err := s.DeleteSchedule(otherCtx, schedule.ID); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+
+	restarted := newTestScheduler(t, stateFile)
+	schedules, err := restarted.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("While calling ListSchedules: %v", err)
+	}
+	if len(schedules) != 1 || schedules[0].ID != schedule.ID || !schedules[0].Paused {
+		t.Fatalf("Expected the paused schedule to be restored, got %+v", schedules)
+	}
+
+	restarted.now = func() time.Time { return schedules[0].NextRun.Add(time.Minute) }
+	restarted.fireDue(ctx)
+	if schedules, _ := restarted.ListSchedules(ctx); !schedules[0].LastRun.IsZero() {
+		t.Errorf("Expected a paused schedule to not fire")
+	}
+
+	if err := restarted.DeleteSchedule(ctx, schedule.ID); err != nil {
+		t.Fatalf("While calling DeleteSchedule: %v", err)
+	}
+	if schedules, _ := newTestScheduler(t, stateFile).ListSchedules(ctx); len(schedules) != 0 {
+		t.Errorf("Expected the deletion to be persisted, got %+v", schedules)
+	}
+}
@@ -0,0 +1,10 @@
+#!/bin/bash
+
+CERTS_DIR=certs/proxy
+mkdir -p "$CERTS_DIR"
+
+proxy_uuid=$(uuid)
+
+openssl genrsa -out "$CERTS_DIR/$proxy_uuid.key" 2048
+openssl req -new -key "$CERTS_DIR/$proxy_uuid.key" -out $proxy_uuid.csr -subj "/C=CA/ST=ON/O=UofT/OU=CS/CN=$proxy_uuid"
+openssl x509 -req -in $proxy_uuid.csr -CA ca.crt -CAkey ca.key -CAcreateserial -out "$CERTS_DIR/$proxy_uuid.pem" -days 30 -extfile openssl.cnf -extensions proxy_cert
@@ -0,0 +1,340 @@
+// Package webhook notifies HTTP endpoints about the processes that exit.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body
+	// in the form "sha256=<hex>" if the notifier is configured with a secret.
+	SignatureHeader = "X-Rex-Signature"
+	// EventHeader carries the type of the event that caused the notification.
+	EventHeader = "X-Rex-Event"
+	// DeliveryHeader carries a unique ID which stays the same across the
+	// retries of a single delivery.
+	DeliveryHeader = "X-Rex-Delivery"
+
+	// notifierUserID identifies the notifier itself when it watches the
+	// process events.
+	notifierUserID = "rexd-webhook-notifier"
+)
+
+var (
+	// ErrCallbackNotAllowed is returned when a process requests a callback
+	// URL that is not in the allowlist of the notifier.
+	ErrCallbackNotAllowed = errors.New("callback url not allowed")
+)
+
+// Config configures the Notifier.
+type Config struct {
+	// Endpoints are notified about every process that exits.
+	Endpoints []string
+	// Allowlist is the list of URL prefixes that processes are allowed to
+	// request as callbacks. A callback is allowed if its scheme and host are
+	// equal to those of an entry, and its path, once cleaned, is the path of
+	// the entry or lies under it, segment by segment. The notifications do
+	// not follow redirects, which could lead outside the allowlist.
+	Allowlist []string
+	// Secret, if not empty, is used to sign the payloads. See SignatureHeader.
+	Secret []byte
+	// MaxAttempts is the number of times a delivery is attempted before
+	// giving up.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, which is doubled after
+	// each subsequent failure.
+	Backoff time.Duration
+	// TailSize is the maximum number of trailing bytes of stdout and stderr
+	// that are included in the payload.
+	TailSize int
+	// Timeout limits each HTTP request.
+	Timeout time.Duration
+}
+
+// DefaultConfig returns a Config with sensible retry and payload settings
+// and no endpoints.
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		TailSize:    4096,
+		Timeout:     10 * time.Second,
+	}
+}
+
+// Payload is the JSON document that is POSTed to the endpoints.
+type Payload struct {
+	ProcessUUID string            `json:"processUUID"`
+	OwnerUUID   string            `json:"ownerUUID"`
+	Path        string            `json:"path"`
+	Args        []string          `json:"args"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	ExitCode    int               `json:"exitCode"`
+	Create      time.Time         `json:"create"`
+	// Start is missing if the process has never left the queue.
+	Start *time.Time `json:"start,omitempty"`
+	Exit  time.Time  `json:"exit"`
+	// QueueSeconds is the time between the creation and the start of the
+	// process, or its exit if it has never started, and DurationSeconds the
+	// time between its start and its exit.
+	QueueSeconds    float64 `json:"queueSeconds"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	StdoutTail      string  `json:"stdoutTail"`
+	StderrTail      string  `json:"stderrTail"`
+}
+
+// Notifier watches a rex.Service for exited processes and POSTs a Payload
+// to the configured endpoints and to the callbacks of the process.
+type Notifier struct {
+	svc       rex.Service
+	config    Config
+	allowlist []*url.URL
+	client    *http.Client
+}
+
+// NewNotifier creates a Notifier on top of svc, which must also implement
+// rex.Watcher.
+func NewNotifier(svc rex.Service, config Config) (*Notifier, error) {
+	if _, ok := svc.(rex.Watcher); !ok {
+		return nil, rex.ErrNotImplemented
+	}
+
+	var allowlist []*url.URL
+	for _, entry := range config.Allowlist {
+		parsed, err := parseHTTPURL(entry)
+		if err != nil {
+			return nil, fmt.Errorf("allowlist entry %q: %w", entry, err)
+		}
+		allowlist = append(allowlist, parsed)
+	}
+	for _, endpoint := range config.Endpoints {
+		if _, err := parseHTTPURL(endpoint); err != nil {
+			return nil, fmt.Errorf("endpoint %q: %w", endpoint, err)
+		}
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+
+	return &Notifier{
+		svc:       svc,
+		config:    config,
+		allowlist: allowlist,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// ValidateCommand rejects commands with callbacks that are not allowed by
+// the allowlist. Meant to be used with localexec.WithCommandValidator.
+func (n *Notifier) ValidateCommand(ctx context.Context, cmd rex.Command) error {
+	for _, callback := range cmd.Callbacks {
+		if !n.allowed(callback) {
+			return fmt.Errorf("%w: %s", ErrCallbackNotAllowed, callback)
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) allowed(callback string) bool {
+	parsed, err := parseHTTPURL(callback)
+	if err != nil {
+		return false
+	}
+	for _, entry := range n.allowlist {
+		if parsed.Scheme == entry.Scheme && parsed.Host == entry.Host &&
+			pathWithin(parsed.Path, entry.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathWithin reports whether the URL path p is prefix or lies under it once
+// both are cleaned, so that /hooks covers /hooks/a but neither /hooks-evil
+// nor /hooks/../admin
+func pathWithin(p, prefix string) bool {
+	p, prefix = path.Clean("/"+p), path.Clean("/"+prefix)
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// Run watches the process events and sends the notifications until ctx is
+// done.
+func (n *Notifier) Run(ctx context.Context) error {
+	watchCtx := rex.WithAllEvents(rex.WithUserID(ctx, notifierUserID))
+	filter := rex.EventFilter{}
+	for {
+		events, err := n.svc.(rex.Watcher).Watch(watchCtx, filter)
+		if err != nil {
+			return err
+		}
+		for event := range events {
+			filter.AfterSeq = event.Seq
+			if event.Type == rex.EventExited {
+				go n.notify(ctx, event)
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warnf("Webhook notifier fell behind, resuming after event %d", filter.AfterSeq)
+	}
+}
+
+func (n *Notifier) notify(ctx context.Context, event rex.Event) {
+	targets := append([]string(nil), n.config.Endpoints...)
+	for _, callback := range event.Process.Callbacks {
+		// The allowlist might have changed since the process was created.
+		if n.allowed(callback) {
+			targets = append(targets, callback)
+		} else {
+			log.Warnf("Skipping disallowed callback %q of process %v", callback, event.Process.ID)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(n.payload(ctx, event.Process))
+	if err != nil {
+		log.Errorf("Failed to marshal webhook payload: %v", err)
+		return
+	}
+	for _, target := range targets {
+		go n.deliver(ctx, target, event.Type.String(), body)
+	}
+}
+
+func (n *Notifier) payload(ctx context.Context, info rex.ProcessInfo) *Payload {
+	// Output is read on behalf of the owner of the process.
+	ownerCtx := rex.WithUserID(ctx, info.OwnerID.String())
+	payload := &Payload{
+		ProcessUUID:  info.ID.String(),
+		OwnerUUID:    info.OwnerID.String(),
+		Path:         info.Path,
+		Args:         info.Args,
+		Labels:       info.Labels,
+		ExitCode:     info.ExitCode,
+		Create:       info.Create,
+		Exit:         info.Exit,
+		QueueSeconds: info.Exit.Sub(info.Create).Seconds(),
+		StdoutTail:   n.tail(ownerCtx, info.ID, rex.StdoutStream),
+		StderrTail:   n.tail(ownerCtx, info.ID, rex.StderrStream),
+	}
+	if !info.Start.IsZero() {
+		start := info.Start
+		payload.Start = &start
+		payload.QueueSeconds = info.Start.Sub(info.Create).Seconds()
+		payload.DurationSeconds = info.Exit.Sub(info.Start).Seconds()
+	}
+	return payload
+}
+
+func (n *Notifier) tail(ctx context.Context, processID uuid.UUID, target rex.OutputStream) string {
+	content, err := n.svc.Read(ctx, processID, target)
+	if err != nil {
+		log.Warnf("Failed to read the output of process %v: %v", processID, err)
+		return ""
+	}
+	if len(content) > n.config.TailSize {
+		content = content[len(content)-n.config.TailSize:]
+	}
+	return string(content)
+}
+
+func (n *Notifier) deliver(ctx context.Context, target, eventType string, body []byte) {
+	deliveryID := uuid.New().String()
+	backoff := n.config.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, target, eventType, deliveryID, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == n.config.MaxAttempts {
+			log.Errorf("Giving up on webhook delivery %s to %s after %d attempts: %v",
+				deliveryID, target, attempt, err)
+			return
+		}
+		log.Warnf("Webhook delivery %s to %s failed (attempt %d): %v",
+			deliveryID, target, attempt, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single request and reports whether it is worth retrying if
+// it has failed.
+func (n *Notifier) post(ctx context.Context, target, eventType, deliveryID string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	if len(n.config.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.config.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Warnf("Failed to close webhook response body: %v", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// Sign returns the value of SignatureHeader for the given body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return nil, errors.New("missing host")
+	}
+	return parsed, nil
+}
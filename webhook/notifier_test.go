@@ -0,0 +1,218 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+	"github.com/farnasirim/rex/webhook"
+)
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func newRecorder(t *testing.T, statuses ...int) (*httptest.Server, <-chan delivery) {
+	deliveries := make(chan delivery, 16)
+	attempt := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+		}
+		status := http.StatusOK
+		if attempt < len(statuses) {
+			status = statuses[attempt]
+		}
+		attempt++
+		w.WriteHeader(status)
+		deliveries <- delivery{header: r.Header, body: body}
+	}))
+	return server, deliveries
+}
+
+func nextDelivery(t *testing.T, deliveries <-chan delivery) delivery {
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for a webhook delivery")
+	}
+	return delivery{}
+}
+
+func startNotifier(t *testing.T, config webhook.Config) (*localexec.ProcessServer, *webhook.Notifier, func()) {
+	var notifier *webhook.Notifier
+	s := localexec.NewServer(os.TempDir(), localexec.WithCommandValidator(
+		func(ctx context.Context, cmd rex.Command) error {
+			return notifier.ValidateCommand(ctx, cmd)
+		}))
+	notifier, err := webhook.NewNotifier(s, config)
+	if err != nil {
+		t.Fatalf("While creating the notifier: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = notifier.Run(ctx) }()
+	return s, notifier, cancel
+}
+
+func TestNotifier_CallbackPayload(t *testing.T) {
+	server, deliveries := newRecorder(t)
+	defer server.Close()
+
+	config := webhook.DefaultConfig()
+	config.Allowlist = []string{server.URL + "/hooks/"}
+	config.Secret = []byte("secret")
+	s, _, stop := startNotifier(t, config)
+	defer stop()
+
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	procID, err := s.ExecCommand(ctx, rex.Command{
+		Path:      "echo",
+		Args:      []string{"hello"},
+		Callbacks: []string{server.URL + "/hooks/team-a"},
+	})
+	if err != nil {
+		t.Fatalf("While calling ExecCommand: %v", err)
+	}
+
+	d := nextDelivery(t, deliveries)
+	if signature := d.header.Get(webhook.SignatureHeader); signature != webhook.Sign(config.Secret, d.body) {
+		t.Errorf("Expected a valid signature, got %q", signature)
+	}
+	var payload webhook.Payload
+	if err := json.Unmarshal(d.body, &payload); err != nil {
+		t.Fatalf("Failed to unmarshal the payload: %v", err)
+	}
+	if payload.ProcessUUID != procID.String() {
+		t.Errorf("Expected payload for %v, got %v", procID, payload.ProcessUUID)
+	}
+	if payload.StdoutTail != "hello\n" {
+		t.Errorf("Expected stdout tail %q, got %q", "hello\n", payload.StdoutTail)
+	}
+	if payload.Start == nil || payload.Start.Before(payload.Create) || payload.Exit.Before(*payload.Start) {
+		t.Fatalf("Expected the start between the creation and the exit, got %+v", payload)
+	}
+	if queue := payload.Start.Sub(payload.Create).Seconds(); math.Abs(payload.QueueSeconds-queue) > 1e-6 {
+		t.Errorf("Expected %gs in the queue, got %g", queue, payload.QueueSeconds)
+	}
+	if duration := payload.Exit.Sub(*payload.Start).Seconds(); math.Abs(payload.DurationSeconds-duration) > 1e-6 {
+		t.Errorf("Expected a duration of %gs from the start, got %g", duration, payload.DurationSeconds)
+	}
+}
+
+func TestNotifier_Retry(t *testing.T) {
+	server, deliveries := newRecorder(t, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	config := webhook.DefaultConfig()
+	config.Endpoints = []string{server.URL}
+	config.Backoff = 10 * time.Millisecond
+	s, _, stop := startNotifier(t, config)
+	defer stop()
+
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	if _, err := s.Exec(ctx, "true"); err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+
+	first := nextDelivery(t, deliveries)
+	second := nextDelivery(t, deliveries)
+	if first.header.Get(webhook.DeliveryHeader) != second.header.Get(webhook.DeliveryHeader) {
+		t.Errorf("Expected the retry to keep the delivery ID")
+	}
+}
+
+func TestNotifier_CallbackNotAllowed(t *testing.T) {
+	config := webhook.DefaultConfig()
+	config.Allowlist = []string{"https://hooks.example.com/rex/"}
+	s, _, stop := startNotifier(t, config)
+	defer stop()
+
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	for _, callback := range []string{
+		"https://hooks.example.com.evil.com/rex/",
+		"https://hooks.example.com/other",
+		"http://hooks.example.com/rex/",
+	} {
+		_, err := s.ExecCommand(ctx, rex.Command{Path: "true", Callbacks: []string{callback}})
+		if !errors.Is(err, webhook.ErrCallbackNotAllowed) {
+			t.Errorf("Expected %v for %q, got: %v", webhook.ErrCallbackNotAllowed, callback, err)
+		}
+	}
+}
+
+func TestNotifier_CallbackPaths(t *testing.T) {
+	config := webhook.DefaultConfig()
+	config.Allowlist = []string{"https://hooks.example.com/hooks"}
+	notifier, err := webhook.NewNotifier(localexec.NewServer(os.TempDir()), config)
+	if err != nil {
+		t.Fatalf("While creating the notifier: %v", err)
+	}
+
+	cases := []struct {
+		callback string
+		allowed  bool
+	}{
+		{"https://hooks.example.com/hooks", true},
+		{"https://hooks.example.com/hooks/team-a", true},
+		{"https://hooks.example.com/hooks/./team-a", true},
+		{"https://hooks.example.com/hooks-evil", false},
+		{"https://hooks.example.com/hooks/../admin", false},
+		{"https://hooks.example.com/hooks/%2e%2e/admin", false},
+		{"https://hooks.example.com/admin/../hooks", true},
+	}
+	for _, c := range cases {
+		err := notifier.ValidateCommand(context.Background(), rex.Command{Path: "true", Callbacks: []string{c.callback}})
+		if c.allowed && err != nil {
+			t.Errorf("Expected %q to be allowed, got: %v", c.callback, err)
+		}
+		if !c.allowed && !errors.Is(err, webhook.ErrCallbackNotAllowed) {
+			t.Errorf("Expected %v for %q, got: %v", webhook.ErrCallbackNotAllowed, c.callback, err)
+		}
+	}
+}
+
+func TestNotifier_NoRedirects(t *testing.T) {
+	outside, outsideDeliveries := newRecorder(t)
+	defer outside.Close()
+	redirected := make(chan struct{}, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected <- struct{}{}
+		http.Redirect(w, r, outside.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	config := webhook.DefaultConfig()
+	config.Endpoints = []string{server.URL}
+	config.MaxAttempts = 1
+	s, _, stop := startNotifier(t, config)
+	defer stop()
+
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+	if _, err := s.Exec(ctx, "true"); err != nil {
+		t.Fatalf("While calling Exec: %v", err)
+	}
+	select {
+	case <-redirected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for a webhook delivery")
+	}
+	select {
+	case <-outsideDeliveries:
+		t.Errorf("Expected the redirect not to be followed")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
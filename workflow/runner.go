@@ -0,0 +1,390 @@
+// Package workflow runs processes with dependencies between them.
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/farnasirim/rex"
+)
+
+// pollInterval is the wait between the checks of the running steps
+const pollInterval = 250 * time.Millisecond
+
+// Runner implements rex.WorkflowRunner on top of a rex.Service. The
+// workflows are kept in memory.
+type Runner struct {
+	svc rex.Service
+	now func() time.Time
+
+	m         sync.Mutex
+	workflows map[uuid.UUID]*run
+}
+
+// run is a workflow along with the index of its steps
+type run struct {
+	m        sync.Mutex
+	workflow rex.Workflow
+	steps    map[string]int
+}
+
+// NewRunner creates a Runner which creates the processes of the steps
+// through svc.
+func NewRunner(svc rex.Service) *Runner {
+	return &Runner{
+		svc:       svc,
+		now:       time.Now,
+		workflows: make(map[uuid.UUID]*run),
+	}
+}
+
+// SubmitWorkflow creates a workflow owned by the caller from the steps of
+// spec and starts the steps that do not depend on any other step.
+func (r *Runner) SubmitWorkflow(ctx context.Context, spec rex.Workflow) (rex.Workflow, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return rex.Workflow{}, rex.ErrUnauthenticated
+	}
+	ownerID, err := uuid.Parse(userID)
+	if err != nil {
+		return rex.Workflow{}, err
+	}
+	steps, err := validateSteps(spec.Steps)
+	if err != nil {
+		return rex.Workflow{}, err
+	}
+
+	run := &run{
+		workflow: rex.Workflow{
+			ID:      uuid.New(),
+			OwnerID: ownerID,
+			State:   rex.WorkflowRunning,
+			Create:  r.now().UTC(),
+		},
+		steps: steps,
+	}
+	for _, step := range spec.Steps {
+		run.workflow.Steps = append(run.workflow.Steps, rex.WorkflowStep{
+			Name:      step.Name,
+			Command:   step.Command,
+			DependsOn: append([]string(nil), step.DependsOn...),
+			Condition: step.Condition,
+			State:     rex.StepPending,
+		})
+	}
+
+	r.m.Lock()
+	r.workflows[run.workflow.ID] = run
+	r.m.Unlock()
+
+	run.m.Lock()
+	defer run.m.Unlock()
+	r.advanceLocked(ctx, run)
+	return run.snapshotLocked(), nil
+}
+
+// GetWorkflow returns a workflow of the caller
+func (r *Runner) GetWorkflow(ctx context.Context, workflowID uuid.UUID) (rex.Workflow, error) {
+	run, err := r.get(ctx, workflowID)
+	if err != nil {
+		return rex.Workflow{}, err
+	}
+	run.m.Lock()
+	defer run.m.Unlock()
+	return run.snapshotLocked(), nil
+}
+
+// ListWorkflows returns the workflows of the caller sorted by their
+// submission time (newest first)
+func (r *Runner) ListWorkflows(ctx context.Context) ([]rex.Workflow, error) {
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+
+	var workflows []rex.Workflow
+	for _, run := range r.runs() {
+		run.m.Lock()
+		if run.workflow.OwnerID.String() == userID {
+			workflows = append(workflows, run.snapshotLocked())
+		}
+		run.m.Unlock()
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].Create.After(workflows[j].Create)
+	})
+	return workflows, nil
+}
+
+// CancelWorkflow sends SIGTERM to the running steps of a workflow of the
+// caller and cancels the rest of its unfinished steps. Finished workflows are
+// left untouched.
+func (r *Runner) CancelWorkflow(ctx context.Context, workflowID uuid.UUID) (rex.Workflow, error) {
+	run, err := r.get(ctx, workflowID)
+	if err != nil {
+		return rex.Workflow{}, err
+	}
+	run.m.Lock()
+	defer run.m.Unlock()
+
+	workflow := &run.workflow
+	if workflow.State != rex.WorkflowRunning {
+		return run.snapshotLocked(), nil
+	}
+	ownerCtx := rex.WithUserID(ctx, workflow.OwnerID.String())
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		if step.State == rex.StepRunning {
+			err := r.svc.Kill(ownerCtx, step.ProcessID, int(syscall.SIGTERM))
+			if err != nil && !errors.Is(err, rex.ErrNotRunning) {
+				log.Warnf("Failed to kill process %v of workflow %v: %v", step.ProcessID, workflow.ID, err)
+			}
+		}
+		if step.State == rex.StepRunning || step.State == rex.StepPending {
+			step.State = rex.StepCanceled
+		}
+	}
+	workflow.State = rex.WorkflowCanceled
+	workflow.Finish = r.now().UTC()
+	return run.snapshotLocked(), nil
+}
+
+// Run advances the running workflows as their steps finish until ctx is
+// done.
+func (r *Runner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.advanceAll(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Runner) advanceAll(ctx context.Context) {
+	for _, run := range r.runs() {
+		run.m.Lock()
+		if run.workflow.State == rex.WorkflowRunning {
+			r.advanceLocked(ctx, run)
+		}
+		run.m.Unlock()
+	}
+}
+
+// advanceLocked records the outcome of the steps that have finished and
+// starts or skips the steps whose dependencies have finished. Requires the
+// caller to hold run.m
+func (r *Runner) advanceLocked(ctx context.Context, run *run) {
+	workflow := &run.workflow
+	ownerCtx := rex.WithUserID(ctx, workflow.OwnerID.String())
+
+	for i := range workflow.Steps {
+		if step := &workflow.Steps[i]; step.State == rex.StepRunning {
+			r.pollLocked(ownerCtx, step)
+		}
+	}
+
+	// Skipping a step might settle the steps that depend on it, hence the
+	// loop.
+	for changed := true; changed; {
+		changed = false
+		for i := range workflow.Steps {
+			step := &workflow.Steps[i]
+			if step.State != rex.StepPending {
+				continue
+			}
+			ready, shouldRun := run.evaluateLocked(step)
+			if !ready {
+				continue
+			}
+			changed = true
+			if !shouldRun {
+				step.State = rex.StepSkipped
+				continue
+			}
+
+			command := step.Command
+			command.WorkflowID = workflow.ID
+			processID, err := r.svc.ExecCommand(ownerCtx, command)
+			if err != nil {
+				step.State = rex.StepFailed
+				step.Error = err.Error()
+				continue
+			}
+			step.State = rex.StepRunning
+			step.ProcessID = processID
+		}
+	}
+
+	finished, failed := true, false
+	for _, step := range workflow.Steps {
+		finished = finished && (step.State != rex.StepPending && step.State != rex.StepRunning)
+		failed = failed || step.State == rex.StepFailed
+	}
+	if !finished {
+		return
+	}
+	workflow.State = rex.WorkflowSucceeded
+	if failed {
+		workflow.State = rex.WorkflowFailed
+	}
+	workflow.Finish = r.now().UTC()
+}
+
+// pollLocked records the outcome of the process of a running step if it has
+// finished. Requires the caller to hold the lock of the run of the step
+func (r *Runner) pollLocked(ctx context.Context, step *rex.WorkflowStep) {
+	info, err := r.svc.GetProcessInfo(ctx, step.ProcessID)
+	if err != nil {
+		step.State = rex.StepFailed
+		step.Error = err.Error()
+		return
+	}
+	switch info.State {
+	case rex.ProcessQueued, rex.ProcessRunning:
+		return
+	case rex.ProcessExited:
+		step.ExitCode = info.ExitCode
+		step.State = rex.StepSucceeded
+		if info.ExitCode != 0 {
+			step.State = rex.StepFailed
+		}
+	default:
+		step.ExitCode = info.ExitCode
+		step.State = rex.StepFailed
+		step.Error = fmt.Sprintf("process %v", info.State)
+	}
+}
+
+// evaluateLocked reports whether all of the dependencies of a step have
+// finished, and if so, whether the step should run. Requires the caller to
+// hold run.m
+func (run *run) evaluateLocked(step *rex.WorkflowStep) (ready, shouldRun bool) {
+	allSucceeded, anyFailed := true, false
+	for _, name := range step.DependsOn {
+		switch run.workflow.Steps[run.steps[name]].State {
+		case rex.StepPending, rex.StepRunning:
+			return false, false
+		case rex.StepFailed, rex.StepCanceled:
+			allSucceeded = false
+			anyFailed = true
+		}
+	}
+
+	switch step.Condition {
+	case rex.StepOnFailure:
+		return true, anyFailed
+	case rex.StepAlways:
+		return true, true
+	default:
+		return true, allSucceeded
+	}
+}
+
+// snapshotLocked returns a copy of the workflow. Requires the caller to
+// hold run.m
+func (run *run) snapshotLocked() rex.Workflow {
+	workflow := run.workflow
+	workflow.Steps = append([]rex.WorkflowStep(nil), run.workflow.Steps...)
+	return workflow
+}
+
+// get returns a workflow of the caller
+func (r *Runner) get(ctx context.Context, workflowID uuid.UUID) (*run, error) {
+	r.m.Lock()
+	run, ok := r.workflows[workflowID]
+	r.m.Unlock()
+	if !ok {
+		return nil, rex.ErrNotFound
+	}
+	userID, ok := rex.UserIDFromContext(ctx)
+	if !ok {
+		return nil, rex.ErrUnauthenticated
+	}
+	// OwnerID never changes, hence no need to lock run
+	if run.workflow.OwnerID.String() != userID {
+		return nil, rex.ErrAccessDenied
+	}
+	return run, nil
+}
+
+func (r *Runner) runs() []*run {
+	r.m.Lock()
+	defer r.m.Unlock()
+	runs := make([]*run, 0, len(r.workflows))
+	for _, run := range r.workflows {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// validateSteps checks that the steps have unique names, valid commands and
+// acyclic dependencies, and returns the index of each step by its name.
+func validateSteps(steps []rex.WorkflowStep) (map[string]int, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("workflow without steps")
+	}
+	index := make(map[string]int)
+	for i, step := range steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("step %d has no name", i+1)
+		}
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("duplicate step name %q", step.Name)
+		}
+		if step.Command.Path == "" {
+			return nil, fmt.Errorf("step %q: missing executable path", step.Name)
+		}
+		if step.Command.Restart != nil {
+			return nil, fmt.Errorf("step %q: steps cannot be supervised services", step.Name)
+		}
+		if step.Condition < rex.StepOnSuccess || step.Condition > rex.StepAlways {
+			return nil, fmt.Errorf("step %q: unknown condition %d", step.Name, step.Condition)
+		}
+		index[step.Name] = i
+	}
+
+	// Kahn's algorithm: a cycle leaves some of the steps with unresolved
+	// dependencies.
+	remaining := make([]int, len(steps))
+	dependents := make(map[string][]int)
+	for i, step := range steps {
+		for _, name := range step.DependsOn {
+			if _, ok := index[name]; !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", step.Name, name)
+			}
+			dependents[name] = append(dependents[name], i)
+		}
+		remaining[i] = len(step.DependsOn)
+	}
+	var resolved []int
+	for i := range steps {
+		if remaining[i] == 0 {
+			resolved = append(resolved, i)
+		}
+	}
+	for next := 0; next < len(resolved); next++ {
+		for _, dependent := range dependents[steps[resolved[next]].Name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				resolved = append(resolved, dependent)
+			}
+		}
+	}
+	if len(resolved) != len(steps) {
+		return nil, errors.New("the dependencies of the steps form a cycle")
+	}
+	return index, nil
+}
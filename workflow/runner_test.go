@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/farnasirim/rex"
+	"github.com/farnasirim/rex/localexec"
+)
+
+func waitForWorkflow(t *testing.T, r *Runner, ctx context.Context, workflowID uuid.UUID) rex.Workflow {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		r.advanceAll(ctx)
+		workflow, err := r.GetWorkflow(ctx, workflowID)
+		if err != nil {
+			t.Fatalf("While calling GetWorkflow: %v", err)
+		}
+		if workflow.State != rex.WorkflowRunning {
+			return workflow
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected workflow to finish, its steps are %+v", workflow.Steps)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunner_Conditions(t *testing.T) {
+	r := NewRunner(localexec.NewServer(os.TempDir()))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	workflow, err := r.SubmitWorkflow(ctx, rex.Workflow{Steps: []rex.WorkflowStep{
+		{Name: "build", Command: rex.Command{Path: "true"}},
+		{Name: "test", Command: rex.Command{Path: "false"}, DependsOn: []string{"build"}},
+		{Name: "deploy", Command: rex.Command{Path: "true"}, DependsOn: []string{"test"}},
+		{Name: "report", Command: rex.Command{Path: "true"}, DependsOn: []string{"test"},
+			Condition: rex.StepOnFailure},
+		{Name: "cleanup", Command: rex.Command{Path: "true"}, DependsOn: []string{"deploy", "report"},
+			Condition: rex.StepAlways},
+	}})
+	if err != nil {
+		t.Fatalf("While calling SubmitWorkflow: %v", err)
+	}
+	if workflow.Steps[0].State != rex.StepRunning || workflow.Steps[1].State != rex.StepPending {
+		t.Errorf("Expected only the first step to be started upon submission, got %+v", workflow.Steps)
+	}
+
+	workflow = waitForWorkflow(t, r, ctx, workflow.ID)
+	if workflow.State != rex.WorkflowFailed {
+		t.Errorf("Expected workflow to fail, it is %v", workflow.State)
+	}
+	expected := []rex.StepState{rex.StepSucceeded, rex.StepFailed, rex.StepSkipped, rex.StepSucceeded, rex.StepSucceeded}
+	for i, step := range workflow.Steps {
+		if step.State != expected[i] {
+			t.Errorf("Expected step %q to be %v, it is %v", step.Name, expected[i], step.State)
+		}
+	}
+
+	info, err := r.svc.GetProcessInfo(ctx, workflow.Steps[4].ProcessID)
+	if err != nil {
+		t.Fatalf("While calling GetProcessInfo: %v", err)
+	}
+	if info.WorkflowID != workflow.ID {
+		t.Errorf("Expected the process to be linked to workflow %v, got %v", workflow.ID, info.WorkflowID)
+	}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	r := NewRunner(localexec.NewServer(os.TempDir()))
+	ctx := rex.WithUserID(context.Background(), uuid.New().String())
+
+	workflow, err := r.SubmitWorkflow(ctx, rex.Workflow{Steps: []rex.WorkflowStep{
+		{Name: "sleep", Command: rex.Command{Path: "sleep", Args: []string{"10"}}},
+		{Name: "after", Command: rex.Command{Path: "true"}, DependsOn: []string{"sleep"},
+			Condition: rex.StepAlways},
+	}})
+	if err != nil {
+		t.Fatalf("While calling SubmitWorkflow: %v", err)
+	}
+
+	otherCtx := rex.WithUserID(context.Background(), uuid.New().String())
+	if _, err := r.CancelWorkflow(otherCtx, workflow.ID); err != rex.ErrAccessDenied {
+		t.Errorf("Expected error %v, actual: %v", rex.ErrAccessDenied, err)
+	}
+	workflow, err = r.CancelWorkflow(ctx, workflow.ID)
+	if err != nil {
+		t.Fatalf("While calling CancelWorkflow: %v", err)
+	}
+	if workflow.State != rex.WorkflowCanceled ||
+		workflow.Steps[0].State != rex.StepCanceled || workflow.Steps[1].State != rex.StepCanceled {
+		t.Errorf("Expected the workflow and its steps to be canceled, got %+v", workflow)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, err := r.svc.GetProcessInfo(ctx, workflow.Steps[0].ProcessID)
+		if err != nil {
+			t.Fatalf("While calling GetProcessInfo: %v", err)
+		}
+		if info.State == rex.ProcessExited {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the running step to be killed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	command := rex.Command{Path: "true"}
+	for _, testCase := range []struct {
+		name  string
+		steps []rex.WorkflowStep
+	}{
+		{"empty", nil},
+		{"duplicate", []rex.WorkflowStep{{Name: "a", Command: command}, {Name: "a", Command: command}}},
+		{"unknown dependency", []rex.WorkflowStep{{Name: "a", Command: command, DependsOn: []string{"b"}}}},
+		{"cycle", []rex.WorkflowStep{
+			{Name: "a", Command: command},
+			{Name: "b", Command: command, DependsOn: []string{"a", "d"}},
+			{Name: "c", Command: command, DependsOn: []string{"b"}},
+			{Name: "d", Command: command, DependsOn: []string{"c"}},
+		}},
+		{"service", []rex.WorkflowStep{{Name: "a", Command: rex.Command{Path: "true", Restart: &rex.RestartPolicy{}}}}},
+	} {
+		if _, err := validateSteps(testCase.steps); err == nil {
+			t.Errorf("Expected %s steps to be rejected", testCase.name)
+		}
+	}
+
+	if _, err := validateSteps([]rex.WorkflowStep{
+		{Name: "a", Command: command},
+		{Name: "b", Command: command, DependsOn: []string{"a"}},
+		{Name: "c", Command: command, DependsOn: []string{"a", "b"}},
+	}); err != nil {
+		t.Errorf("Expected a valid DAG to be accepted, got: %v", err)
+	}
+}